@@ -0,0 +1,77 @@
+package main
+
+import "iter"
+
+// Seq adapts a GetNext/HasMore iterator to an iter.Seq2, so it can be used
+// with range. The iterator is consumed as the sequence is walked. Every
+// value comes with a nil error; if the iterator stops with an error, a last
+// pair holds the zero value and the error.
+func Seq[T any](it Iterator[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for it.HasMore() {
+			next, ok := it.GetNext()
+			if !ok || !yield(next, nil) {
+				return
+			}
+		}
+		if err := it.Err(); err != nil {
+			var zero T
+			yield(zero, err)
+		}
+	}
+}
+
+// PullIterator adapts an iter.Seq to the GetNext/HasMore protocol.
+type PullIterator[T any] struct {
+	next    func() (T, bool)
+	stop    func()
+	peeked  T
+	hasPeek bool
+	done    bool
+}
+
+// FromSeq returns an Iterator that pulls values from seq on demand.
+// Call Stop when the iterator is abandoned before it is exhausted.
+func FromSeq[T any](seq iter.Seq[T]) *PullIterator[T] {
+	next, stop := iter.Pull(seq)
+	return &PullIterator[T]{next: next, stop: stop}
+}
+
+func (p *PullIterator[T]) GetNext() (T, bool) {
+	if !p.HasMore() {
+		var zero T
+		return zero, false
+	}
+	v := p.peeked
+	var zero T
+	p.peeked, p.hasPeek = zero, false
+	return v, true
+}
+
+func (p *PullIterator[T]) HasMore() bool {
+	if p.hasPeek {
+		return true
+	}
+	if p.done {
+		return false
+	}
+	v, ok := p.next()
+	if !ok {
+		p.done = true
+		return false
+	}
+	p.peeked, p.hasPeek = v, true
+	return true
+}
+
+// Err is always nil: an iter.Seq has no way to report an error.
+func (p *PullIterator[T]) Err() error {
+	return nil
+}
+
+// Stop releases the underlying sequence.
+func (p *PullIterator[T]) Stop() {
+	var zero T
+	p.peeked, p.hasPeek, p.done = zero, false, true
+	p.stop()
+}
@@ -1,6 +1,14 @@
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+
+	"iterator-lazy"
+)
 
 // Model
 type Person interface {
@@ -15,21 +23,30 @@ func (e *Employee) Name() string {
 }
 
 // iterator interface
-type Iterator interface {
-	GetNext() (Person, bool)
+type Iterator[T any] interface {
+	GetNext() (T, bool)
 	HasMore() bool
+	// Err reports why GetNext and HasMore stopped early, e.g.
+	// ErrConcurrentModification, or nil at the end of the collection.
+	Err() error
 }
 
+var ErrConcurrentModification = errors.New("collection modified during iteration")
+
 // Concrete iterator
 type EmployeeIterator struct {
-	collection      *EmployeeCollection
-	currentPosition int
+	collection       *EmployeeCollection
+	currentPosition  int
+	expectedModCount int
+	err              error
 }
 
 func NewPersonIterator(collection *EmployeeCollection) *EmployeeIterator {
-	return &EmployeeIterator{collection: collection}
+	return &EmployeeIterator{collection: collection, expectedModCount: collection.modCount}
 }
 
+// GetNext fails fast: once the collection has been modified behind the
+// iterator's back it returns false, and Err reports ErrConcurrentModification.
 func (i *EmployeeIterator) GetNext() (Person, bool) { // change return type
 	if i.HasMore() {
 		result := i.collection.employeeList[i.currentPosition]
@@ -39,37 +56,130 @@ func (i *EmployeeIterator) GetNext() (Person, bool) { // change return type
 	return nil, false
 }
 func (i *EmployeeIterator) HasMore() bool {
-	return i.currentPosition < len(i.collection.employeeList)
+	if i.err == nil && i.collection.modCount != i.expectedModCount {
+		i.err = ErrConcurrentModification
+	}
+	return i.err == nil && i.currentPosition < len(i.collection.employeeList)
+}
+
+func (i *EmployeeIterator) Err() error {
+	return i.err
 }
 
 // iterable collection
 type IterableCollection interface {
-	CreateIterator() Iterator
+	CreateIterator() Iterator[Person]
 }
 
 // concrete collection
 type EmployeeCollection struct {
 	employeeList []Person
+	modCount     int // bumped on every change, see EmployeeIterator
 }
 
 func NewEmployeeCollection(employees []Person) *EmployeeCollection {
 	return &EmployeeCollection{employeeList: employees}
 }
 
-func (c *EmployeeCollection) CreateIterator() Iterator {
+func (c *EmployeeCollection) CreateIterator() Iterator[Person] {
 	return NewPersonIterator(c)
 }
 
+// CreateSnapshotIterator iterates over a copy of the collection, so it never
+// sees later changes and never fails.
+func (c *EmployeeCollection) CreateSnapshotIterator() Iterator[Person] {
+	return NewPersonIterator(NewEmployeeCollection(slices.Clone(c.employeeList)))
+}
+
+func (c *EmployeeCollection) Add(p Person) {
+	c.employeeList = append(c.employeeList, p)
+	c.modCount++
+}
+
+// Remove deletes the first occurrence of p and reports whether it was found.
+func (c *EmployeeCollection) Remove(p Person) bool {
+	i := slices.Index(c.employeeList, p)
+	if i < 0 {
+		return false
+	}
+	c.employeeList = slices.Delete(c.employeeList, i, i+1)
+	c.modCount++
+	return true
+}
+
+// All yields every employee, for use with range. If the collection is
+// modified inside the loop, the last pair holds ErrConcurrentModification.
+func (c *EmployeeCollection) All() iter.Seq2[Person, error] {
+	return func(yield func(Person, error) bool) {
+		// a new iterator for every loop, so the sequence can be reused
+		Seq(c.CreateIterator())(yield)
+	}
+}
+
+// Values yields every employee, for use with range and the lazy
+// combinators. It walks a copy of the collection taken when the loop
+// starts, so it never fails.
+func (c *EmployeeCollection) Values() iter.Seq[Person] {
+	return func(yield func(Person) bool) {
+		for _, p := range slices.Clone(c.employeeList) {
+			if !yield(p) {
+				return
+			}
+		}
+	}
+}
+
 func main() {
 	employees := []Person{
 		&Employee{"Leonard"},
 		&Employee{"Raphael"},
 	}
-	iterator := NewEmployeeCollection(employees).CreateIterator()
+	collection := NewEmployeeCollection(employees)
+	iterator := collection.CreateIterator()
 
 	for iterator.HasMore() {
 		if next, exists := iterator.GetNext(); exists {
 			fmt.Println(next.Name())
 		}
 	}
+
+	// range-over-func
+	for p, err := range collection.All() {
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		fmt.Println(p.Name())
+	}
+
+	// changing the collection invalidates running iterators
+	iterator = collection.CreateIterator()
+	snapshot := collection.CreateSnapshotIterator()
+	collection.Add(&Employee{"Donatello"})
+	if _, exists := iterator.GetNext(); !exists {
+		fmt.Println(iterator.Err())
+	}
+	for snapshot.HasMore() {
+		if next, exists := snapshot.GetNext(); exists {
+			fmt.Println(next.Name())
+		}
+	}
+
+	// pipelines of lazy combinators instead of temporary slices
+	upper := lazy.Map(lazy.Distinct(lazy.Map(collection.Values(), Person.Name)), strings.ToUpper)
+	for names := range lazy.Chunk(upper, 2) {
+		fmt.Println(names)
+	}
+	for name, id := range lazy.Zip(lazy.Map(collection.Values(), Person.Name), lazy.Skip(lazy.Naturals(), 100)) {
+		fmt.Println(id, name)
+	}
+
+	// any iter.Seq can be consumed with the GetNext/HasMore protocol
+	pull := FromSeq(collection.Values())
+	defer pull.Stop()
+	for pull.HasMore() {
+		if next, exists := pull.GetNext(); exists {
+			fmt.Println(next.Name())
+		}
+	}
 }
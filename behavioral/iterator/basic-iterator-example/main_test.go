@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"iter"
+	"slices"
+	"testing"
+
+	"iterator-lazy"
+)
+
+func newTestCollection() *EmployeeCollection {
+	return NewEmployeeCollection([]Person{
+		&Employee{"Leonard"},
+		&Employee{"Raphael"},
+		&Employee{"Donatello"},
+	})
+}
+
+func names(people []Person) []string {
+	result := make([]string, len(people))
+	for i, p := range people {
+		result[i] = p.Name()
+	}
+	return result
+}
+
+func TestEmployeeCollection_All(t *testing.T) {
+	var got []string
+	for p, err := range newTestCollection().All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p.Name())
+	}
+	expected := []string{"Leonard", "Raphael", "Donatello"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+
+	for p := range newTestCollection().All() {
+		if p.Name() != "Leonard" {
+			t.Errorf("Expected to stop at the first element, got %s", p.Name())
+		}
+		break
+	}
+}
+
+// collect gathers the values of seq, and stops at the first error.
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var result []T
+	for v, err := range seq {
+		if err != nil {
+			return result, err
+		}
+		result = append(result, v)
+	}
+	return result, nil
+}
+
+func TestEmployeeCollection_ReuseSequences(t *testing.T) {
+	collection := newTestCollection()
+	all, values := collection.All(), collection.Values()
+	collection.Add(&Employee{"Michelangelo"}) // before the loops start
+	expected := names(collection.employeeList)
+	for range 2 {
+		if got, err := collect(all); err != nil || !slices.Equal(names(got), expected) {
+			t.Errorf("Expected %v but got %v, %v", expected, names(got), err)
+		}
+		if got := slices.Collect(values); !slices.Equal(names(got), expected) {
+			t.Errorf("Expected %v but got %v", expected, names(got))
+		}
+	}
+}
+
+func TestFromSeq(t *testing.T) {
+	collection := newTestCollection()
+	it := FromSeq(collection.Values())
+	defer it.Stop()
+
+	var got []Person
+	for it.HasMore() {
+		next, ok := it.GetNext()
+		if !ok {
+			t.Fatal("GetNext failed although HasMore returned true")
+		}
+		got = append(got, next)
+	}
+	if !slices.Equal(names(got), names(collection.employeeList)) {
+		t.Errorf("Expected %v but got %v", names(collection.employeeList), names(got))
+	}
+	if _, ok := it.GetNext(); ok {
+		t.Error("Expected an exhausted iterator")
+	}
+}
+
+func TestFromSeq_Stop(t *testing.T) {
+	it := FromSeq(newTestCollection().Values())
+	it.GetNext()
+	it.Stop()
+	if it.HasMore() {
+		t.Error("Expected no more elements after Stop")
+	}
+}
+
+func TestEmployeeIterator_ConcurrentModification(t *testing.T) {
+	collection := newTestCollection()
+	it := NewPersonIterator(collection)
+	it.GetNext()
+
+	collection.Remove(collection.employeeList[0])
+	if it.HasMore() {
+		t.Error("Expected HasMore to be false after a modification")
+	}
+	if _, ok := it.GetNext(); ok {
+		t.Error("Expected GetNext to fail after a modification")
+	}
+	if !errors.Is(it.Err(), ErrConcurrentModification) {
+		t.Errorf("Expected ErrConcurrentModification, got %v", it.Err())
+	}
+}
+
+func TestEmployeeCollection_SnapshotIterator(t *testing.T) {
+	collection := newTestCollection()
+	snapshot := collection.CreateSnapshotIterator()
+	expected := names(collection.employeeList)
+
+	collection.Add(&Employee{"Michelangelo"})
+	got, err := collect(Seq(snapshot))
+	if err != nil || !slices.Equal(names(got), expected) {
+		t.Errorf("Expected %v but got %v", expected, names(got))
+	}
+}
+
+func TestEmployeeCollection_AllReportsModification(t *testing.T) {
+	collection := newTestCollection()
+	var removed []string
+	var err error
+	for p, e := range collection.All() {
+		if err = e; err != nil {
+			break
+		}
+		collection.Remove(p)
+		removed = append(removed, p.Name())
+	}
+	if !errors.Is(err, ErrConcurrentModification) || !slices.Equal(removed, []string{"Leonard"}) {
+		t.Errorf("Expected ErrConcurrentModification after Leonard, got %v, %v", removed, err)
+	}
+
+	// Values walks a copy, so it may be modified in the loop
+	collection = newTestCollection()
+	for p := range collection.Values() {
+		collection.Remove(p)
+	}
+	if len(collection.employeeList) != 0 {
+		t.Errorf("Expected every employee to be removed, got %v", names(collection.employeeList))
+	}
+}
+
+func TestEmployeeCollection_Lazy(t *testing.T) {
+	collection := newTestCollection()
+	long := lazy.Filter(lazy.Map(collection.Values(), Person.Name), func(name string) bool { return len(name) > 7 })
+	for range 2 {
+		if got := lazy.Collect(long); !slices.Equal(got, []string{"Donatello"}) {
+			t.Errorf("Unexpected names %v", got)
+		}
+	}
+	if got := lazy.Collect(lazy.Map(lazy.FromNext(collection.CreateIterator().GetNext), Person.Name)); len(got) != 3 {
+		t.Errorf("Expected FromNext to walk the iterator, got %v", got)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import "iter"
+
+// Iterator adapts an iter.Seq to the MoveNext/Current protocol used by
+// InOrderIterator.
+type Iterator[T any] struct {
+	Current T
+	next    func() (T, bool)
+	stop    func()
+}
+
+// NewIterator returns an Iterator that pulls values from seq on demand.
+// Call Stop when the iterator is abandoned before it is exhausted.
+func NewIterator[T any](seq iter.Seq[T]) *Iterator[T] {
+	next, stop := iter.Pull(seq)
+	return &Iterator[T]{next: next, stop: stop}
+}
+
+func (i *Iterator[T]) MoveNext() bool {
+	v, ok := i.next()
+	if !ok {
+		var zero T
+		i.Current = zero
+		return false
+	}
+	i.Current = v
+	return true
+}
+
+// Seq adapts the iterator back to an iter.Seq.
+func (i *Iterator[T]) Seq() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i.MoveNext() {
+			if !yield(i.Current) {
+				return
+			}
+		}
+	}
+}
+
+// Err is always nil: an iter.Seq has no way to report an error.
+func (i *Iterator[T]) Err() error {
+	return nil
+}
+
+// Stop releases the underlying sequence.
+func (i *Iterator[T]) Stop() {
+	i.stop()
+}
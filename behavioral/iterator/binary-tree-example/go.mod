@@ -0,0 +1,7 @@
+module iterator-binary-tree
+
+go 1.23.6
+
+require iterator-lazy v0.0.0
+
+replace iterator-lazy => ../lazy
@@ -1,43 +1,62 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"iter"
 
-type Node struct {
-	Value               int
-	left, right, parent *Node
+	"iterator-lazy"
+)
+
+type Node[T any] struct {
+	Value               T
+	left, right, parent *Node[T]
+	height              int // only maintained by SearchTree
 }
 
-func NewNode(value int, left, right *Node) *Node {
-	n := &Node{Value: value, left: left, right: right}
-	left.parent = n
-	right.parent = n
+// NewNode links value to its children. Either child may be nil.
+func NewNode[T any](value T, left, right *Node[T]) *Node[T] {
+	n := &Node[T]{Value: value, left: left, right: right}
+	if left != nil {
+		left.parent = n
+	}
+	if right != nil {
+		right.parent = n
+	}
 	return n
 }
 
-func NewTerminalNode(value int) *Node {
-	return &Node{Value: value}
+func NewTerminalNode[T any](value T) *Node[T] {
+	return &Node[T]{Value: value}
 }
 
-type InOrderIterator struct {
-	Current       *Node
-	root          *Node
+type InOrderIterator[T any] struct {
+	Current       *Node[T]
+	root          *Node[T]
 	returnedStart bool
+	modGuard[T]
 }
 
-func NewInOrderIterator(root *Node) *InOrderIterator {
-	i := &InOrderIterator{root, root, false}
-	for i.Current.left != nil {
-		i.Current = i.Current.left
-	}
+func NewInOrderIterator[T any](root *Node[T]) *InOrderIterator[T] {
+	i := &InOrderIterator[T]{root: root}
+	i.Reset()
 	return i
 }
 
-func (i *InOrderIterator) Reset() {
+// Reset moves the iterator back to the leftmost node, where an in-order
+// traversal starts.
+func (i *InOrderIterator[T]) Reset() {
+	i.resync()
 	i.Current = i.root
 	i.returnedStart = false
+	for i.Current != nil && i.Current.left != nil {
+		i.Current = i.Current.left
+	}
 }
 
-func (i *InOrderIterator) MoveNext() bool {
+func (i *InOrderIterator[T]) MoveNext() bool {
+	if !i.check() {
+		return false
+	}
 	if i.Current == nil {
 		return false
 	}
@@ -63,16 +82,79 @@ func (i *InOrderIterator) MoveNext() bool {
 	}
 }
 
-type BinaryTree struct {
-	root *Node
+// Seq adapts the iterator to an iter.Seq. The iterator is consumed as the
+// sequence is walked.
+func (i *InOrderIterator[T]) Seq() iter.Seq2[*Node[T], error] {
+	return seq(i.MoveNext, func() *Node[T] { return i.Current }, i.Err)
+}
+
+type BinaryTree[T any] struct {
+	root     *Node[T]
+	modCount int // bumped on every change, see modGuard
+}
+
+func NewBinaryTree[T any](root *Node[T]) *BinaryTree[T] {
+	return &BinaryTree[T]{root: root}
+}
+
+func (b *BinaryTree[T]) InOrder() *InOrderIterator[T] {
+	i := NewInOrderIterator(b.root)
+	i.watch(b)
+	return i
+}
+
+func (b *BinaryTree[T]) PreOrder() *PreOrderIterator[T] {
+	i := NewPreOrderIterator(b.root)
+	i.watch(b)
+	return i
+}
+
+func (b *BinaryTree[T]) PostOrder() *PostOrderIterator[T] {
+	i := NewPostOrderIterator(b.root)
+	i.watch(b)
+	return i
+}
+
+func (b *BinaryTree[T]) LevelOrder() *LevelOrderIterator[T] {
+	i := NewLevelOrderIterator(b.root)
+	i.watch(b)
+	return i
+}
+
+func (b *BinaryTree[T]) ReverseInOrder() *ReverseInOrderIterator[T] {
+	i := NewReverseInOrderIterator(b.root)
+	i.watch(b)
+	return i
 }
 
-func NewBinaryTree(root *Node) *BinaryTree {
-	return &BinaryTree{root: root}
+// DepthLimited walks the tree in pre-order without going below maxDepth.
+// The root is at depth 0.
+func (b *BinaryTree[T]) DepthLimited(maxDepth int) *DepthLimitedIterator[T] {
+	i := NewDepthLimitedIterator(b.root, maxDepth)
+	i.watch(b)
+	return i
 }
 
-func (b *BinaryTree) InOrder() *InOrderIterator {
-	return NewInOrderIterator(b.root)
+// All yields the nodes in order, for use with range. If the tree is
+// modified inside the loop, the last pair holds ErrConcurrentModification.
+func (b *BinaryTree[T]) All() iter.Seq2[*Node[T], error] {
+	return func(yield func(*Node[T], error) bool) {
+		// a new iterator for every loop, so the sequence can be reused
+		b.InOrder().Seq()(yield)
+	}
+}
+
+// Values yields the node values in order, for use with range and the lazy
+// combinators. It walks a snapshot taken when the loop starts, so it never
+// fails.
+func (b *BinaryTree[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for n := range b.Snapshot().All() { // a snapshot has no error to report
+			if !yield(n.Value) {
+				return
+			}
+		}
+	}
 }
 
 func main() {
@@ -85,4 +167,62 @@ func main() {
 	for i := t.InOrder(); i.MoveNext(); {
 		fmt.Printf("%d\n", i.Current.Value)
 	}
+
+	fmt.Print("pre-order:")
+	for i := t.PreOrder(); i.MoveNext(); {
+		fmt.Printf(" %d", i.Current.Value)
+	}
+	fmt.Print("\npost-order:")
+	for i := t.PostOrder(); i.MoveNext(); {
+		fmt.Printf(" %d", i.Current.Value)
+	}
+	fmt.Print("\nlevel-order:")
+	for i := t.LevelOrder(); i.MoveNext(); {
+		fmt.Printf(" %d", i.Current.Value)
+	}
+	fmt.Print("\nreverse in-order:")
+	for i := t.ReverseInOrder(); i.MoveNext(); {
+		fmt.Printf(" %d", i.Current.Value)
+	}
+	fmt.Print("\ndepth 0:")
+	for i := t.DepthLimited(0); i.MoveNext(); {
+		fmt.Printf(" %d", i.Current.Value)
+	}
+	fmt.Println()
+
+	st := NewSearchTree(5, 3, 8, 1, 4)
+	st.Delete(3)
+	floor, _ := st.Floor(6)
+	ceiling, _ := st.Ceiling(6)
+	fmt.Println("floor(6):", floor, "ceiling(6):", ceiling)
+	for k := range st.Range(1, 6) {
+		fmt.Printf("%d\n", k)
+	}
+
+	// changing the tree invalidates running iterators
+	it := t.PreOrder()
+	snapshot := t.Snapshot()
+	t.SetRight(root, NewTerminalNode(4))
+	if !it.MoveNext() {
+		fmt.Println(it.Err())
+	}
+	for n := range snapshot.All() {
+		fmt.Printf("%d\n", n.Value)
+	}
+
+	for v := range t.Values() {
+		fmt.Printf("%d\n", v)
+	}
+
+	// pipelines of lazy combinators instead of temporary slices
+	odd := lazy.Filter(st.All(), func(v int) bool { return v%2 == 1 })
+	fmt.Println("sum of odd keys:", lazy.Reduce(odd, 0, func(acc, v int) int { return acc + v }))
+	fmt.Println(lazy.Collect(lazy.Take(lazy.Chain(t.Values(), lazy.Naturals()), 6)))
+
+	// any iter.Seq can be consumed with the MoveNext/Current protocol
+	i := NewIterator(t.Values())
+	defer i.Stop()
+	for i.MoveNext() {
+		fmt.Printf("%d\n", i.Current)
+	}
 }
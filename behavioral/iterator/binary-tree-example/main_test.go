@@ -0,0 +1,61 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"iterator-lazy"
+)
+
+func newTestTree() *BinaryTree[int] {
+	return NewBinaryTree(NewNode(1,
+		NewTerminalNode(2),
+		NewTerminalNode(3),
+	))
+}
+
+func TestBinaryTree_Values(t *testing.T) {
+	got := slices.Collect(newTestTree().Values())
+	expected := []int{2, 1, 3}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+
+	for v := range newTestTree().Values() {
+		if v != 2 {
+			t.Errorf("Expected to stop at the first value, got %d", v)
+		}
+		break
+	}
+}
+
+func TestIterator(t *testing.T) {
+	i := NewIterator(newTestTree().Values())
+	defer i.Stop()
+
+	var got []int
+	for i.MoveNext() {
+		got = append(got, i.Current)
+	}
+	expected := []int{2, 1, 3}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+	if i.MoveNext() {
+		t.Error("Expected an exhausted iterator")
+	}
+}
+
+func TestBinaryTree_Lazy(t *testing.T) {
+	tree := newTestTree()
+	values := lazy.Skip(tree.Values(), 1)
+	for range 2 {
+		if got := lazy.Collect(values); !slices.Equal(got, []int{1, 3}) {
+			t.Errorf("Unexpected tree values %v", got)
+		}
+	}
+	i := tree.InOrder()
+	if got := lazy.Collect(lazy.FromMoveNext(i.MoveNext, func() int { return i.Current.Value })); !slices.Equal(got, []int{2, 1, 3}) {
+		t.Errorf("Expected FromMoveNext to walk the iterator, got %v", got)
+	}
+}
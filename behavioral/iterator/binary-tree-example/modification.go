@@ -0,0 +1,81 @@
+package main
+
+import "errors"
+
+var ErrConcurrentModification = errors.New("tree modified during iteration")
+
+// modGuard makes an iterator fail fast when the BinaryTree it was created
+// from is modified. Iterators built directly from a root node have no tree
+// to watch and are never checked.
+type modGuard[T any] struct {
+	tree             *BinaryTree[T]
+	expectedModCount int
+	err              error
+}
+
+func (g *modGuard[T]) watch(tree *BinaryTree[T]) {
+	g.tree = tree
+	g.resync()
+}
+
+// resync accepts the tree as it is now, e.g. when the iterator is Reset. An
+// iterator that has already failed stays failed, so that the error is not
+// lost; a new iterator has to be created from the tree.
+func (g *modGuard[T]) resync() {
+	if g.err == nil && g.tree != nil {
+		g.expectedModCount = g.tree.modCount
+	}
+}
+
+func (g *modGuard[T]) check() bool {
+	if g.err == nil && g.tree != nil && g.tree.modCount != g.expectedModCount {
+		g.err = ErrConcurrentModification
+	}
+	return g.err == nil
+}
+
+// Err reports ErrConcurrentModification once MoveNext has stopped because the
+// tree was modified.
+func (g *modGuard[T]) Err() error {
+	return g.err
+}
+
+// SetLeft replaces the left subtree of parent. child may be nil to prune it.
+func (b *BinaryTree[T]) SetLeft(parent, child *Node[T]) {
+	if parent.left != nil {
+		parent.left.parent = nil
+	}
+	parent.left = child
+	if child != nil {
+		child.parent = parent
+	}
+	b.modCount++
+}
+
+// SetRight replaces the right subtree of parent. child may be nil to prune it.
+func (b *BinaryTree[T]) SetRight(parent, child *Node[T]) {
+	if parent.right != nil {
+		parent.right.parent = nil
+	}
+	parent.right = child
+	if child != nil {
+		child.parent = parent
+	}
+	b.modCount++
+}
+
+// Snapshot returns a deep copy of the tree. Iterators over the copy never see
+// later changes to the original and never fail.
+func (b *BinaryTree[T]) Snapshot() *BinaryTree[T] {
+	return NewBinaryTree(clone(b.root, nil))
+}
+
+func clone[T any](n, parent *Node[T]) *Node[T] {
+	if n == nil {
+		return nil
+	}
+	c := &Node[T]{Value: n.Value, parent: parent, height: n.height}
+	c.left = clone(n.left, c)
+	c.right = clone(n.right, c)
+	return c
+}
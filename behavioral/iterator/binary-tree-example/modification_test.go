@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestBinaryTree_ConcurrentModification(t *testing.T) {
+	tree := newFullTree()
+	iterators := []interface {
+		MoveNext() bool
+		Reset()
+		Err() error
+	}{
+		tree.InOrder(), tree.PreOrder(), tree.PostOrder(),
+		tree.LevelOrder(), tree.ReverseInOrder(), tree.DepthLimited(2),
+	}
+	for _, it := range iterators {
+		it.MoveNext()
+	}
+
+	tree.SetLeft(tree.root, NewTerminalNode(0))
+	for _, it := range iterators {
+		if it.MoveNext() {
+			t.Errorf("%T: expected MoveNext to fail after a modification", it)
+		}
+		if !errors.Is(it.Err(), ErrConcurrentModification) {
+			t.Errorf("%T: expected ErrConcurrentModification, got %v", it, it.Err())
+		}
+		it.Reset()
+		if it.MoveNext() || !errors.Is(it.Err(), ErrConcurrentModification) {
+			t.Errorf("%T: expected the error to survive Reset, got %v", it, it.Err())
+		}
+	}
+
+	// a modification before the first failure is accepted by Reset
+	it := tree.PreOrder()
+	it.MoveNext()
+	tree.SetLeft(tree.root, nil)
+	it.Reset()
+	if !it.MoveNext() || it.Err() != nil {
+		t.Errorf("Expected Reset to accept the modified tree, got %v", it.Err())
+	}
+}
+
+func TestBinaryTree_Snapshot(t *testing.T) {
+	tree := newFullTree()
+	snapshot := tree.Snapshot()
+
+	var got []int
+	for n := range snapshot.All() {
+		got = append(got, n.Value)
+		tree.SetRight(tree.root, nil)
+	}
+	if !slices.Equal(got, []int{1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("Unexpected snapshot values %v", got)
+	}
+	if got := slices.Collect(tree.Values()); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("Unexpected values after pruning %v", got)
+	}
+}
+
+func TestBinaryTree_AllReportsModification(t *testing.T) {
+	tree := newFullTree()
+	var visited []int
+	var err error
+	for n, e := range tree.All() {
+		if err = e; err != nil {
+			break
+		}
+		visited = append(visited, n.Value)
+		tree.SetLeft(n, nil)
+	}
+	if !errors.Is(err, ErrConcurrentModification) || !slices.Equal(visited, []int{1}) {
+		t.Errorf("Expected ErrConcurrentModification after 1, got %v, %v", visited, err)
+	}
+
+	// Values walks a snapshot, so the tree may be modified in the loop
+	tree = newFullTree()
+	visited = nil
+	for v := range tree.Values() {
+		visited = append(visited, v)
+		tree.SetRight(tree.root, nil)
+	}
+	if !slices.Equal(visited, []int{1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("Unexpected values %v", visited)
+	}
+}
+
+func TestSearchTree_TreeViewFailsFast(t *testing.T) {
+	st := NewSearchTree(1, 2, 3, 4, 5)
+	it := st.Tree().LevelOrder()
+	it.MoveNext()
+	st.Insert(6)
+	if it.MoveNext() || !errors.Is(it.Err(), ErrConcurrentModification) {
+		t.Error("Expected the BinaryTree view to fail after an insert")
+	}
+}
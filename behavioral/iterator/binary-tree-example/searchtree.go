@@ -0,0 +1,341 @@
+package main
+
+import (
+	"cmp"
+	"iter"
+)
+
+// SearchTree is an ordered set of keys stored in an AVL tree. It keeps itself
+// balanced, so Insert, Delete, Contains, Floor and Ceiling are O(log n).
+type SearchTree[K cmp.Ordered] struct {
+	nodes BinaryTree[K]
+	size  int
+}
+
+func NewSearchTree[K cmp.Ordered](keys ...K) *SearchTree[K] {
+	t := &SearchTree[K]{}
+	for _, k := range keys {
+		t.Insert(k)
+	}
+	return t
+}
+
+func (t *SearchTree[K]) Len() int {
+	return t.size
+}
+
+// Insert adds key to the tree. It reports false if the key was already there.
+func (t *SearchTree[K]) Insert(key K) bool {
+	var inserted bool
+	t.nodes.root, inserted = insert(t.nodes.root, key)
+	t.nodes.root.parent = nil
+	if inserted {
+		t.size++
+		t.nodes.modCount++
+	}
+	return inserted
+}
+
+// Delete removes key from the tree. It reports false if the key was missing.
+// Nodes are relinked rather than having their values swapped, so a *Node
+// obtained earlier never changes its Value.
+func (t *SearchTree[K]) Delete(key K) bool {
+	var deleted bool
+	t.nodes.root, deleted = remove(t.nodes.root, key)
+	if t.nodes.root != nil {
+		t.nodes.root.parent = nil
+	}
+	if deleted {
+		t.size--
+		t.nodes.modCount++
+	}
+	return deleted
+}
+
+// Find returns the node holding key, or nil.
+func (t *SearchTree[K]) Find(key K) *Node[K] {
+	n := t.nodes.root
+	for n != nil {
+		switch c := cmp.Compare(key, n.Value); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n
+		}
+	}
+	return nil
+}
+
+func (t *SearchTree[K]) Contains(key K) bool {
+	return t.Find(key) != nil
+}
+
+// Floor returns the greatest key less than or equal to key.
+func (t *SearchTree[K]) Floor(key K) (K, bool) {
+	return nodeValue(t.search(key, true, true))
+}
+
+// Ceiling returns the smallest key greater than or equal to key.
+func (t *SearchTree[K]) Ceiling(key K) (K, bool) {
+	return nodeValue(t.search(key, false, true))
+}
+
+// search finds the closest node below (or above) key, including key itself
+// when inclusive is set.
+func (t *SearchTree[K]) search(key K, below, inclusive bool) *Node[K] {
+	var best *Node[K]
+	n := t.nodes.root
+	for n != nil {
+		c := cmp.Compare(key, n.Value)
+		switch {
+		case c == 0 && inclusive:
+			return n
+		case below && c > 0, !below && c < 0:
+			best = n
+		}
+		if c < 0 || (c == 0 && below) {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return best
+}
+
+func (t *SearchTree[K]) min() *Node[K] {
+	n := t.nodes.root
+	for n != nil && n.left != nil {
+		n = n.left
+	}
+	return n
+}
+
+func (t *SearchTree[K]) max() *Node[K] {
+	n := t.nodes.root
+	for n != nil && n.right != nil {
+		n = n.right
+	}
+	return n
+}
+
+// InOrder returns an ascending iterator. It is safe to insert or delete keys,
+// including the current one, while the iterator is in use.
+func (t *SearchTree[K]) InOrder() *SearchTreeIterator[K] {
+	return &SearchTreeIterator[K]{tree: t}
+}
+
+// ReverseInOrder returns a descending iterator with the same guarantees as
+// InOrder.
+func (t *SearchTree[K]) ReverseInOrder() *SearchTreeIterator[K] {
+	return &SearchTreeIterator[K]{tree: t, reverse: true}
+}
+
+// All yields the keys in ascending order, for use with range.
+func (t *SearchTree[K]) All() iter.Seq[K] {
+	return keys(t.InOrder().Seq())
+}
+
+// Range yields the keys in [from, to) in ascending order.
+func (t *SearchTree[K]) Range(from, to K) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		n := t.search(from, false, true)
+		for n != nil && cmp.Less(n.Value, to) {
+			if !yield(n.Value) {
+				return
+			}
+			n = t.search(n.Value, false, false)
+		}
+	}
+}
+
+// Tree exposes the underlying nodes as a BinaryTree, so the other traversal
+// orders can be used. Unlike InOrder, those iterators follow the node links,
+// so they fail with ErrConcurrentModification once a key is inserted or
+// deleted. Use Tree().Snapshot() to traverse while modifying.
+func (t *SearchTree[K]) Tree() *BinaryTree[K] {
+	return &t.nodes
+}
+
+// SearchTreeIterator walks a SearchTree with the MoveNext/Current protocol.
+// Every step looks up the successor of the last key from the root instead of
+// following node links, which keeps it correct when nodes are deleted.
+type SearchTreeIterator[K cmp.Ordered] struct {
+	Current *Node[K]
+	tree    *SearchTree[K]
+	reverse bool
+	started bool
+}
+
+func (i *SearchTreeIterator[K]) Reset() {
+	i.Current = nil
+	i.started = false
+}
+
+func (i *SearchTreeIterator[K]) MoveNext() bool {
+	switch {
+	case !i.started && i.reverse:
+		i.Current = i.tree.max()
+	case !i.started:
+		i.Current = i.tree.min()
+	case i.Current == nil:
+		return false
+	default:
+		i.Current = i.tree.search(i.Current.Value, i.reverse, false)
+	}
+	i.started = true
+	return i.Current != nil
+}
+
+// Seq adapts the iterator to an iter.Seq. It has no error to report.
+func (i *SearchTreeIterator[K]) Seq() iter.Seq[*Node[K]] {
+	return func(yield func(*Node[K]) bool) {
+		for i.MoveNext() {
+			if !yield(i.Current) {
+				return
+			}
+		}
+	}
+}
+
+func keys[K any](nodes iter.Seq[*Node[K]]) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for n := range nodes {
+			if !yield(n.Value) {
+				return
+			}
+		}
+	}
+}
+
+func nodeValue[K any](n *Node[K]) (K, bool) {
+	if n == nil {
+		var zero K
+		return zero, false
+	}
+	return n.Value, true
+}
+
+func insert[K cmp.Ordered](n *Node[K], key K) (*Node[K], bool) {
+	if n == nil {
+		return &Node[K]{Value: key, height: 1}, true
+	}
+	var inserted bool
+	switch c := cmp.Compare(key, n.Value); {
+	case c < 0:
+		n.left, inserted = insert(n.left, key)
+		n.left.parent = n
+	case c > 0:
+		n.right, inserted = insert(n.right, key)
+		n.right.parent = n
+	default:
+		return n, false
+	}
+	return rebalance(n), inserted
+}
+
+func remove[K cmp.Ordered](n *Node[K], key K) (*Node[K], bool) {
+	if n == nil {
+		return nil, false
+	}
+	var deleted bool
+	switch c := cmp.Compare(key, n.Value); {
+	case c < 0:
+		n.left, deleted = remove(n.left, key)
+		setParent(n.left, n)
+	case c > 0:
+		n.right, deleted = remove(n.right, key)
+		setParent(n.right, n)
+	default:
+		var replacement *Node[K]
+		switch {
+		case n.left == nil:
+			replacement = n.right
+		case n.right == nil:
+			replacement = n.left
+		default:
+			var right *Node[K]
+			right, replacement = removeMin(n.right)
+			replacement.left, replacement.right = n.left, right
+			setParent(replacement.left, replacement)
+			setParent(replacement.right, replacement)
+			replacement = rebalance(replacement)
+		}
+		setParent(replacement, n.parent)
+		n.left, n.right, n.parent, n.height = nil, nil, nil, 0
+		return replacement, true
+	}
+	return rebalance(n), deleted
+}
+
+// removeMin unlinks the smallest node of the subtree rooted at n and returns
+// the new subtree root together with the unlinked node.
+func removeMin[K any](n *Node[K]) (*Node[K], *Node[K]) {
+	if n.left == nil {
+		setParent(n.right, n.parent)
+		return n.right, n
+	}
+	var m *Node[K]
+	n.left, m = removeMin(n.left)
+	setParent(n.left, n)
+	return rebalance(n), m
+}
+
+func rebalance[K any](n *Node[K]) *Node[K] {
+	updateHeight(n)
+	switch balance := height(n.left) - height(n.right); {
+	case balance > 1:
+		if height(n.left.left) < height(n.left.right) {
+			n.left = rotateLeft(n.left)
+		}
+		return rotateRight(n)
+	case balance < -1:
+		if height(n.right.right) < height(n.right.left) {
+			n.right = rotateRight(n.right)
+		}
+		return rotateLeft(n)
+	}
+	return n
+}
+
+func rotateLeft[K any](n *Node[K]) *Node[K] {
+	r := n.right
+	n.right = r.left
+	setParent(n.right, n)
+	r.left = n
+	r.parent = n.parent
+	n.parent = r
+	updateHeight(n)
+	updateHeight(r)
+	return r
+}
+
+func rotateRight[K any](n *Node[K]) *Node[K] {
+	l := n.left
+	n.left = l.right
+	setParent(n.left, n)
+	l.right = n
+	l.parent = n.parent
+	n.parent = l
+	updateHeight(n)
+	updateHeight(l)
+	return l
+}
+
+func height[K any](n *Node[K]) int {
+	if n == nil {
+		return 0
+	}
+	return n.height
+}
+
+func updateHeight[K any](n *Node[K]) {
+	n.height = 1 + max(height(n.left), height(n.right))
+}
+
+func setParent[K any](n, parent *Node[K]) {
+	if n != nil {
+		n.parent = parent
+	}
+}
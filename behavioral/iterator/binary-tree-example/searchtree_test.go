@@ -0,0 +1,140 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// checkInvariants verifies ordering, AVL balance and parent links, and
+// returns the number of nodes.
+func checkInvariants[K int | string](t *testing.T, n, parent *Node[K], lo, hi *K) int {
+	t.Helper()
+	if n == nil {
+		return 0
+	}
+	if n.parent != parent {
+		t.Fatalf("Broken parent link at %v", n.Value)
+	}
+	if (lo != nil && n.Value <= *lo) || (hi != nil && n.Value >= *hi) {
+		t.Fatalf("Key %v is out of order", n.Value)
+	}
+	if b := height(n.left) - height(n.right); b < -1 || b > 1 {
+		t.Fatalf("Node %v is unbalanced: %d", n.Value, b)
+	}
+	if n.height != 1+max(height(n.left), height(n.right)) {
+		t.Fatalf("Wrong height at %v", n.Value)
+	}
+	return 1 + checkInvariants(t, n.left, n, lo, &n.Value) + checkInvariants(t, n.right, n, &n.Value, hi)
+}
+
+func TestSearchTree_RandomOperations(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	tree := NewSearchTree[int]()
+	reference := map[int]bool{}
+
+	for range 5000 {
+		key := r.IntN(500)
+		if r.IntN(3) == 0 {
+			if tree.Delete(key) != reference[key] {
+				t.Fatalf("Delete(%d) disagrees with the reference", key)
+			}
+			delete(reference, key)
+		} else {
+			if tree.Insert(key) == reference[key] {
+				t.Fatalf("Insert(%d) disagrees with the reference", key)
+			}
+			reference[key] = true
+		}
+	}
+
+	if n := checkInvariants(t, tree.nodes.root, nil, nil, nil); n != len(reference) || tree.Len() != n {
+		t.Fatalf("Expected %d keys, found %d nodes and Len %d", len(reference), n, tree.Len())
+	}
+	var expected []int
+	for k := range reference {
+		expected = append(expected, k)
+	}
+	slices.Sort(expected)
+	if got := slices.Collect(tree.All()); !slices.Equal(got, expected) {
+		t.Errorf("Keys are not in ascending order: %v", got)
+	}
+	if got := values(tree.Tree().InOrder().Seq()); !slices.Equal(got, expected) {
+		t.Errorf("BinaryTree view disagrees with All: %v", got)
+	}
+}
+
+func TestSearchTree_FloorCeiling(t *testing.T) {
+	tree := NewSearchTree(10, 20, 30, 40)
+	tests := []struct {
+		key            int
+		floor, ceiling int
+		hasFloor       bool
+		hasCeiling     bool
+	}{
+		{5, 0, 10, false, true},
+		{10, 10, 10, true, true},
+		{25, 20, 30, true, true},
+		{40, 40, 40, true, true},
+		{45, 40, 0, true, false},
+	}
+	for _, tt := range tests {
+		if f, ok := tree.Floor(tt.key); ok != tt.hasFloor || f != tt.floor {
+			t.Errorf("Floor(%d) = %d, %v", tt.key, f, ok)
+		}
+		if c, ok := tree.Ceiling(tt.key); ok != tt.hasCeiling || c != tt.ceiling {
+			t.Errorf("Ceiling(%d) = %d, %v", tt.key, c, ok)
+		}
+	}
+}
+
+func TestSearchTree_Range(t *testing.T) {
+	tree := NewSearchTree(1, 3, 5, 7, 9, 11)
+	if got := slices.Collect(tree.Range(3, 9)); !slices.Equal(got, []int{3, 5, 7}) {
+		t.Errorf("Unexpected range %v", got)
+	}
+	if got := slices.Collect(tree.Range(4, 4)); len(got) != 0 {
+		t.Errorf("Expected an empty range, got %v", got)
+	}
+}
+
+func TestSearchTree_DeleteWhileIterating(t *testing.T) {
+	tree := NewSearchTree[int]()
+	for i := range 100 {
+		tree.Insert(i)
+	}
+
+	var got []int
+	for i := tree.InOrder(); i.MoveNext(); {
+		got = append(got, i.Current.Value)
+		// delete the current key and the one after it
+		tree.Delete(i.Current.Value)
+		tree.Delete(i.Current.Value + 1)
+	}
+	var expected []int
+	for i := 0; i < 100; i += 2 {
+		expected = append(expected, i)
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+	if tree.Len() != 0 || tree.nodes.root != nil {
+		t.Errorf("Expected an empty tree, Len is %d", tree.Len())
+	}
+}
+
+func TestSearchTree_ReverseInOrder(t *testing.T) {
+	tree := NewSearchTree("b", "d", "a", "c")
+	var got []string
+	i := tree.ReverseInOrder()
+	for i.MoveNext() {
+		got = append(got, i.Current.Value)
+	}
+	if !slices.Equal(got, []string{"d", "c", "b", "a"}) {
+		t.Errorf("Unexpected order %v", got)
+	}
+	i.Reset()
+	if !i.MoveNext() || i.Current.Value != "d" {
+		t.Error("Expected Reset to go back to the greatest key")
+	}
+}
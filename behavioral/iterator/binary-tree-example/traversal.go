@@ -0,0 +1,288 @@
+package main
+
+import "iter"
+
+// PreOrderIterator visits a node before its left and right subtrees.
+type PreOrderIterator[T any] struct {
+	Current *Node[T]
+	root    *Node[T]
+	stack   []*Node[T]
+	modGuard[T]
+}
+
+func NewPreOrderIterator[T any](root *Node[T]) *PreOrderIterator[T] {
+	i := &PreOrderIterator[T]{root: root}
+	i.Reset()
+	return i
+}
+
+func (i *PreOrderIterator[T]) Reset() {
+	i.resync()
+	i.Current = nil
+	i.stack = i.stack[:0]
+	if i.root != nil {
+		i.stack = append(i.stack, i.root)
+	}
+}
+
+func (i *PreOrderIterator[T]) MoveNext() bool {
+	if !i.check() {
+		return false
+	}
+	if len(i.stack) == 0 {
+		i.Current = nil
+		return false
+	}
+	n := i.stack[len(i.stack)-1]
+	i.stack = i.stack[:len(i.stack)-1]
+	if n.right != nil {
+		i.stack = append(i.stack, n.right)
+	}
+	if n.left != nil {
+		i.stack = append(i.stack, n.left)
+	}
+	i.Current = n
+	return true
+}
+
+func (i *PreOrderIterator[T]) Seq() iter.Seq2[*Node[T], error] {
+	return seq(i.MoveNext, func() *Node[T] { return i.Current }, i.Err)
+}
+
+// PostOrderIterator visits the left and right subtrees before the node.
+type PostOrderIterator[T any] struct {
+	Current  *Node[T]
+	root     *Node[T]
+	stack    []*Node[T]
+	returned *Node[T]
+	modGuard[T]
+}
+
+func NewPostOrderIterator[T any](root *Node[T]) *PostOrderIterator[T] {
+	i := &PostOrderIterator[T]{root: root}
+	i.Reset()
+	return i
+}
+
+func (i *PostOrderIterator[T]) Reset() {
+	i.resync()
+	i.Current = nil
+	i.returned = nil
+	i.stack = i.stack[:0]
+	i.pushLeftPath(i.root)
+}
+
+// pushLeftPath descends from n, preferring left children, until it reaches a
+// leaf, which is the first node of the subtree in post-order.
+func (i *PostOrderIterator[T]) pushLeftPath(n *Node[T]) {
+	for n != nil {
+		i.stack = append(i.stack, n)
+		if n.left != nil {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+}
+
+func (i *PostOrderIterator[T]) MoveNext() bool {
+	if !i.check() {
+		return false
+	}
+	if len(i.stack) == 0 {
+		i.Current = nil
+		return false
+	}
+	n := i.stack[len(i.stack)-1]
+	i.stack = i.stack[:len(i.stack)-1]
+	if len(i.stack) > 0 {
+		parent := i.stack[len(i.stack)-1]
+		if parent.left == n && parent.right != nil {
+			i.pushLeftPath(parent.right)
+		}
+	}
+	i.Current = n
+	return true
+}
+
+func (i *PostOrderIterator[T]) Seq() iter.Seq2[*Node[T], error] {
+	return seq(i.MoveNext, func() *Node[T] { return i.Current }, i.Err)
+}
+
+// LevelOrderIterator visits the tree breadth-first, one level at a time from
+// left to right.
+type LevelOrderIterator[T any] struct {
+	Current *Node[T]
+	root    *Node[T]
+	queue   []*Node[T]
+	modGuard[T]
+}
+
+func NewLevelOrderIterator[T any](root *Node[T]) *LevelOrderIterator[T] {
+	i := &LevelOrderIterator[T]{root: root}
+	i.Reset()
+	return i
+}
+
+func (i *LevelOrderIterator[T]) Reset() {
+	i.resync()
+	i.Current = nil
+	i.queue = i.queue[:0]
+	if i.root != nil {
+		i.queue = append(i.queue, i.root)
+	}
+}
+
+func (i *LevelOrderIterator[T]) MoveNext() bool {
+	if !i.check() {
+		return false
+	}
+	if len(i.queue) == 0 {
+		i.Current = nil
+		return false
+	}
+	n := i.queue[0]
+	i.queue = i.queue[1:]
+	if n.left != nil {
+		i.queue = append(i.queue, n.left)
+	}
+	if n.right != nil {
+		i.queue = append(i.queue, n.right)
+	}
+	i.Current = n
+	return true
+}
+
+func (i *LevelOrderIterator[T]) Seq() iter.Seq2[*Node[T], error] {
+	return seq(i.MoveNext, func() *Node[T] { return i.Current }, i.Err)
+}
+
+// ReverseInOrderIterator visits the right subtree, the node and then the left
+// subtree, which is an in-order traversal backwards.
+type ReverseInOrderIterator[T any] struct {
+	Current       *Node[T]
+	root          *Node[T]
+	returnedStart bool
+	modGuard[T]
+}
+
+func NewReverseInOrderIterator[T any](root *Node[T]) *ReverseInOrderIterator[T] {
+	i := &ReverseInOrderIterator[T]{root: root}
+	i.Reset()
+	return i
+}
+
+func (i *ReverseInOrderIterator[T]) Reset() {
+	i.resync()
+	i.Current = i.root
+	i.returnedStart = false
+	for i.Current != nil && i.Current.right != nil {
+		i.Current = i.Current.right
+	}
+}
+
+func (i *ReverseInOrderIterator[T]) MoveNext() bool {
+	if !i.check() {
+		return false
+	}
+	if i.Current == nil {
+		return false
+	}
+	if !i.returnedStart {
+		i.returnedStart = true
+		return true
+	}
+
+	if i.Current.left != nil {
+		i.Current = i.Current.left
+		for i.Current.right != nil {
+			i.Current = i.Current.right
+		}
+		return true
+	}
+	p := i.Current.parent
+	for p != nil && i.Current == p.left {
+		i.Current = p
+		p = p.parent
+	}
+	i.Current = p
+	return i.Current != nil
+}
+
+func (i *ReverseInOrderIterator[T]) Seq() iter.Seq2[*Node[T], error] {
+	return seq(i.MoveNext, func() *Node[T] { return i.Current }, i.Err)
+}
+
+// DepthLimitedIterator is a pre-order traversal that skips every node below
+// maxDepth. Depth holds the depth of Current, counting the root as 0.
+type DepthLimitedIterator[T any] struct {
+	Current  *Node[T]
+	Depth    int
+	root     *Node[T]
+	maxDepth int
+	stack    []depthNode[T]
+	modGuard[T]
+}
+
+type depthNode[T any] struct {
+	node  *Node[T]
+	depth int
+}
+
+func NewDepthLimitedIterator[T any](root *Node[T], maxDepth int) *DepthLimitedIterator[T] {
+	i := &DepthLimitedIterator[T]{root: root, maxDepth: maxDepth}
+	i.Reset()
+	return i
+}
+
+func (i *DepthLimitedIterator[T]) Reset() {
+	i.resync()
+	i.Current = nil
+	i.Depth = 0
+	i.stack = i.stack[:0]
+	if i.root != nil && i.maxDepth >= 0 {
+		i.stack = append(i.stack, depthNode[T]{i.root, 0})
+	}
+}
+
+func (i *DepthLimitedIterator[T]) MoveNext() bool {
+	if !i.check() {
+		return false
+	}
+	if len(i.stack) == 0 {
+		i.Current = nil
+		return false
+	}
+	top := i.stack[len(i.stack)-1]
+	i.stack = i.stack[:len(i.stack)-1]
+	if top.depth < i.maxDepth {
+		if top.node.right != nil {
+			i.stack = append(i.stack, depthNode[T]{top.node.right, top.depth + 1})
+		}
+		if top.node.left != nil {
+			i.stack = append(i.stack, depthNode[T]{top.node.left, top.depth + 1})
+		}
+	}
+	i.Current, i.Depth = top.node, top.depth
+	return true
+}
+
+func (i *DepthLimitedIterator[T]) Seq() iter.Seq2[*Node[T], error] {
+	return seq(i.MoveNext, func() *Node[T] { return i.Current }, i.Err)
+}
+
+// seq builds an iter.Seq2 out of any MoveNext/Current iterator. Every node
+// comes with a nil error; if err reports an error once MoveNext stops, a
+// last pair holds a nil node and the error.
+func seq[T any](moveNext func() bool, current func() *Node[T], err func() error) iter.Seq2[*Node[T], error] {
+	return func(yield func(*Node[T], error) bool) {
+		for moveNext() {
+			if !yield(current(), nil) {
+				return
+			}
+		}
+		if err := err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
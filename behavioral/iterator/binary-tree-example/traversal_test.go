@@ -0,0 +1,100 @@
+package main
+
+import (
+	"iter"
+	"slices"
+	"testing"
+)
+
+// newFullTree builds
+//
+//	   4
+//	 2   6
+//	1 3 5 7
+func newFullTree() *BinaryTree[int] {
+	return NewBinaryTree(NewNode(4,
+		NewNode(2, NewTerminalNode(1), NewTerminalNode(3)),
+		NewNode(6, NewTerminalNode(5), NewTerminalNode(7)),
+	))
+}
+
+type resettable interface {
+	Reset()
+	Seq() iter.Seq2[*Node[int], error]
+}
+
+// values gathers the node values of s, and stops at the first error.
+func values(s iter.Seq2[*Node[int], error]) []int {
+	var result []int
+	for n, err := range s {
+		if err != nil {
+			break
+		}
+		result = append(result, n.Value)
+	}
+	return result
+}
+
+func TestTraversals(t *testing.T) {
+	tree := newFullTree()
+	tests := []struct {
+		name     string
+		it       resettable
+		expected []int
+	}{
+		{"in-order", tree.InOrder(), []int{1, 2, 3, 4, 5, 6, 7}},
+		{"pre-order", tree.PreOrder(), []int{4, 2, 1, 3, 6, 5, 7}},
+		{"post-order", tree.PostOrder(), []int{1, 3, 2, 5, 7, 6, 4}},
+		{"level-order", tree.LevelOrder(), []int{4, 2, 6, 1, 3, 5, 7}},
+		{"reverse in-order", tree.ReverseInOrder(), []int{7, 6, 5, 4, 3, 2, 1}},
+		{"depth-limited", tree.DepthLimited(1), []int{4, 2, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := values(tt.it.Seq()); !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v but got %v", tt.expected, got)
+			}
+			tt.it.Reset()
+			if got := values(tt.it.Seq()); !slices.Equal(got, tt.expected) {
+				t.Errorf("After Reset expected %v but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInOrderIterator_ResetMidway(t *testing.T) {
+	i := newFullTree().InOrder()
+	i.MoveNext()
+	i.MoveNext()
+	i.Reset()
+	if !i.MoveNext() || i.Current.Value != 1 {
+		t.Errorf("Expected the leftmost node after Reset, got %d", i.Current.Value)
+	}
+}
+
+func TestDepthLimitedIterator_Depth(t *testing.T) {
+	var depths []int
+	for i := newFullTree().DepthLimited(2); i.MoveNext(); {
+		depths = append(depths, i.Depth)
+	}
+	expected := []int{0, 1, 2, 2, 1, 2, 2}
+	if !slices.Equal(depths, expected) {
+		t.Errorf("Expected %v but got %v", expected, depths)
+	}
+
+	if got := values(newFullTree().DepthLimited(-1).Seq()); len(got) != 0 {
+		t.Errorf("Expected no nodes for a negative depth, got %v", got)
+	}
+}
+
+func TestTraversals_EmptyTree(t *testing.T) {
+	tree := NewBinaryTree[int](nil)
+	for _, it := range []resettable{
+		tree.InOrder(), tree.PreOrder(), tree.PostOrder(),
+		tree.LevelOrder(), tree.ReverseInOrder(), tree.DepthLimited(3),
+	} {
+		if got := values(it.Seq()); len(got) != 0 {
+			t.Errorf("Expected no nodes, got %v", got)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module iterator-lazy
+
+go 1.23.6
@@ -0,0 +1,192 @@
+// Package lazy has generic combinators over iter.Seq. Every combinator
+// takes and returns an iter.Seq and pulls values from its source only when
+// the consumer asks for the next one, so they are safe to use with infinite
+// or very large sources. A count or size of n <= 0 is taken as 0.
+package lazy
+
+import "iter"
+
+// FromNext adapts a GetNext-style iterator, such as EmployeeIterator.GetNext.
+func FromNext[T any](next func() (T, bool)) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for {
+			v, ok := next()
+			if !ok || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// FromMoveNext adapts a MoveNext/Current iterator, such as InOrderIterator.
+func FromMoveNext[T any](moveNext func() bool, current func() T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for moveNext() {
+			if !yield(current()) {
+				return
+			}
+		}
+	}
+}
+
+// Naturals is an infinite source: 0, 1, 2, ...
+func Naturals() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; yield(i); i++ {
+		}
+	}
+}
+
+func Map[T, R any](seq iter.Seq[T], f func(T) R) iter.Seq[R] {
+	return func(yield func(R) bool) {
+		for v := range seq {
+			if !yield(f(v)) {
+				return
+			}
+		}
+	}
+}
+
+func Filter[T any](seq iter.Seq[T], keep func(T) bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range seq {
+			if keep(v) && !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Take yields at most n values.
+func Take[T any](seq iter.Seq[T], n int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		if n <= 0 {
+			return
+		}
+		taken := 0
+		for v := range seq {
+			if !yield(v) {
+				return
+			}
+			taken++
+			if taken == n {
+				return
+			}
+		}
+	}
+}
+
+// TakeWhile yields values until the first one that fails the predicate.
+func TakeWhile[T any](seq iter.Seq[T], pred func(T) bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for v := range seq {
+			if !pred(v) || !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Skip drops the first n values.
+func Skip[T any](seq iter.Seq[T], n int) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		skipped := 0
+		for v := range seq {
+			if skipped < n {
+				skipped++
+				continue
+			}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Zip pairs up the values of a and b and stops with the shorter one.
+func Zip[A, B any](a iter.Seq[A], b iter.Seq[B]) iter.Seq2[A, B] {
+	return func(yield func(A, B) bool) {
+		next, stop := iter.Pull(b)
+		defer stop()
+		for va := range a {
+			vb, ok := next()
+			if !ok || !yield(va, vb) {
+				return
+			}
+		}
+	}
+}
+
+// Chain yields every value of each sequence in turn.
+func Chain[T any](seqs ...iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for _, seq := range seqs {
+			for v := range seq {
+				if !yield(v) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Chunk groups values into slices of size n; the last one may be shorter.
+// Each chunk is a new slice, so it may be kept by the consumer. A size of 0
+// yields no chunks.
+func Chunk[T any](seq iter.Seq[T], n int) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		if n <= 0 {
+			return
+		}
+		chunk := make([]T, 0, n)
+		for v := range seq {
+			chunk = append(chunk, v)
+			if len(chunk) == n {
+				if !yield(chunk) {
+					return
+				}
+				chunk = make([]T, 0, n)
+			}
+		}
+		if len(chunk) > 0 {
+			yield(chunk)
+		}
+	}
+}
+
+// Distinct drops values that were already yielded. It remembers every value
+// it has seen, so memory grows with the number of distinct values.
+func Distinct[T comparable](seq iter.Seq[T]) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		seen := map[T]struct{}{}
+		for v := range seq {
+			if _, ok := seen[v]; ok {
+				continue
+			}
+			seen[v] = struct{}{}
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+// Reduce folds the sequence into a single value. It consumes the whole
+// sequence, so it must not be used on infinite sources.
+func Reduce[T, R any](seq iter.Seq[T], initial R, f func(R, T) R) R {
+	acc := initial
+	for v := range seq {
+		acc = f(acc, v)
+	}
+	return acc
+}
+
+// Collect gathers the sequence into a slice. Like Reduce, it consumes the
+// whole sequence.
+func Collect[T any](seq iter.Seq[T]) []T {
+	var result []T
+	for v := range seq {
+		result = append(result, v)
+	}
+	return result
+}
@@ -0,0 +1,100 @@
+package lazy
+
+import (
+	"iter"
+	"slices"
+	"testing"
+)
+
+func values(vs ...int) iter.Seq[int] {
+	return slices.Values(vs)
+}
+
+// counting wraps seq and records how many values were pulled from it.
+func counting(seq iter.Seq[int], pulled *int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for v := range seq {
+			*pulled++
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      iter.Seq[int]
+		expected []int
+	}{
+		{"Map", Map(values(1, 2, 3), func(v int) int { return v * 10 }), []int{10, 20, 30}},
+		{"Filter", Filter(values(1, 2, 3, 4), func(v int) bool { return v%2 == 0 }), []int{2, 4}},
+		{"Take", Take(values(1, 2, 3), 2), []int{1, 2}},
+		{"Take zero", Take(values(1, 2, 3), 0), nil},
+		{"TakeWhile", TakeWhile(values(1, 2, 5, 1), func(v int) bool { return v < 3 }), []int{1, 2}},
+		{"Skip", Skip(values(1, 2, 3), 2), []int{3}},
+		{"Skip past the end", Skip(values(1, 2, 3), 5), nil},
+		{"Skip negative", Skip(values(1, 2, 3), -1), []int{1, 2, 3}},
+		{"Chain", Chain(values(1), values(), values(2, 3)), []int{1, 2, 3}},
+		{"Distinct", Distinct(values(1, 2, 1, 3, 2)), []int{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Collect(tt.got); !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v but got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestZip(t *testing.T) {
+	var as, bs []int
+	for a, b := range Zip(values(1, 2, 3), values(10, 20)) {
+		as = append(as, a)
+		bs = append(bs, b)
+	}
+	if !slices.Equal(as, []int{1, 2}) || !slices.Equal(bs, []int{10, 20}) {
+		t.Errorf("Unexpected pairs %v %v", as, bs)
+	}
+}
+
+func TestChunk(t *testing.T) {
+	var got [][]int
+	for c := range Chunk(values(1, 2, 3, 4, 5), 2) {
+		got = append(got, c)
+	}
+	expected := [][]int{{1, 2}, {3, 4}, {5}}
+	if !slices.EqualFunc(got, expected, slices.Equal[[]int]) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+
+	for _, n := range []int{0, -1} {
+		if got := Collect(Chunk(values(1, 2), n)); len(got) != 0 {
+			t.Errorf("Expected no chunks of size %d, got %v", n, got)
+		}
+	}
+}
+
+func TestReduce(t *testing.T) {
+	sum := Reduce(values(1, 2, 3), 0, func(acc, v int) int { return acc + v })
+	if sum != 6 {
+		t.Errorf("Expected 6 but got %d", sum)
+	}
+}
+
+func TestLaziness(t *testing.T) {
+	pulled := 0
+	pipeline := Take(Filter(Map(counting(Naturals(), &pulled), func(v int) int { return v * 3 }),
+		func(v int) bool { return v%2 == 0 }), 3)
+
+	if pulled != 0 {
+		t.Fatalf("Nothing should be pulled before iterating, pulled %d", pulled)
+	}
+	if got := Collect(pipeline); !slices.Equal(got, []int{0, 6, 12}) {
+		t.Errorf("Unexpected values %v", got)
+	}
+	if pulled != 5 {
+		t.Errorf("Expected 5 values pulled from the source, pulled %d", pulled)
+	}
+}
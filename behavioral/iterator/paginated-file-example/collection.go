@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrFileChanged is returned when the file is not the one a cursor or a
+	// running iterator was made for.
+	ErrFileChanged = errors.New("file changed")
+)
+
+type Format int
+
+const (
+	JSONLines Format = iota
+	CSV
+)
+
+// Cursor is an opaque position in a FileCollection. It can be stored and
+// passed to Resume later to continue where an iterator stopped. It is only
+// valid for the same file, as it was when the cursor was made.
+type Cursor string
+
+type cursorState struct {
+	Format  Format      `json:"f"`
+	Version fileVersion `json:"v"`
+	Offset  int64       `json:"o"`
+}
+
+// fileVersion tells a file apart from another file, or from the same file
+// after it was rewritten.
+type fileVersion struct {
+	Size    int64 `json:"s"`
+	ModTime int64 `json:"m"` // in nanoseconds
+}
+
+func versionOf(info os.FileInfo) fileVersion {
+	return fileVersion{info.Size(), info.ModTime().UnixNano()}
+}
+
+func encodeCursor(format Format, version fileVersion, offset int64) Cursor {
+	b, _ := json.Marshal(cursorState{format, version, offset})
+	return Cursor(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func decodeCursor(c Cursor, format Format) (cursorState, error) {
+	b, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil {
+		return cursorState{}, ErrInvalidCursor
+	}
+	var s cursorState
+	if err := json.Unmarshal(b, &s); err != nil || s.Format != format || s.Offset < 0 || s.Offset > s.Version.Size {
+		return cursorState{}, ErrInvalidCursor
+	}
+	return s, nil
+}
+
+// FileCollection is an IterableCollection backed by a JSON-lines or CSV
+// file. Records are read a page at a time, so the file is never held in
+// memory as a whole.
+type FileCollection struct {
+	path     string
+	format   Format
+	pageSize int
+	prefetch bool
+}
+
+// NewFileCollection reads pageSize records per page. With prefetch set, the
+// next page is read in the background while the current one is iterated.
+func NewFileCollection(path string, format Format, pageSize int, prefetch bool) *FileCollection {
+	if pageSize < 1 {
+		pageSize = 1
+	}
+	return &FileCollection{path: path, format: format, pageSize: pageSize, prefetch: prefetch}
+}
+
+func (c *FileCollection) CreateIterator() Iterator[Person] {
+	return c.Iterate()
+}
+
+// Iterate is CreateIterator with access to Err, Cursor and Close. An error
+// opening the file is reported by Err.
+func (c *FileCollection) Iterate() *PageIterator {
+	src, err := c.open()
+	if err != nil {
+		return &PageIterator{collection: c, err: err, current: page{eof: true}}
+	}
+	return newPageIterator(c, src, 0)
+}
+
+// Resume returns an iterator that continues from a cursor obtained from
+// PageIterator.Cursor. A cursor made for another file, or for this file
+// before it changed, is an ErrFileChanged.
+func (c *FileCollection) Resume(cursor Cursor) (*PageIterator, error) {
+	state, err := decodeCursor(cursor, c.format)
+	if err != nil {
+		return nil, err
+	}
+	src, err := c.open()
+	if err != nil {
+		return nil, err
+	}
+	if src.version != state.Version {
+		src.file.Close()
+		return nil, ErrFileChanged
+	}
+	return newPageIterator(c, src, state.Offset), nil
+}
+
+// source is the file an iterator reads its pages from. It stays open from
+// the first page to the last.
+type source struct {
+	file    *os.File
+	version fileVersion
+	// for CSV: where the records start, after the header
+	dataStart  int64
+	columns    int
+	nameColumn int
+}
+
+func (c *FileCollection) open() (*source, error) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	src := &source{file: f, version: versionOf(info)}
+	if c.format == CSV {
+		if err := src.readHeader(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return src, nil
+}
+
+func (s *source) readHeader() error {
+	header := csv.NewReader(io.NewSectionReader(s.file, 0, s.version.Size))
+	columns, err := header.Read()
+	if err != nil {
+		return fmt.Errorf("reading CSV header: %w", err)
+	}
+	s.nameColumn = slices.Index(columns, "name")
+	if s.nameColumn < 0 {
+		return errors.New(`CSV header has no "name" column`)
+	}
+	s.columns = len(columns)
+	s.dataStart = header.InputOffset()
+	return nil
+}
+
+type page struct {
+	records []Person
+	ends    []int64 // file offset right after each record
+	start   int64
+	next    int64
+	eof     bool
+	err     error
+}
+
+// readPage reads up to pageSize records starting at offset.
+func (c *FileCollection) readPage(src *source, offset int64) page {
+	p := page{start: offset, next: offset}
+	info, err := src.file.Stat()
+	if err != nil {
+		p.err = err
+		return p
+	}
+	if versionOf(info) != src.version {
+		p.err = ErrFileChanged
+		return p
+	}
+	if c.format == CSV {
+		if p.start == 0 {
+			p.start = src.dataStart
+			p.next = p.start
+		}
+		return c.readCSVPage(src, p)
+	}
+	return c.readJSONPage(src, p)
+}
+
+func (c *FileCollection) readJSONPage(src *source, p page) page {
+	r := bufio.NewReader(io.NewSectionReader(src.file, p.start, src.version.Size-p.start))
+	for len(p.records) < c.pageSize {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			recordStart := p.next
+			p.next += int64(len(line))
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				var rec struct {
+					Name string `json:"name"`
+				}
+				if err := json.Unmarshal(line, &rec); err != nil {
+					p.err = fmt.Errorf("record at offset %d: %w", recordStart, err)
+					return p
+				}
+				p.records = append(p.records, &Employee{rec.Name})
+				p.ends = append(p.ends, p.next)
+			}
+		}
+		if err == io.EOF {
+			p.eof = true
+			return p
+		}
+		if err != nil {
+			p.err = err
+			return p
+		}
+	}
+	return p
+}
+
+func (c *FileCollection) readCSVPage(src *source, p page) page {
+	r := csv.NewReader(io.NewSectionReader(src.file, p.start, src.version.Size-p.start))
+	r.FieldsPerRecord = src.columns
+	for len(p.records) < c.pageSize {
+		row, err := r.Read()
+		if err == io.EOF {
+			p.eof = true
+			return p
+		}
+		if err != nil {
+			p.err = err
+			return p
+		}
+		p.next = p.start + r.InputOffset()
+		p.records = append(p.records, &Employee{row[src.nameColumn]})
+		p.ends = append(p.ends, p.next)
+	}
+	return p
+}
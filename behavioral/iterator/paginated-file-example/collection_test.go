@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func jsonLines(n int) string {
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "{\"name\": \"employee-%d\"}\n", i)
+	}
+	return b.String()
+}
+
+func expectedNames(from, to int) []string {
+	var result []string
+	for i := from; i < to; i++ {
+		result = append(result, fmt.Sprintf("employee-%d", i))
+	}
+	return result
+}
+
+func drain(t *testing.T, it *PageIterator) []string {
+	t.Helper()
+	var result []string
+	for it.HasMore() {
+		next, ok := it.GetNext()
+		if !ok {
+			t.Fatal("GetNext failed although HasMore returned true")
+		}
+		result = append(result, next.Name())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestFileCollection_Pages(t *testing.T) {
+	path := writeFile(t, "employees.jsonl", jsonLines(10))
+	for _, prefetch := range []bool{false, true} {
+		for _, pageSize := range []int{1, 3, 10, 20} {
+			got := drain(t, NewFileCollection(path, JSONLines, pageSize, prefetch).Iterate())
+			if !slices.Equal(got, expectedNames(0, 10)) {
+				t.Errorf("pageSize %d, prefetch %v: unexpected records %v", pageSize, prefetch, got)
+			}
+		}
+	}
+}
+
+func TestFileCollection_Resume(t *testing.T) {
+	path := writeFile(t, "employees.jsonl", jsonLines(10))
+	for _, prefetch := range []bool{false, true} {
+		collection := NewFileCollection(path, JSONLines, 3, prefetch)
+		it := collection.Iterate()
+		for range 4 {
+			it.GetNext()
+		}
+
+		resumed, err := collection.Resume(it.Cursor())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := drain(t, resumed); !slices.Equal(got, expectedNames(4, 10)) {
+			t.Errorf("prefetch %v: unexpected records after resume %v", prefetch, got)
+		}
+	}
+}
+
+func TestFileCollection_CSV(t *testing.T) {
+	path := writeFile(t, "employees.csv", "id,name\n1,Leonard\n2,\"Raphael\nthe second\"\n3,Donatello\n")
+	collection := NewFileCollection(path, CSV, 2, true)
+
+	it := collection.Iterate()
+	it.GetNext()
+	cursor := it.Cursor()
+	if got := drain(t, it); !slices.Equal(got, []string{"Raphael\nthe second", "Donatello"}) {
+		t.Errorf("Unexpected records %v", got)
+	}
+
+	resumed, err := collection.Resume(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(t, resumed); !slices.Equal(got, []string{"Raphael\nthe second", "Donatello"}) {
+		t.Errorf("Unexpected records after resume %v", got)
+	}
+}
+
+func TestFileCollection_InvalidCursor(t *testing.T) {
+	path := writeFile(t, "employees.jsonl", jsonLines(2))
+	collection := NewFileCollection(path, JSONLines, 1, false)
+
+	if _, err := collection.Resume("not a cursor"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+
+	csvCursor := NewFileCollection(path, CSV, 1, false).Iterate().Cursor()
+	if _, err := collection.Resume(csvCursor); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a cursor of another format, got %v", err)
+	}
+}
+
+func TestFileCollection_MalformedRecord(t *testing.T) {
+	path := writeFile(t, "employees.jsonl", "{\"name\": \"Leonard\"}\nnot json\n{\"name\": \"Raphael\"}\n")
+	it := NewFileCollection(path, JSONLines, 5, false).Iterate()
+
+	var got []string
+	for it.HasMore() {
+		next, _ := it.GetNext()
+		got = append(got, next.Name())
+	}
+	if !slices.Equal(got, []string{"Leonard"}) {
+		t.Errorf("Expected the records before the error, got %v", got)
+	}
+	if it.Err() == nil {
+		t.Error("Expected an error for the malformed record")
+	}
+}
+
+func TestFileCollection_CursorForAnotherFile(t *testing.T) {
+	path := writeFile(t, "employees.jsonl", jsonLines(10))
+	it := NewFileCollection(path, JSONLines, 3, false).Iterate()
+	it.GetNext()
+	cursor := it.Cursor()
+	it.Close()
+
+	other := writeFile(t, "others.jsonl", jsonLines(12))
+	if _, err := NewFileCollection(other, JSONLines, 3, false).Resume(cursor); !errors.Is(err, ErrFileChanged) {
+		t.Errorf("Expected ErrFileChanged for another file, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(jsonLines(11)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileCollection(path, JSONLines, 3, false).Resume(cursor); !errors.Is(err, ErrFileChanged) {
+		t.Errorf("Expected ErrFileChanged for a rewritten file, got %v", err)
+	}
+}
+
+func TestFileCollection_ChangedDuringIteration(t *testing.T) {
+	path := writeFile(t, "employees.jsonl", jsonLines(10))
+	it := NewFileCollection(path, JSONLines, 3, false).Iterate()
+	defer it.Close()
+	it.GetNext()
+
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(jsonLines(1))
+	f.Close()
+
+	var got []string
+	for it.HasMore() {
+		next, _ := it.GetNext()
+		got = append(got, next.Name())
+	}
+	if !slices.Equal(got, expectedNames(1, 3)) || !errors.Is(it.Err(), ErrFileChanged) {
+		t.Errorf("Expected the rest of the first page and ErrFileChanged, got %v, %v", got, it.Err())
+	}
+}
+
+func TestFileCollection_Close(t *testing.T) {
+	path := writeFile(t, "employees.jsonl", jsonLines(10))
+	it := NewFileCollection(path, JSONLines, 3, true).Iterate()
+	it.GetNext()
+	if err := it.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := drain(t, it); !slices.Equal(got, expectedNames(1, 3)) {
+		t.Errorf("Expected only the rest of the current page after Close, got %v", got)
+	}
+	if _, err := NewFileCollection(path, JSONLines, 3, true).Resume(it.Cursor()); err != nil {
+		t.Errorf("Expected the cursor to work after Close, got %v", err)
+	}
+
+	missing := NewFileCollection(filepath.Join(t.TempDir(), "missing"), JSONLines, 3, false).Iterate()
+	if missing.HasMore() || !errors.Is(missing.Err(), os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", missing.Err())
+	}
+}
@@ -0,0 +1,3 @@
+module iterator-paginated-file
+
+go 1.23.6
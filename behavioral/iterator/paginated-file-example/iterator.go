@@ -0,0 +1,103 @@
+package main
+
+// PageIterator walks a FileCollection page by page. A reading error stops the
+// iteration after the records read before it, and is reported by Err.
+//
+// The file stays open until the last page has been read. Call Close to
+// release it when the iterator is abandoned before that.
+type PageIterator struct {
+	collection *FileCollection
+	source     *source
+	version    fileVersion
+	current    page
+	position   int
+	pending    chan page
+	err        error
+}
+
+func newPageIterator(c *FileCollection, src *source, offset int64) *PageIterator {
+	i := &PageIterator{collection: c, source: src, version: src.version}
+	i.current = c.readPage(src, offset)
+	i.afterFetch()
+	return i
+}
+
+// afterFetch records a read error and starts prefetching the following page.
+func (i *PageIterator) afterFetch() {
+	if i.current.err != nil {
+		i.err = i.current.err
+		i.release()
+		return
+	}
+	if i.current.eof {
+		i.release()
+		return
+	}
+	if i.collection.prefetch {
+		src, next := i.source, i.current.next
+		i.pending = make(chan page, 1)
+		go func(pending chan<- page) {
+			pending <- i.collection.readPage(src, next)
+		}(i.pending)
+	}
+}
+
+func (i *PageIterator) GetNext() (Person, bool) {
+	if !i.HasMore() {
+		return nil, false
+	}
+	result := i.current.records[i.position]
+	i.position++
+	return result, true
+}
+
+func (i *PageIterator) HasMore() bool {
+	for i.position == len(i.current.records) {
+		if i.err != nil || i.current.eof || i.source == nil {
+			return false
+		}
+		if i.pending != nil {
+			i.current = <-i.pending
+			i.pending = nil
+		} else {
+			i.current = i.collection.readPage(i.source, i.current.next)
+		}
+		i.position = 0
+		i.afterFetch()
+	}
+	return true
+}
+
+func (i *PageIterator) Err() error {
+	return i.err
+}
+
+// Close releases the file. The records of the current page can still be
+// read, and Cursor still works.
+func (i *PageIterator) Close() error {
+	return i.release()
+}
+
+// release waits for a prefetch in progress, and closes the file.
+func (i *PageIterator) release() error {
+	if i.pending != nil {
+		<-i.pending
+		i.pending = nil
+	}
+	if i.source == nil {
+		return nil
+	}
+	err := i.source.file.Close()
+	i.source = nil
+	return err
+}
+
+// Cursor points right after the last record returned by GetNext, whether or
+// not the following page has already been fetched.
+func (i *PageIterator) Cursor() Cursor {
+	offset := i.current.start
+	if i.position > 0 {
+		offset = i.current.ends[i.position-1]
+	}
+	return encodeCursor(i.collection.format, i.version, offset)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Model
+type Person interface {
+	Name() string
+}
+type Employee struct {
+	name string
+}
+
+func (e *Employee) Name() string {
+	return e.name
+}
+
+// iterator interface
+type Iterator[T any] interface {
+	GetNext() (T, bool)
+	HasMore() bool
+	// Err reports why GetNext and HasMore stopped early, or nil at the end
+	// of the collection.
+	Err() error
+}
+
+// iterable collection
+type IterableCollection interface {
+	CreateIterator() Iterator[Person]
+}
+
+func main() {
+	dir, err := os.MkdirTemp("", "employees")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "employees.jsonl")
+	content := `{"name": "Leonard"}
+{"name": "Raphael"}
+{"name": "Donatello"}
+{"name": "Michelangelo"}
+{"name": "Splinter"}
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		panic(err)
+	}
+
+	var collection IterableCollection = NewFileCollection(path, JSONLines, 2, true)
+	iterator := collection.CreateIterator()
+	for range 3 {
+		if next, exists := iterator.GetNext(); exists {
+			fmt.Println(next.Name())
+		}
+	}
+
+	// save the position and continue later
+	cursor := iterator.(*PageIterator).Cursor()
+	fmt.Println("cursor:", cursor)
+	iterator.(*PageIterator).Close()
+
+	resumed, err := NewFileCollection(path, JSONLines, 2, false).Resume(cursor)
+	if err != nil {
+		panic(err)
+	}
+	for resumed.HasMore() {
+		if next, exists := resumed.GetNext(); exists {
+			fmt.Println(next.Name())
+		}
+	}
+	if err := resumed.Err(); err != nil {
+		fmt.Println("error:", err)
+	}
+}
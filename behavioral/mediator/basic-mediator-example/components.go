@@ -0,0 +1,160 @@
+package main
+
+// Events
+type EVENT int
+
+const (
+	CLICK EVENT = iota
+	KEYPRESS
+	CHECK
+	UNCHECK
+	CHANGE
+)
+
+func (e EVENT) String() string {
+	switch e {
+	case CLICK:
+		return "click"
+	case KEYPRESS:
+		return "keypress"
+	case CHECK:
+		return "check"
+	case UNCHECK:
+		return "uncheck"
+	case CHANGE:
+		return "change"
+	}
+	return "unknown"
+}
+
+// Abstract component
+type Component interface {
+	Name() string
+	State() State
+	setDialog(dialog Mediator)
+	// set changes the state without notifying the dialog, so that rules do
+	// not trigger each other.
+	set(state State)
+}
+
+// State is a snapshot of a component. Fields that do not apply to a kind of
+// component are left zero.
+type State struct {
+	Enabled bool
+	Checked bool
+	Value   string
+	Error   string // validation message
+}
+
+// component is the state every component shares.
+type component struct {
+	name   string
+	dialog Mediator
+	state  State
+}
+
+func (c *component) Name() string {
+	return c.name
+}
+
+func (c *component) State() State {
+	return c.state
+}
+
+func (c *component) Enabled() bool {
+	return c.state.Enabled
+}
+
+func (c *component) setDialog(dialog Mediator) {
+	c.dialog = dialog
+}
+
+func (c *component) set(state State) {
+	c.state = state
+}
+
+// notify tells the dialog about the event, unless the component is
+// disabled: disabled components ignore the user.
+func (c *component) notify(sender Component, event EVENT) bool {
+	if !c.state.Enabled {
+		return false
+	}
+	if c.dialog != nil {
+		c.dialog.notify(sender, event)
+	}
+	return true
+}
+
+// Concrete component
+type Button struct {
+	component
+}
+
+func NewButtonComponent(name string) *Button {
+	return &Button{component{name: name, state: State{Enabled: true}}}
+}
+
+func (b *Button) click() {
+	b.notify(b, CLICK)
+}
+
+func (b *Button) keypress() {
+	b.notify(b, KEYPRESS)
+}
+
+type Checkbox struct {
+	component
+}
+
+func NewCheckboxComponent(name string) *Checkbox {
+	return &Checkbox{component{name: name, state: State{Enabled: true}}}
+}
+
+func (c *Checkbox) Checked() bool {
+	return c.state.Checked
+}
+
+func (c *Checkbox) check() {
+	c.setChecked(true)
+}
+
+func (c *Checkbox) uncheck() {
+	c.setChecked(false)
+}
+
+func (c *Checkbox) toggle() {
+	c.setChecked(!c.state.Checked)
+}
+
+func (c *Checkbox) setChecked(checked bool) {
+	if !c.state.Enabled || c.state.Checked == checked {
+		return
+	}
+	c.state.Checked = checked
+	event := UNCHECK
+	if checked {
+		event = CHECK
+	}
+	c.notify(c, event)
+}
+
+type TextField struct {
+	component
+}
+
+func NewTextFieldComponent(name string) *TextField {
+	return &TextField{component{name: name, state: State{Enabled: true}}}
+}
+
+func (t *TextField) Value() string {
+	return t.state.Value
+}
+
+// input replaces the text, as if the user typed it.
+func (t *TextField) input(text string) {
+	if !t.state.Enabled {
+		return
+	}
+	t.state.Value = text
+	t.notify(t, CHANGE)
+}
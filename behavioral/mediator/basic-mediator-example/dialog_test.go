@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func newTestDialog(t *testing.T) (*AuthenticationDialog, *bytes.Buffer) {
+	t.Helper()
+	d := NewAuthenticationDialog("Login")
+	var out bytes.Buffer
+	d.Out = &out
+	return d, &out
+}
+
+func expectDump(t *testing.T, d *RuleDialog, lines ...string) {
+	t.Helper()
+	expected := strings.Join(lines, "\n") + "\n"
+	if got := d.Dump(); got != expected {
+		t.Errorf("Expected state\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestAuthenticationDialog_Binding(t *testing.T) {
+	d, out := newTestDialog(t)
+	expectDump(t, d.RuleDialog,
+		`cancelBtn: enabled`,
+		`checkbox: enabled unchecked`,
+		`okBtn: disabled`,
+		`password: enabled ""`,
+		`username: enabled ""`)
+
+	d.username.input("john")
+	d.password.input("correct horse")
+	d.okBtn.click()
+	if out.Len() != 0 {
+		t.Errorf("A disabled button should ignore clicks, got %q", out)
+	}
+
+	d.checkbox.check()
+	if !d.okBtn.Enabled() {
+		t.Error("Expected okBtn to be enabled once the checkbox is checked")
+	}
+	d.okBtn.click()
+	if out.String() != "Login: logged in as john\n" {
+		t.Errorf("Unexpected output %q", out)
+	}
+
+	d.checkbox.toggle()
+	if d.okBtn.Enabled() {
+		t.Error("Expected okBtn to be disabled again")
+	}
+}
+
+func TestAuthenticationDialog_Validation(t *testing.T) {
+	d, out := newTestDialog(t)
+	d.checkbox.check()
+
+	// untouched fields show no errors until the user tries to submit
+	d.password.input("short")
+	expectDump(t, d.RuleDialog,
+		`cancelBtn: enabled`,
+		`checkbox: enabled checked`,
+		`okBtn: enabled`,
+		`password: enabled "short" error="at least 8 characters"`,
+		`username: enabled ""`)
+
+	d.okBtn.click()
+	if out.Len() != 0 {
+		t.Errorf("An invalid form should not be submitted, got %q", out)
+	}
+	if got := d.State("username").Error; got != "required" {
+		t.Errorf("Expected the username to be required, got %q", got)
+	}
+
+	d.password.input("")
+	if got := d.State("password").Error; got != "required" {
+		t.Errorf("Expected the first failing validation to be shown, got %q", got)
+	}
+}
+
+func TestAuthenticationDialog_Cancel(t *testing.T) {
+	d, out := newTestDialog(t)
+	d.checkbox.check()
+	d.username.input("john")
+	d.password.input("x")
+	d.cancelBtn.click()
+
+	expectDump(t, d.RuleDialog,
+		`cancelBtn: enabled`,
+		`checkbox: enabled unchecked`,
+		`okBtn: disabled`,
+		`password: enabled ""`,
+		`username: enabled ""`)
+	if out.String() != "Login: cancelled\n" {
+		t.Errorf("Unexpected output %q", out)
+	}
+}
+
+func TestNewRuleDialog_UnknownComponent(t *testing.T) {
+	ok := NewButtonComponent("ok")
+	for _, tc := range []struct {
+		rules       []Rule
+		bindings    []Binding
+		validations []Validation
+		err         string
+	}{
+		{rules: []Rule{{On: CLICK, From: "okBtn"}}, err: `unknown component "okBtn"`},
+		{rules: []Rule{{On: CLICK, Do: []Action{Clear("name")}}}, err: `unknown component "name"`},
+		{bindings: []Binding{{Enable: "ok", When: All(Checked("terms"))}}, err: `unknown component "terms"`},
+		{validations: []Validation{{Field: "ok", Check: Required}}, err: `"ok" is not a text field`},
+	} {
+		_, err := NewRuleDialog("Test", []Component{ok}, tc.rules, tc.bindings, tc.validations)
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("Expected an error with %q, got %v", tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module mediator-basic
+
+go 1.23.6
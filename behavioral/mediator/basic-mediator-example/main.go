@@ -1,111 +1,68 @@
 package main
 
-import (
-	"fmt"
-)
+import "fmt"
 
 // Mediator interface
 type Mediator interface {
 	notify(sender Component, event EVENT)
 }
 
-// Concrete mediator
+// Concrete mediator: the authentication dialog is nothing but its rules.
 type AuthenticationDialog struct {
-	title            string
+	*RuleDialog
 	okBtn, cancelBtn *Button
 	checkbox         *Checkbox
+	username         *TextField
+	password         *TextField
 }
 
-func NewAuthenticationDialog(title string, okBtn, cancelBtn *Button, checkbox *Checkbox) *AuthenticationDialog {
-	m := &AuthenticationDialog{
-		title: title, okBtn: okBtn,
-		cancelBtn: cancelBtn,
-		checkbox:  checkbox,
+func NewAuthenticationDialog(title string) *AuthenticationDialog {
+	a := &AuthenticationDialog{
+		okBtn:     NewButtonComponent("okBtn"),
+		cancelBtn: NewButtonComponent("cancelBtn"),
+		checkbox:  NewCheckboxComponent("checkbox"),
+		username:  NewTextFieldComponent("username"),
+		password:  NewTextFieldComponent("password"),
 	}
-	okBtn.setDialog(m)
-	cancelBtn.setDialog(m)
-	checkbox.setDialog(m)
-	return m
-}
-
-func (a *AuthenticationDialog) notify(sender Component, event EVENT) {
-	if sender == a.okBtn && event == CLICK {
-		fmt.Println("Ok Button clicked.")
+	rules := []Rule{
+		{On: CLICK, From: "okBtn", If: Valid(), Do: []Action{Print("logged in as %s", "username")}},
+		{On: CLICK, From: "okBtn", If: Not(Valid()), Do: []Action{ShowErrors()}},
+		{On: KEYPRESS, From: "okBtn", Do: []Action{Print("Ok Button key pressed.")}},
+		{On: CLICK, From: "cancelBtn", Do: []Action{Clear("username", "password", "checkbox"), Print("cancelled")}},
 	}
-	if sender == a.okBtn && event == KEYPRESS {
-		fmt.Println("Ok Button key pressed.")
+	bindings := []Binding{
+		// the terms have to be accepted first
+		{Enable: "okBtn", When: Checked("checkbox")},
 	}
-	if sender == a.cancelBtn && event == CLICK {
-		fmt.Println("Cancel Button clicked.")
+	validations := []Validation{
+		{Field: "username", Check: Required, Message: "required"},
+		{Field: "password", Check: Required, Message: "required"},
+		{Field: "password", Check: MinLength(8), Message: "at least 8 characters"},
 	}
-	if sender == a.cancelBtn && event == KEYPRESS {
-		fmt.Println("Cancel Button key pressed.")
+	dialog, err := NewRuleDialog(title, []Component{a.okBtn, a.cancelBtn, a.checkbox, a.username, a.password}, rules, bindings, validations)
+	if err != nil {
+		panic(err) // the rules above refer to the components above
 	}
-	if sender == a.checkbox && event == CHECK {
-		fmt.Println("Checkbox checked.")
-	}
-}
-
-// Events
-type EVENT int
-
-const (
-	CLICK EVENT = iota
-	KEYPRESS
-	CHECK
-)
-
-// Abstract component
-type Component interface {
-	setDialog(dialog Mediator)
+	a.RuleDialog = dialog
+	return a
 }
 
-// Concrete component
-type Button struct {
-	dialog Mediator
-}
-
-func NewButtonComponent() *Button {
-	return &Button{}
-}
-
-func (b *Button) setDialog(dialog Mediator) {
-	b.dialog = dialog
-}
-
-func (b *Button) click() {
-	b.dialog.notify(b, CLICK)
-}
-
-func (b *Button) keypress() {
-	b.dialog.notify(b, KEYPRESS)
-}
-
-type Checkbox struct {
-	dialog Mediator
-}
-
-func NewCheckboxComponent() *Checkbox {
-	return &Checkbox{}
-}
-
-func (c *Checkbox) check() {
-	c.dialog.notify(c, CHECK)
-}
+func main() {
+	dialog := NewAuthenticationDialog("AuthenticationDialog")
 
-func (c *Checkbox) setDialog(dialog Mediator) {
-	c.dialog = dialog
-}
+	// sandbox
+	dialog.okBtn.click() // disabled until the checkbox is checked
+	dialog.checkbox.check()
+	dialog.okBtn.click()
+	fmt.Print(dialog.Dump())
 
-func main() {
-	okBtn := NewButtonComponent()
-	cancelBtn := NewButtonComponent()
+	dialog.username.input("john")
+	dialog.password.input("secret")
+	fmt.Print(dialog.Dump())
 
-	checkbox := NewCheckboxComponent()
-	NewAuthenticationDialog("AuthenticationDialog", okBtn, cancelBtn, checkbox)
+	dialog.password.input("correct horse")
+	dialog.okBtn.click()
 
-	// sandbox
-	okBtn.click()
-	okBtn.keypress()
-	checkbox.check()
+	dialog.cancelBtn.click()
+	fmt.Print(dialog.Dump())
 }
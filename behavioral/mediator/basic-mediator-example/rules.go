@@ -0,0 +1,305 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Rule reacts to an event: when From sends On and If holds, the actions run
+// in order. An empty From matches every component, and a zero If always
+// holds.
+type Rule struct {
+	On   EVENT
+	From string
+	If   Condition
+	Do   []Action
+}
+
+// Binding keeps a component enabled exactly while the condition holds. The
+// bindings are evaluated after every event.
+type Binding struct {
+	Enable string
+	When   Condition
+}
+
+// Validation checks the value of a text field. Its message is shown on the
+// field once the user has changed it.
+type Validation struct {
+	Field   string
+	Check   func(value string) bool
+	Message string
+}
+
+// Condition and Action remember the components they refer to, so that the
+// dialog can check them when it is created.
+type Condition struct {
+	names []string
+	holds func(d *RuleDialog) bool
+}
+
+func (c Condition) check(d *RuleDialog) bool {
+	return c.holds == nil || c.holds(d)
+}
+
+type Action struct {
+	names []string
+	run   func(d *RuleDialog)
+}
+
+// RuleDialog is a mediator whose interactions are declared as data instead
+// of being written as code: the components only know the dialog, and the
+// dialog only knows the rules.
+type RuleDialog struct {
+	title       string
+	components  map[string]Component
+	rules       []Rule
+	bindings    []Binding
+	validations []Validation
+	touched     map[string]bool // fields the user changed
+	Out         io.Writer       // where Print writes, os.Stdout by default
+}
+
+// NewRuleDialog fails if a rule refers to a component it does not have.
+func NewRuleDialog(title string, components []Component, rules []Rule, bindings []Binding, validations []Validation) (*RuleDialog, error) {
+	d := &RuleDialog{
+		title:       title,
+		components:  make(map[string]Component),
+		rules:       rules,
+		bindings:    bindings,
+		validations: validations,
+		touched:     make(map[string]bool),
+		Out:         os.Stdout,
+	}
+	for _, c := range components {
+		if _, ok := d.components[c.Name()]; ok {
+			return nil, fmt.Errorf("%s: duplicate component %q", title, c.Name())
+		}
+		d.components[c.Name()] = c
+	}
+
+	var names []string
+	for _, r := range rules {
+		if r.From != "" {
+			names = append(names, r.From)
+		}
+		names = append(names, r.If.names...)
+		for _, a := range r.Do {
+			names = append(names, a.names...)
+		}
+	}
+	for _, b := range bindings {
+		names = append(names, b.Enable)
+		names = append(names, b.When.names...)
+	}
+	for _, v := range validations {
+		if _, ok := d.components[v.Field].(*TextField); !ok {
+			return nil, fmt.Errorf("%s: %q is not a text field", title, v.Field)
+		}
+	}
+	for _, name := range names {
+		if _, ok := d.components[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown component %q", title, name)
+		}
+	}
+
+	for _, c := range components {
+		c.setDialog(d)
+	}
+	d.update()
+	return d, nil
+}
+
+func (d *RuleDialog) notify(sender Component, event EVENT) {
+	if event == CHANGE {
+		d.touched[sender.Name()] = true
+	}
+	for _, r := range d.rules {
+		if r.On != event || (r.From != "" && r.From != sender.Name()) {
+			continue
+		}
+		if !r.If.check(d) {
+			continue
+		}
+		for _, action := range r.Do {
+			action.run(d)
+		}
+	}
+	d.update()
+}
+
+// update applies the bindings and the validation messages.
+func (d *RuleDialog) update() {
+	for _, name := range d.names() {
+		if _, ok := d.components[name].(*TextField); ok {
+			d.modify(name, func(s *State) { s.Error = "" })
+		}
+	}
+	for _, v := range d.validations {
+		if d.touched[v.Field] && !v.Check(d.components[v.Field].State().Value) {
+			d.modify(v.Field, func(s *State) {
+				if s.Error == "" {
+					s.Error = v.Message
+				}
+			})
+		}
+	}
+	for _, b := range d.bindings {
+		enabled := b.When.check(d)
+		d.modify(b.Enable, func(s *State) { s.Enabled = enabled })
+	}
+}
+
+func (d *RuleDialog) modify(name string, change func(*State)) {
+	c := d.components[name]
+	state := c.State()
+	change(&state)
+	c.set(state)
+}
+
+// Valid reports whether every validation passes, touched or not.
+func (d *RuleDialog) Valid() bool {
+	for _, v := range d.validations {
+		if !v.Check(d.components[v.Field].State().Value) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *RuleDialog) State(name string) State {
+	if c, ok := d.components[name]; ok {
+		return c.State()
+	}
+	return State{}
+}
+
+// Dump describes the state of every component, one per line in name order,
+// e.g. for comparing in tests.
+func (d *RuleDialog) Dump() string {
+	var b strings.Builder
+	for _, name := range d.names() {
+		s := d.components[name].State()
+		fmt.Fprintf(&b, "%s:", name)
+		if s.Enabled {
+			b.WriteString(" enabled")
+		} else {
+			b.WriteString(" disabled")
+		}
+		switch d.components[name].(type) {
+		case *Checkbox:
+			if s.Checked {
+				b.WriteString(" checked")
+			} else {
+				b.WriteString(" unchecked")
+			}
+		case *TextField:
+			fmt.Fprintf(&b, " %q", s.Value)
+		}
+		if s.Error != "" {
+			fmt.Fprintf(&b, " error=%q", s.Error)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (d *RuleDialog) names() []string {
+	names := make([]string, 0, len(d.components))
+	for name := range d.components {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Conditions
+
+func Checked(name string) Condition {
+	return Condition{[]string{name}, func(d *RuleDialog) bool { return d.State(name).Checked }}
+}
+
+func NotEmpty(name string) Condition {
+	return Condition{[]string{name}, func(d *RuleDialog) bool { return d.State(name).Value != "" }}
+}
+
+// Valid holds when every validation of the dialog passes.
+func Valid() Condition {
+	return Condition{nil, (*RuleDialog).Valid}
+}
+
+func Not(c Condition) Condition {
+	return Condition{c.names, func(d *RuleDialog) bool { return !c.check(d) }}
+}
+
+func All(conditions ...Condition) Condition {
+	var names []string
+	for _, c := range conditions {
+		names = append(names, c.names...)
+	}
+	return Condition{names, func(d *RuleDialog) bool {
+		for _, c := range conditions {
+			if !c.check(d) {
+				return false
+			}
+		}
+		return true
+	}}
+}
+
+// Actions
+
+// Clear empties the text fields and unchecks the checkboxes, and forgets
+// that the user changed them.
+func Clear(names ...string) Action {
+	return Action{names, func(d *RuleDialog) {
+		for _, name := range names {
+			d.modify(name, func(s *State) {
+				s.Value = ""
+				s.Checked = false
+			})
+			delete(d.touched, name)
+		}
+	}}
+}
+
+// ShowErrors shows the validation messages of every field, even the ones
+// the user has not changed yet.
+func ShowErrors() Action {
+	return Action{nil, func(d *RuleDialog) {
+		for _, v := range d.validations {
+			d.touched[v.Field] = true
+		}
+	}}
+}
+
+func SetValue(name, value string) Action {
+	return Action{[]string{name}, func(d *RuleDialog) {
+		d.modify(name, func(s *State) { s.Value = value })
+	}}
+}
+
+// Print writes a line to the dialog's Out. The values of the named text
+// fields fill in the format.
+func Print(format string, names ...string) Action {
+	return Action{names, func(d *RuleDialog) {
+		args := make([]any, len(names))
+		for i, name := range names {
+			args[i] = d.State(name).Value
+		}
+		fmt.Fprintf(d.Out, "%s: %s\n", d.title, fmt.Sprintf(format, args...))
+	}}
+}
+
+// Validations
+
+func Required(value string) bool {
+	return value != ""
+}
+
+func MinLength(n int) func(string) bool {
+	return func(value string) bool { return utf8.RuneCountInString(value) >= n }
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// WordFilter masks the words of its list, whatever their case, with
+// asterisks. Only whole words are masked.
+type WordFilter struct {
+	words map[string]bool
+}
+
+func NewWordFilter(words ...string) *WordFilter {
+	f := &WordFilter{words: make(map[string]bool)}
+	for _, w := range words {
+		f.words[strings.ToLower(w)] = true
+	}
+	return f
+}
+
+func (f *WordFilter) Name() string { return "words" }
+
+func (f *WordFilter) Check(s *Submission) error {
+	var b strings.Builder
+	word := -1 // start of the current word in s.Text
+	flush := func(end int) {
+		if word < 0 {
+			return
+		}
+		w := s.Text[word:end]
+		if f.words[strings.ToLower(w)] {
+			b.WriteString(strings.Repeat("*", utf8.RuneCountInString(w)))
+		} else {
+			b.WriteString(w)
+		}
+		word = -1
+	}
+	for i, r := range s.Text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if word < 0 {
+				word = i
+			}
+			continue
+		}
+		flush(i)
+		b.WriteRune(r)
+	}
+	flush(len(s.Text))
+	s.Text = b.String()
+	return nil
+}
+
+// RateLimiter is a token bucket per sender: a sender may send Burst
+// messages at once, and Rate messages per second after that.
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{Rate: rate, Burst: burst, buckets: make(map[string]*bucket)}
+}
+
+func (l *RateLimiter) Name() string { return "rate" }
+
+func (l *RateLimiter) Check(s *Submission) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[s.Sender]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: s.Time}
+		l.buckets[s.Sender] = b
+	}
+	if elapsed := s.Time.Sub(b.last); elapsed > 0 {
+		b.tokens = min(float64(l.Burst), b.tokens+elapsed.Seconds()*l.Rate)
+		b.last = s.Time
+	}
+	if b.tokens < 1 {
+		return ErrRateLimited
+	}
+	b.tokens--
+	return nil
+}
+
+func (l *RateLimiter) rename(old, nickname string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[old]; ok {
+		delete(l.buckets, old)
+		l.buckets[nickname] = b
+	}
+}
+
+// LengthLimit rejects messages of more than that many characters.
+type LengthLimit int
+
+func (l LengthLimit) Name() string { return "length" }
+
+func (l LengthLimit) Check(s *Submission) error {
+	if n := utf8.RuneCountInString(s.Text); n > int(l) {
+		return fmt.Errorf("%w (%d characters, at most %d)", ErrTooLong, n, int(l))
+	}
+	return nil
+}
@@ -0,0 +1,3 @@
+module mediator-chat-hub
+
+go 1.23.6
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotConnected     = errors.New("not connected to a chat hub")
+	ErrNicknameTaken    = errors.New("nickname already taken")
+	ErrUnknownRecipient = errors.New("unknown recipient")
+	ErrNotInRoom        = errors.New("not in room")
+)
+
+// The name used for join, leave and rename notices
+const hubSender = "Room"
+
+type ChatRoom struct {
+	name    string
+	people  []*Person
+	mu      sync.Mutex // guards history
+	history []Message
+}
+
+// broadcast addresses the message to everyone in the room but the sender,
+// and remembers it for people joining later.
+func (c *ChatRoom) broadcast(m Message, historyLimit int) deliveries {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out deliveries
+	for _, p := range c.people {
+		if p.Name != m.Sender {
+			out = out.add(p, m)
+		}
+	}
+	if historyLimit > 0 {
+		c.history = append(c.history, m)
+		if over := len(c.history) - historyLimit; over > 0 {
+			c.history = slices.Delete(c.history, 0, over)
+		}
+	}
+	return out
+}
+
+// deliveries are the messages a hub operation addressed. They are collected
+// while the hub is locked and posted once it is unlocked, since posting may
+// wait for room in a full mailbox.
+type deliveries []delivery
+
+type delivery struct {
+	to *mailbox
+	m  Message
+}
+
+func (d deliveries) add(p *Person, m Message) deliveries {
+	return append(d, delivery{p.mailbox, m})
+}
+
+func (d deliveries) post() {
+	for _, x := range d {
+		x.to.post(x.m)
+	}
+}
+
+func (c *ChatRoom) has(p *Person) bool {
+	return slices.Contains(c.people, p)
+}
+
+// ChatHub is the mediator between every Person and every ChatRoom. People are
+// only known to each other by nickname, through the hub. It is safe for
+// concurrent use: messages take a read lock, so rooms are independent of
+// each other, while joins, leaves and renames take the write lock.
+//
+// No lock is held while messages are posted to the mailboxes, so a receiver
+// that cannot keep up only holds up the senders posting to it. Messages
+// sent one after the other reach everyone in that order, but two messages
+// sent at the same time may reach different people in a different order.
+type ChatHub struct {
+	mu           sync.RWMutex
+	rooms        map[string]*ChatRoom
+	people       map[string]*Person
+	online       map[*Person]bool
+	pending      map[*Person][]Message
+	historyLimit int
+	mailbox      MailboxConfig
+	moderator    *Moderator
+}
+
+// NewChatHub replays up to historyLimit messages of a room to people joining
+// it. A limit of 0 disables history.
+func NewChatHub(historyLimit int) *ChatHub {
+	return &ChatHub{
+		rooms:        make(map[string]*ChatRoom),
+		people:       make(map[string]*Person),
+		online:       make(map[*Person]bool),
+		pending:      make(map[*Person][]Message),
+		historyLimit: historyLimit,
+		mailbox:      DefaultMailbox,
+	}
+}
+
+// SetMailbox configures the mailboxes of people connecting from now on.
+func (h *ChatHub) SetMailbox(config MailboxConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mailbox = config
+}
+
+// SetModerator runs every room and private message through m before it is
+// delivered. A nil m turns moderation off.
+func (h *ChatHub) SetModerator(m *Moderator) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.moderator = m
+}
+
+// Connect brings p online. The first Connect registers p's nickname;
+// private messages sent while p was away are delivered now.
+func (h *ChatHub) Connect(p *Person) error {
+	h.mu.Lock()
+	out, err := h.connect(p)
+	h.mu.Unlock()
+	out.post()
+	return err
+}
+
+func (h *ChatHub) connect(p *Person) (deliveries, error) {
+	if other, ok := h.people[p.Name]; ok && other != p {
+		return nil, fmt.Errorf("%w: %s", ErrNicknameTaken, p.Name)
+	}
+	if h.online[p] {
+		return nil, nil
+	}
+	h.people[p.Name] = p
+	h.online[p] = true
+	p.hub = h
+	var mb *mailbox
+	mb = newMailbox(h.mailbox, p.Receive, func() {
+		// post calls this holding mb.sending, which closing mb waits for
+		go h.kick(p, mb)
+	})
+	p.mailbox = mb
+
+	var out deliveries
+	for _, m := range h.pending[p] {
+		out = out.add(p, m)
+	}
+	delete(h.pending, p)
+	return out, nil
+}
+
+// Disconnect makes p leave every room. p keeps its nickname, and private
+// messages sent to it are kept until it connects again. Messages already in
+// p's mailbox are still delivered.
+func (h *ChatHub) Disconnect(p *Person) {
+	h.mu.Lock()
+	out := h.disconnect(p)
+	h.mu.Unlock()
+	out.post()
+}
+
+func (h *ChatHub) disconnect(p *Person) deliveries {
+	if !h.online[p] {
+		return nil
+	}
+	out := h.leaveAll(p)
+	h.online[p] = false
+	p.mailbox.close()
+	return out
+}
+
+// leaveAll makes p leave every room it is in.
+func (h *ChatHub) leaveAll(p *Person) deliveries {
+	var out deliveries
+	for _, name := range h.roomNames() {
+		if h.rooms[name].has(p) {
+			left, _ := h.leave(p, name)
+			out = append(out, left...)
+		}
+	}
+	return out
+}
+
+// kick disconnects a receiver whose mailbox mb could not keep up. It does
+// nothing if p has connected again with another mailbox since.
+func (h *ChatHub) kick(p *Person, mb *mailbox) {
+	h.mu.Lock()
+	if p.mailbox != mb {
+		h.mu.Unlock()
+		return
+	}
+	out := h.disconnect(p)
+	h.mu.Unlock()
+	out.post()
+	if p.onKick != nil {
+		p.onKick()
+	}
+}
+
+func (h *ChatHub) Join(p *Person, room string) error {
+	h.mu.Lock()
+	out, err := h.join(p, room)
+	h.mu.Unlock()
+	out.post()
+	return err
+}
+
+func (h *ChatHub) join(p *Person, room string) (deliveries, error) {
+	if !h.online[p] {
+		return nil, ErrNotConnected
+	}
+	if h.moderator != nil && h.moderator.Banned(p.Name) {
+		return nil, ErrBanned
+	}
+	r, ok := h.rooms[room]
+	if !ok {
+		r = &ChatRoom{name: room}
+		h.rooms[room] = r
+	}
+	if r.has(p) {
+		return nil, nil
+	}
+
+	var out deliveries
+	for _, m := range r.history {
+		out = out.add(p, m)
+	}
+	out = append(out, r.broadcast(Message{Room: room, Sender: hubSender, Text: p.Name + " joins the chat"}, h.historyLimit)...)
+	r.people = append(r.people, p)
+	return out, nil
+}
+
+func (h *ChatHub) Leave(p *Person, room string) error {
+	h.mu.Lock()
+	out, err := h.leave(p, room)
+	h.mu.Unlock()
+	out.post()
+	return err
+}
+
+func (h *ChatHub) leave(p *Person, room string) (deliveries, error) {
+	r, ok := h.rooms[room]
+	if !ok || !r.has(p) {
+		return nil, fmt.Errorf("%w: %s", ErrNotInRoom, room)
+	}
+	r.people = slices.DeleteFunc(r.people, func(other *Person) bool { return other == p })
+	return r.broadcast(Message{Room: room, Sender: hubSender, Text: p.Name + " leaves the chat"}, h.historyLimit), nil
+}
+
+// Broadcast shows the message to the others in the room. Only members may
+// talk in a room.
+func (h *ChatHub) Broadcast(source, room, message string) error {
+	h.mu.RLock()
+	out, err := h.broadcast(h.people[source], room, message)
+	h.mu.RUnlock()
+	out.post()
+	return err
+}
+
+func (h *ChatHub) say(p *Person, room, message string) error {
+	h.mu.RLock()
+	out, err := h.broadcast(p, room, message)
+	h.mu.RUnlock()
+	out.post()
+	return err
+}
+
+func (h *ChatHub) broadcast(sender *Person, room, message string) (deliveries, error) {
+	r, ok := h.rooms[room]
+	if !ok || sender == nil || !r.has(sender) {
+		return nil, fmt.Errorf("%w: %s", ErrNotInRoom, room)
+	}
+	text, out, err := h.moderate(sender, &Submission{Sender: sender.Name, Room: room, Text: message})
+	if err != nil {
+		return out, err
+	}
+	return r.broadcast(Message{Room: room, Sender: sender.Name, Text: text}, h.historyLimit), nil
+}
+
+// moderate runs s through the moderator, if any, and returns the text to
+// deliver. A rejected sender gets a notice from the hub.
+func (h *ChatHub) moderate(sender *Person, s *Submission) (string, deliveries, error) {
+	if h.moderator == nil {
+		return s.Text, nil, nil
+	}
+	if err := h.moderator.check(s); err != nil {
+		var out deliveries
+		if sender != nil && h.online[sender] {
+			out = out.add(sender, Message{Sender: hubSender, Text: err.Error()})
+		}
+		return "", out, err
+	}
+	return s.Text, nil, nil
+}
+
+// Message shows the message only to the destination, or keeps it until the
+// destination comes back online.
+func (h *ChatHub) Message(source, destination, message string) error {
+	h.mu.RLock()
+	p, ok := h.people[destination]
+	if !ok {
+		h.mu.RUnlock()
+		return fmt.Errorf("%w: %s", ErrUnknownRecipient, destination)
+	}
+	text, out, err := h.moderate(h.people[source], &Submission{Sender: source, Recipient: destination, Text: message})
+	if err != nil {
+		h.mu.RUnlock()
+		out.post()
+		return err
+	}
+	m := Message{Sender: source, Text: text}
+	if h.online[p] {
+		out = out.add(p, m)
+		h.mu.RUnlock()
+		out.post()
+		return nil
+	}
+	h.mu.RUnlock()
+
+	h.mu.Lock()
+	// p may have come back in the meantime
+	if h.online[p] {
+		out = out.add(p, m)
+	} else {
+		h.pending[p] = append(h.pending[p], m)
+	}
+	h.mu.Unlock()
+	out.post()
+	return nil
+}
+
+func (h *ChatHub) privateMessage(p *Person, destination, message string) error {
+	h.mu.RLock()
+	source := p.Name
+	h.mu.RUnlock()
+	return h.Message(source, destination, message)
+}
+
+// Rename changes p's nickname and tells every room p is in.
+func (h *ChatHub) Rename(p *Person, nickname string) error {
+	h.mu.Lock()
+	out, err := h.rename(p, nickname)
+	h.mu.Unlock()
+	out.post()
+	return err
+}
+
+func (h *ChatHub) rename(p *Person, nickname string) (deliveries, error) {
+	if !h.online[p] {
+		return nil, ErrNotConnected
+	}
+	if other, ok := h.people[nickname]; ok && other != p {
+		return nil, fmt.Errorf("%w: %s", ErrNicknameTaken, nickname)
+	}
+	old := p.Name
+	delete(h.people, old)
+	p.mu.Lock()
+	p.Name = nickname
+	p.mu.Unlock()
+	h.people[nickname] = p
+	if h.moderator != nil {
+		h.moderator.rename(old, nickname)
+	}
+
+	var out deliveries
+	for _, name := range h.roomNames() {
+		if r := h.rooms[name]; r.has(p) {
+			out = append(out, r.broadcast(Message{Room: name, Sender: hubSender, Text: old + " is now known as " + nickname}, h.historyLimit)...)
+		}
+	}
+	return out, nil
+}
+
+// Ban bans nickname through the moderator for d, or until lifted if d is
+// 0, and removes the person from every room.
+func (h *ChatHub) Ban(nickname string, d time.Duration) error {
+	h.mu.Lock()
+	if h.moderator == nil {
+		h.mu.Unlock()
+		return ErrNoModerator
+	}
+	h.moderator.Ban(nickname, d)
+	var out deliveries
+	if p, ok := h.people[nickname]; ok && h.online[p] {
+		out = h.leaveAll(p).add(p, Message{Sender: hubSender, Text: "you are banned"})
+	}
+	h.mu.Unlock()
+	out.post()
+	return nil
+}
+
+// Flush waits until every message posted so far has been delivered to the
+// people online.
+func (h *ChatHub) Flush() {
+	h.mu.RLock()
+	var mailboxes []*mailbox
+	for p, online := range h.online {
+		if online {
+			mailboxes = append(mailboxes, p.mailbox)
+		}
+	}
+	h.mu.RUnlock()
+	for _, mb := range mailboxes {
+		mb.flush()
+	}
+}
+
+// roomNames returns the room names in a stable order.
+func (h *ChatHub) roomNames() []string {
+	names := make([]string, 0, len(h.rooms))
+	for name := range h.rooms {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func connect(t *testing.T, hub *ChatHub, names ...string) []*Person {
+	t.Helper()
+	var people []*Person
+	for _, name := range names {
+		p := NewPerson(name)
+		if err := hub.Connect(p); err != nil {
+			t.Fatal(err)
+		}
+		people = append(people, p)
+	}
+	return people
+}
+
+func TestChatHub_Rooms(t *testing.T) {
+	hub := NewChatHub(0)
+	people := connect(t, hub, "John", "Jane", "Simon")
+	john, jane, simon := people[0], people[1], people[2]
+
+	john.Join("general")
+	jane.Join("general")
+	simon.Join("random")
+
+	john.Say("general", "hi")
+	hub.Flush()
+	if !slices.Contains(jane.ChatLog(), "#general John: hi") {
+		t.Error("Expected Jane to receive the message")
+	}
+	hub.Flush()
+	if slices.Contains(simon.ChatLog(), "#general John: hi") {
+		t.Error("Simon is not in the room and should not receive the message")
+	}
+	if err := simon.Say("general", "hi"); !errors.Is(err, ErrNotInRoom) {
+		t.Errorf("Expected ErrNotInRoom, got %v", err)
+	}
+
+	if err := jane.Leave("general"); err != nil {
+		t.Fatal(err)
+	}
+	hub.Flush()
+	if !slices.Contains(john.ChatLog(), "#general Room: Jane leaves the chat") {
+		t.Error("Expected a leave notice")
+	}
+	john.Say("general", "still there?")
+	hub.Flush()
+	if slices.Contains(jane.ChatLog(), "#general John: still there?") {
+		t.Error("Jane left and should not receive the message")
+	}
+	if err := jane.Leave("general"); !errors.Is(err, ErrNotInRoom) {
+		t.Errorf("Expected ErrNotInRoom, got %v", err)
+	}
+}
+
+func TestChatHub_History(t *testing.T) {
+	hub := NewChatHub(2)
+	people := connect(t, hub, "John", "Jane")
+	john, jane := people[0], people[1]
+
+	john.Join("general")
+	john.Say("general", "one")
+	john.Say("general", "two")
+	john.Say("general", "three")
+
+	jane.Join("general")
+	expected := []string{"#general John: two", "#general John: three"}
+	hub.Flush()
+	if !slices.Equal(jane.ChatLog(), expected) {
+		t.Errorf("Expected the last 2 messages %v, got %v", expected, jane.ChatLog())
+	}
+}
+
+func TestChatHub_OfflineMessages(t *testing.T) {
+	hub := NewChatHub(0)
+	people := connect(t, hub, "John", "Jane")
+	john, jane := people[0], people[1]
+
+	john.Quit()
+	if err := jane.PrivateMessage("John", "see you"); err != nil {
+		t.Fatal(err)
+	}
+	hub.Flush()
+	if len(john.ChatLog()) != 0 {
+		t.Error("John is offline and should not receive the message yet")
+	}
+
+	hub.Connect(john)
+	hub.Flush()
+	if !slices.Equal(john.ChatLog(), []string{"(private) Jane: see you"}) {
+		t.Errorf("Expected the pending message, got %v", john.ChatLog())
+	}
+
+	if err := jane.PrivateMessage("Bob", "hi"); !errors.Is(err, ErrUnknownRecipient) {
+		t.Errorf("Expected ErrUnknownRecipient, got %v", err)
+	}
+}
+
+func TestChatHub_Nicknames(t *testing.T) {
+	hub := NewChatHub(0)
+	people := connect(t, hub, "John", "Jane")
+	john, jane := people[0], people[1]
+
+	if err := hub.Connect(NewPerson("John")); !errors.Is(err, ErrNicknameTaken) {
+		t.Errorf("Expected ErrNicknameTaken, got %v", err)
+	}
+	if err := john.SetNickname("Jane"); !errors.Is(err, ErrNicknameTaken) {
+		t.Errorf("Expected ErrNicknameTaken, got %v", err)
+	}
+
+	if err := john.SetNickname("Johnny"); err != nil {
+		t.Fatal(err)
+	}
+	jane.PrivateMessage("Johnny", "nice name")
+	hub.Flush()
+	if !slices.Equal(john.ChatLog(), []string{"(private) Jane: nice name"}) {
+		t.Errorf("Expected the message under the new nickname, got %v", john.ChatLog())
+	}
+	if err := jane.PrivateMessage("John", "hi"); !errors.Is(err, ErrUnknownRecipient) {
+		t.Errorf("Expected the old nickname to be free, got %v", err)
+	}
+}
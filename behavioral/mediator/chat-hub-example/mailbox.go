@@ -0,0 +1,167 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// OverflowPolicy decides what happens when a message arrives for a Person
+// whose mailbox is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest undelivered message to make room.
+	DropOldest OverflowPolicy = iota
+	// BlockWithTimeout makes the sender wait for room, up to Timeout, and
+	// then discards the new message.
+	BlockWithTimeout
+	// Disconnect drops the slow receiver from the hub.
+	Disconnect
+)
+
+type MailboxConfig struct {
+	Size    int
+	Policy  OverflowPolicy
+	Timeout time.Duration // only used by BlockWithTimeout
+}
+
+var DefaultMailbox = MailboxConfig{Size: 64, Policy: DropOldest}
+
+// mailbox is a bounded queue in front of a Person. A goroutine per mailbox
+// delivers the messages in order, so a slow receiver only delays itself.
+type mailbox struct {
+	ch       chan Message
+	config   MailboxConfig
+	overflow func() // called once when the Disconnect policy kicks in
+	// sending is held for reading by post while it sends to ch, and for
+	// writing by close while it closes ch. closing wakes up the posts that
+	// wait for room, so that close does not wait for their timeout.
+	sending sync.RWMutex
+	closing chan struct{}
+
+	mu         sync.Mutex
+	idle       *sync.Cond
+	pending    int // queued or being delivered
+	dropped    int
+	closed     bool
+	overflowed bool
+}
+
+func newMailbox(config MailboxConfig, receive func(Message), overflow func()) *mailbox {
+	if config.Size < 1 {
+		config.Size = 1
+	}
+	b := &mailbox{ch: make(chan Message, config.Size), config: config, overflow: overflow, closing: make(chan struct{})}
+	b.idle = sync.NewCond(&b.mu)
+	go func() {
+		for m := range b.ch {
+			receive(m)
+			b.done(1)
+		}
+	}()
+	return b
+}
+
+// post queues m according to the overflow policy. It may run concurrently
+// with close; a message posted once the mailbox is closed is dropped.
+func (b *mailbox) post(m Message) {
+	b.sending.RLock()
+	defer b.sending.RUnlock()
+	b.mu.Lock()
+	if b.closed || b.overflowed {
+		b.dropped++
+		b.mu.Unlock()
+		return
+	}
+	b.pending++
+	b.mu.Unlock()
+
+	select {
+	case b.ch <- m:
+		return
+	default:
+	}
+
+	switch b.config.Policy {
+	case DropOldest:
+		for {
+			select {
+			case b.ch <- m:
+				return
+			default:
+			}
+			select {
+			case <-b.ch:
+				b.drop()
+			default:
+			}
+		}
+	case BlockWithTimeout:
+		timer := time.NewTimer(b.config.Timeout)
+		defer timer.Stop()
+		select {
+		case b.ch <- m:
+		case <-timer.C:
+			b.drop()
+		case <-b.closing:
+			b.drop()
+		}
+	case Disconnect:
+		b.drop()
+		b.mu.Lock()
+		first := !b.overflowed
+		b.overflowed = true
+		b.mu.Unlock()
+		if first && b.overflow != nil {
+			b.overflow()
+		}
+	}
+}
+
+func (b *mailbox) drop() {
+	b.mu.Lock()
+	b.dropped++
+	b.mu.Unlock()
+	b.done(1)
+}
+
+func (b *mailbox) done(n int) {
+	b.mu.Lock()
+	b.pending -= n
+	if b.pending == 0 {
+		b.idle.Broadcast()
+	}
+	b.mu.Unlock()
+}
+
+// close stops accepting messages; the ones already queued are still
+// delivered.
+func (b *mailbox) close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.closing)
+	b.mu.Unlock()
+
+	b.sending.Lock() // wait for the posts in progress
+	close(b.ch)
+	b.sending.Unlock()
+}
+
+// flush waits until every queued message has been delivered or dropped.
+func (b *mailbox) flush() {
+	b.mu.Lock()
+	for b.pending > 0 {
+		b.idle.Wait()
+	}
+	b.mu.Unlock()
+}
+
+func (b *mailbox) droppedCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockedReceiver holds up the delivery of the first message until release
+// is closed.
+type blockedReceiver struct {
+	mu       sync.Mutex
+	received []string
+	started  chan struct{}
+	release  chan struct{}
+	once     sync.Once
+}
+
+func newBlockedReceiver() *blockedReceiver {
+	return &blockedReceiver{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (r *blockedReceiver) receive(m Message) {
+	r.once.Do(func() {
+		close(r.started)
+		<-r.release
+	})
+	r.mu.Lock()
+	r.received = append(r.received, m.Text)
+	r.mu.Unlock()
+}
+
+func (r *blockedReceiver) texts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.received)
+}
+
+func TestMailbox_DropOldest(t *testing.T) {
+	r := newBlockedReceiver()
+	mb := newMailbox(MailboxConfig{Size: 3, Policy: DropOldest}, r.receive, nil)
+	mb.post(Message{Text: "0"})
+	<-r.started
+	for i := 1; i <= 5; i++ {
+		mb.post(Message{Text: fmt.Sprint(i)})
+	}
+	close(r.release)
+	mb.flush()
+
+	if got := r.texts(); !slices.Equal(got, []string{"0", "3", "4", "5"}) {
+		t.Errorf("Expected the oldest messages to be dropped, got %v", got)
+	}
+	if mb.droppedCount() != 2 {
+		t.Errorf("Expected 2 dropped messages, got %d", mb.droppedCount())
+	}
+}
+
+func TestMailbox_BlockWithTimeout(t *testing.T) {
+	r := newBlockedReceiver()
+	mb := newMailbox(MailboxConfig{Size: 1, Policy: BlockWithTimeout, Timeout: 50 * time.Millisecond}, r.receive, nil)
+	mb.post(Message{Text: "0"})
+	<-r.started
+	mb.post(Message{Text: "1"})
+
+	start := time.Now()
+	mb.post(Message{Text: "2"})
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("Expected post to block for the timeout, returned after %v", elapsed)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(r.release)
+	}()
+	mb.post(Message{Text: "3"}) // room is made before the timeout
+	mb.flush()
+
+	if got := r.texts(); !slices.Equal(got, []string{"0", "1", "3"}) {
+		t.Errorf("Expected only the timed out message to be dropped, got %v", got)
+	}
+}
+
+func TestChatHub_DisconnectSlowReceiver(t *testing.T) {
+	hub := NewChatHub(0)
+	hub.SetMailbox(MailboxConfig{Size: 2, Policy: Disconnect})
+	r := newBlockedReceiver()
+	kicked := make(chan struct{})
+	slow := NewRemotePerson("slow", r.receive, func() { close(kicked) })
+	hub.Connect(slow)
+
+	hub.SetMailbox(DefaultMailbox)
+	talker := NewRemotePerson("talker", func(Message) {}, nil)
+	fast := NewPerson("fast")
+	hub.Connect(talker)
+	hub.Connect(fast)
+
+	hub.Join(slow, "general")
+	hub.Join(talker, "general")
+	hub.Join(fast, "general")
+	for i := range 5 {
+		talker.Say("general", fmt.Sprint(i))
+	}
+
+	select {
+	case <-kicked:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the slow receiver to be disconnected")
+	}
+	close(r.release)
+	hub.Flush()
+
+	if err := slow.Say("general", "hello?"); err == nil {
+		t.Error("Expected the slow receiver to be out of the room")
+	}
+	if !slices.Contains(fast.ChatLog(), "#general Room: slow leaves the chat") {
+		t.Errorf("Expected a leave notice, got %v", fast.ChatLog())
+	}
+	if got := fast.ChatLog(); !slices.Contains(got, "#general talker: 4") {
+		t.Errorf("The fast receiver should get every message, got %v", got)
+	}
+}
+
+func TestChatHub_KickAfterReconnect(t *testing.T) {
+	hub := NewChatHub(0)
+	kicked := false
+	p := NewRemotePerson("p", func(Message) {}, func() { kicked = true })
+	hub.Connect(p)
+	overflowed := p.mailbox
+	p.Quit()
+	hub.Connect(p)
+
+	// the kick for the old mailbox runs only now
+	hub.kick(p, overflowed)
+	if err := p.Join("general"); err != nil || kicked {
+		t.Errorf("Expected the new session to stay connected, got %v, kicked %v", err, kicked)
+	}
+}
+
+func TestChatHub_ConcurrentSay(t *testing.T) {
+	const people, messages = 8, 200
+	hub := NewChatHub(20)
+	hub.SetMailbox(MailboxConfig{Size: 16, Policy: BlockWithTimeout, Timeout: 10 * time.Second})
+
+	type inbox struct {
+		mu   sync.Mutex
+		next map[string]int
+		err  error
+	}
+	var everyone []*Person
+	inboxes := make([]*inbox, people)
+	for i := range people {
+		in := &inbox{next: map[string]int{}}
+		inboxes[i] = in
+		p := NewRemotePerson(fmt.Sprintf("user%d", i), func(m Message) {
+			in.mu.Lock()
+			defer in.mu.Unlock()
+			if m.Sender == hubSender || m.Room == "" { // notices and private messages
+				return
+			}
+			var j int
+			fmt.Sscanf(m.Text, "message %d", &j)
+			if j != in.next[m.Sender] && in.err == nil {
+				in.err = fmt.Errorf("got %q from %s, expected message %d", m.Text, m.Sender, in.next[m.Sender])
+			}
+			in.next[m.Sender]++
+		}, nil)
+		if err := hub.Connect(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Join("lobby"); err != nil {
+			t.Fatal(err)
+		}
+		everyone = append(everyone, p)
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range everyone {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range messages {
+				if err := p.Say("lobby", fmt.Sprintf("message %d", j)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	// people coming and going, and private messages, while the room is busy
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := range 50 {
+			visitor := NewRemotePerson(fmt.Sprintf("visitor%d", i), func(Message) {}, nil)
+			hub.Connect(visitor)
+			visitor.Join("lobby")
+			visitor.Quit()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			everyone[0].PrivateMessage("user1", "psst")
+		}
+	}()
+	wg.Wait()
+	hub.Flush()
+
+	for i, in := range inboxes {
+		in.mu.Lock()
+		if in.err != nil {
+			t.Errorf("user%d: %v", i, in.err)
+		}
+		for j, p := range everyone {
+			if j != i && in.next[p.Name] != messages {
+				t.Errorf("user%d got %d messages from %s", i, in.next[p.Name], p.Name)
+			}
+		}
+		in.mu.Unlock()
+	}
+}
+
+func TestMailbox_PostDuringClose(t *testing.T) {
+	r := newBlockedReceiver()
+	defer close(r.release)
+	mb := newMailbox(MailboxConfig{Size: 1, Policy: BlockWithTimeout, Timeout: 10 * time.Second}, r.receive, nil)
+	mb.post(Message{Text: "0"})
+	<-r.started
+	mb.post(Message{Text: "1"})
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mb.post(Message{Text: fmt.Sprint("waiting ", i)}) // waits for room
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	mb.close()
+	wg.Wait()
+	mb.post(Message{Text: "after close"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected close to wake up the waiting posts, took %v", elapsed)
+	}
+	if mb.droppedCount() != 11 {
+		t.Errorf("Expected the waiting and late messages to be dropped, got %d", mb.droppedCount())
+	}
+}
+
+func TestChatHub_SlowReceiverDoesNotStallOthers(t *testing.T) {
+	hub := NewChatHub(0)
+	hub.SetMailbox(MailboxConfig{Size: 1, Policy: BlockWithTimeout, Timeout: 10 * time.Second})
+	r := newBlockedReceiver()
+	defer close(r.release)
+	slow := NewRemotePerson("slow", r.receive, nil)
+	talker := NewRemotePerson("talker", func(Message) {}, nil)
+	hub.Connect(slow)
+	hub.Connect(talker)
+	hub.Join(talker, "slow")
+	hub.Join(slow, "slow")
+	talker.Say("slow", "0")
+	<-r.started
+	talker.Say("slow", "1") // the mailbox is full now
+
+	blocked := make(chan struct{})
+	go func() {
+		talker.Say("slow", "2") // waits for room
+		close(blocked)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a, b := NewPerson("a"), NewPerson("b")
+		hub.Connect(a)
+		hub.Connect(b)
+		a.Join("other")
+		b.Join("other")
+		a.Say("other", "hello")
+		talker.Join("other")
+		hub.Message("a", "b", "psst")
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("A receiver of another room held up the hub")
+	}
+	select {
+	case <-blocked:
+		t.Error("Expected the sender to the slow receiver to wait for room")
+	default:
+	}
+}
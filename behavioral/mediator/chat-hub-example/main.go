@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	tcpAddr := flag.String("tcp", "", "serve the line protocol on this address, e.g. :4000")
+	wsAddr := flag.String("ws", "", "serve WebSocket clients on this address, e.g. :8080")
+	flag.Parse()
+	if *tcpAddr != "" || *wsAddr != "" {
+		serve(*tcpAddr, *wsAddr)
+		return
+	}
+
+	hub := NewChatHub(10)
+	john := NewPerson("John")
+	jane := NewPerson("Jane")
+	check(hub.Connect(john))
+	check(hub.Connect(jane))
+
+	check(john.Join("general"))
+	check(jane.Join("general"))
+	check(john.Say("general", "Hi room"))
+	check(jane.Say("general", "oh, hey John"))
+
+	check(jane.Join("random"))
+	check(jane.Say("random", "anyone here?"))
+	// messages are delivered from the mailboxes in the background
+	hub.Flush()
+
+	// Simon gets the history of the room when he joins
+	simon := NewPerson("Simon")
+	check(hub.Connect(simon))
+	check(simon.Join("general"))
+	check(simon.Say("general", "Hi guys!"))
+	check(simon.SetNickname("Si"))
+	hub.Flush()
+
+	// private messages wait for offline people
+	john.Quit()
+	check(jane.PrivateMessage("John", "see you tomorrow"))
+	check(hub.Connect(john))
+	hub.Flush()
+
+	if err := jane.PrivateMessage("Bob", "are you there?"); err != nil {
+		fmt.Println("error:", err)
+	}
+	check(simon.Leave("general"))
+	hub.Flush()
+
+	// moderation: masked words, a length limit and a timed mute
+	moderator := NewModerator(NewAuditLog(os.Stdout), NewWordFilter("darn"), LengthLimit(40))
+	hub.SetModerator(moderator)
+	check(john.Join("general"))
+	check(jane.Say("general", "Darn, the build broke again"))
+	if err := jane.Say("general", strings.Repeat("really ", 10)+"broke"); err != nil {
+		fmt.Println("error:", err)
+	}
+	moderator.Mute("Jane", time.Minute)
+	if err := jane.Say("general", "hello?"); err != nil {
+		fmt.Println("error:", err)
+	}
+	hub.Flush()
+}
+
+func check(err error) {
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func serve(tcpAddr, wsAddr string) {
+	hub := NewChatHub(50)
+	hub.SetModerator(NewModerator(NewAuditLog(log.Writer()), NewRateLimiter(1, 5), LengthLimit(500)))
+	server := NewServer(hub)
+	errs := make(chan error, 2)
+	if tcpAddr != "" {
+		l, err := net.Listen("tcp", tcpAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("line protocol on", l.Addr())
+		go func() { errs <- server.ServeTCP(l) }()
+	}
+	if wsAddr != "" {
+		log.Println("websocket on", wsAddr)
+		go func() { errs <- http.ListenAndServe(wsAddr, server) }()
+	}
+	log.Fatal(<-errs)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Every message the moderation pipeline refuses wraps ErrRejected.
+var (
+	ErrRejected    = errors.New("message rejected")
+	ErrMuted       = fmt.Errorf("%w: you are muted", ErrRejected)
+	ErrBanned      = errors.New("banned")
+	ErrRateLimited = fmt.Errorf("%w: slow down", ErrRejected)
+	ErrTooLong     = fmt.Errorf("%w: too long", ErrRejected)
+	ErrNoModerator = errors.New("no moderator")
+)
+
+// Submission is a message on its way through the moderation pipeline.
+// Filters may rewrite Text. Room is empty for private messages.
+type Submission struct {
+	Time      time.Time
+	Sender    string
+	Room      string
+	Recipient string
+	Text      string
+}
+
+// Filter is a step of the moderation pipeline. It rewrites the submission
+// or rejects it with an error wrapping ErrRejected.
+type Filter interface {
+	Name() string
+	Check(s *Submission) error
+}
+
+// renamer is implemented by filters that keep state per nickname.
+type renamer interface {
+	rename(old, nickname string)
+}
+
+// Moderator runs every message through its filters before the hub delivers
+// it, and keeps the mute and ban lists. Every action is recorded in the
+// audit log.
+type Moderator struct {
+	filters []Filter
+	audit   *AuditLog
+	now     func() time.Time
+
+	mu     sync.Mutex
+	muted  map[string]time.Time // zero time: until lifted
+	banned map[string]time.Time
+}
+
+// NewModerator runs the filters in the given order. audit may be nil.
+func NewModerator(audit *AuditLog, filters ...Filter) *Moderator {
+	if audit == nil {
+		audit = NewAuditLog(nil)
+	}
+	return &Moderator{
+		filters: filters,
+		audit:   audit,
+		now:     time.Now,
+		muted:   make(map[string]time.Time),
+		banned:  make(map[string]time.Time),
+	}
+}
+
+func (m *Moderator) AuditLog() *AuditLog {
+	return m.audit
+}
+
+// Mute keeps nickname from talking for d. A d of 0 mutes until Unmute.
+func (m *Moderator) Mute(nickname string, d time.Duration) {
+	m.sanction(m.muted, AuditMute, nickname, d)
+}
+
+func (m *Moderator) Unmute(nickname string) {
+	m.lift(m.muted, AuditUnmute, nickname, "lifted")
+}
+
+// Ban keeps nickname from talking and joining rooms for d. A d of 0 bans
+// until Unban. Use ChatHub.Ban to also remove the person from its rooms.
+func (m *Moderator) Ban(nickname string, d time.Duration) {
+	m.sanction(m.banned, AuditBan, nickname, d)
+}
+
+func (m *Moderator) Unban(nickname string) {
+	m.lift(m.banned, AuditUnban, nickname, "lifted")
+}
+
+func (m *Moderator) Muted(nickname string) bool {
+	return m.active(m.muted, AuditUnmute, nickname)
+}
+
+func (m *Moderator) Banned(nickname string) bool {
+	return m.active(m.banned, AuditUnban, nickname)
+}
+
+func (m *Moderator) sanction(list map[string]time.Time, action AuditAction, nickname string, d time.Duration) {
+	now := m.now()
+	var until time.Time
+	reason := "until lifted"
+	if d > 0 {
+		until = now.Add(d)
+		reason = "for " + d.String()
+	}
+	m.mu.Lock()
+	list[nickname] = until
+	m.mu.Unlock()
+	m.audit.record(AuditEntry{Time: now, Action: action, Target: nickname, Reason: reason})
+}
+
+func (m *Moderator) lift(list map[string]time.Time, action AuditAction, nickname, reason string) {
+	m.mu.Lock()
+	_, ok := list[nickname]
+	delete(list, nickname)
+	m.mu.Unlock()
+	if ok {
+		m.audit.record(AuditEntry{Time: m.now(), Action: action, Target: nickname, Reason: reason})
+	}
+}
+
+// active reports whether nickname is on the list, and lifts the sanction
+// once it has expired.
+func (m *Moderator) active(list map[string]time.Time, lifted AuditAction, nickname string) bool {
+	now := m.now()
+	m.mu.Lock()
+	until, ok := list[nickname]
+	expired := ok && !until.IsZero() && !now.Before(until)
+	if expired {
+		delete(list, nickname)
+	}
+	m.mu.Unlock()
+	if expired {
+		m.audit.record(AuditEntry{Time: now, Action: lifted, Target: nickname, Reason: "expired"})
+	}
+	return ok && !expired
+}
+
+// check runs s through the pipeline. The first rejection stops it.
+func (m *Moderator) check(s *Submission) error {
+	s.Time = m.now()
+	var err error
+	switch {
+	case m.Banned(s.Sender):
+		err = fmt.Errorf("%w: you are %w", ErrRejected, ErrBanned)
+	case m.Muted(s.Sender):
+		err = ErrMuted
+	}
+	if err != nil {
+		m.reject(s, "", err)
+		return err
+	}
+
+	for _, f := range m.filters {
+		before := s.Text
+		if err := f.Check(s); err != nil {
+			m.reject(s, f.Name(), err)
+			return err
+		}
+		if s.Text != before {
+			m.audit.record(AuditEntry{Time: s.Time, Action: AuditRewrite, Filter: f.Name(), Target: s.Sender, Room: s.Room, Reason: before})
+		}
+	}
+	return nil
+}
+
+func (m *Moderator) reject(s *Submission, filter string, err error) {
+	m.audit.record(AuditEntry{Time: s.Time, Action: AuditReject, Filter: filter, Target: s.Sender, Room: s.Room, Reason: err.Error()})
+}
+
+// rename carries the sanctions and the filter state over to a new nickname,
+// so that renaming does not lift a mute.
+func (m *Moderator) rename(old, nickname string) {
+	m.mu.Lock()
+	for _, list := range []map[string]time.Time{m.muted, m.banned} {
+		if until, ok := list[old]; ok {
+			delete(list, old)
+			list[nickname] = until
+		}
+	}
+	m.mu.Unlock()
+	for _, f := range m.filters {
+		if r, ok := f.(renamer); ok {
+			r.rename(old, nickname)
+		}
+	}
+}
+
+type AuditAction string
+
+const (
+	AuditReject  AuditAction = "reject"
+	AuditRewrite AuditAction = "rewrite"
+	AuditMute    AuditAction = "mute"
+	AuditUnmute  AuditAction = "unmute"
+	AuditBan     AuditAction = "ban"
+	AuditUnban   AuditAction = "unban"
+)
+
+// AuditEntry is one moderation action. Filter is empty for actions of the
+// moderator itself. For rewrites, Reason holds the original text.
+type AuditEntry struct {
+	Time   time.Time
+	Action AuditAction
+	Filter string
+	Target string
+	Room   string
+	Reason string
+}
+
+func (e AuditEntry) String() string {
+	s := fmt.Sprintf("%s %s %s", e.Time.Format(time.RFC3339), e.Action, e.Target)
+	if e.Room != "" {
+		s += " in #" + e.Room
+	}
+	if e.Filter != "" {
+		s += " by " + e.Filter
+	}
+	if e.Reason != "" {
+		s += fmt.Sprintf(": %q", e.Reason)
+	}
+	return s
+}
+
+// AuditLog keeps every moderation action, and also writes it as a line to
+// w if w is not nil.
+type AuditLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	entries []AuditEntry
+}
+
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{w: w}
+}
+
+func (l *AuditLog) record(e AuditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+	if l.w != nil {
+		fmt.Fprintln(l.w, e)
+	}
+}
+
+func (l *AuditLog) Entries() []AuditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.entries)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestModerator(filters ...Filter) (*Moderator, *fakeClock) {
+	clock := &fakeClock{time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	m := NewModerator(nil, filters...)
+	m.now = clock.now
+	return m, clock
+}
+
+func actions(log *AuditLog) []AuditAction {
+	var actions []AuditAction
+	for _, e := range log.Entries() {
+		actions = append(actions, e.Action)
+	}
+	return actions
+}
+
+func TestWordFilter(t *testing.T) {
+	f := NewWordFilter("darn", "heck")
+	s := &Submission{Text: "Darn it, DARN! darned heck…"}
+	if err := f.Check(s); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "**** it, ****! darned ****…"; s.Text != expected {
+		t.Errorf("Expected %q, got %q", expected, s.Text)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(1, 2)
+	start := time.Now()
+	check := func(sender string, at time.Duration) error {
+		return l.Check(&Submission{Sender: sender, Time: start.Add(at)})
+	}
+
+	if check("John", 0) != nil || check("John", 0) != nil {
+		t.Fatal("Expected a burst of 2 to be allowed")
+	}
+	if err := check("John", 0); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+	if err := check("Jane", 0); err != nil {
+		t.Errorf("Every sender has a bucket of its own, got %v", err)
+	}
+	if err := check("John", 500*time.Millisecond); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected half a token not to be enough, got %v", err)
+	}
+	if err := check("John", time.Second); err != nil {
+		t.Errorf("Expected a token after a second, got %v", err)
+	}
+}
+
+func TestLengthLimit(t *testing.T) {
+	if err := LengthLimit(5).Check(&Submission{Text: "héllo"}); err != nil {
+		t.Errorf("Characters should be counted, not bytes, got %v", err)
+	}
+	if err := LengthLimit(5).Check(&Submission{Text: "hello!"}); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestChatHub_Moderation(t *testing.T) {
+	hub := NewChatHub(10)
+	moderator, _ := newTestModerator(NewWordFilter("darn"), LengthLimit(20))
+	hub.SetModerator(moderator)
+	people := connect(t, hub, "John", "Jane")
+	john, jane := people[0], people[1]
+	john.Join("general")
+	jane.Join("general")
+
+	john.Say("general", "darn it")
+	if err := john.Say("general", strings.Repeat("a", 21)); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Expected ErrTooLong, got %v", err)
+	}
+	if err := john.PrivateMessage("Jane", "darn"); err != nil {
+		t.Fatal(err)
+	}
+	hub.Flush()
+
+	expected := []string{"#general Room: John joins the chat", "#general John: **** it", "(private) John: ****"}
+	if !slices.Equal(jane.ChatLog(), expected) {
+		t.Errorf("Expected %v, got %v", expected, jane.ChatLog())
+	}
+	if !slices.Contains(john.ChatLog(), "(private) Room: message rejected: too long (21 characters, at most 20)") {
+		t.Errorf("Expected a rejection notice, got %v", john.ChatLog())
+	}
+	if got := actions(moderator.AuditLog()); !slices.Equal(got, []AuditAction{AuditRewrite, AuditReject, AuditRewrite}) {
+		t.Errorf("Unexpected audit log %v", got)
+	}
+}
+
+func TestChatHub_Mute(t *testing.T) {
+	hub := NewChatHub(0)
+	moderator, clock := newTestModerator()
+	hub.SetModerator(moderator)
+	people := connect(t, hub, "John", "Jane")
+	john, jane := people[0], people[1]
+	john.Join("general")
+	jane.Join("general")
+
+	moderator.Mute("John", time.Minute)
+	if err := john.Say("general", "hi"); !errors.Is(err, ErrMuted) {
+		t.Errorf("Expected ErrMuted, got %v", err)
+	}
+	if err := john.PrivateMessage("Jane", "hi"); !errors.Is(err, ErrMuted) {
+		t.Errorf("Expected ErrMuted for private messages too, got %v", err)
+	}
+
+	// renaming does not get around the mute
+	john.SetNickname("Johnny")
+	if err := john.Say("general", "hi"); !errors.Is(err, ErrMuted) {
+		t.Errorf("Expected ErrMuted after renaming, got %v", err)
+	}
+
+	clock.advance(time.Minute)
+	if err := john.Say("general", "hi"); err != nil {
+		t.Errorf("Expected the mute to expire, got %v", err)
+	}
+	hub.Flush()
+	if got := jane.ChatLog(); !slices.Equal(got, []string{"#general Room: John is now known as Johnny", "#general Johnny: hi"}) {
+		t.Errorf("Jane should only get the message after the mute, got %v", got)
+	}
+
+	entries := moderator.AuditLog().Entries()
+	last := entries[len(entries)-1]
+	if last.Action != AuditUnmute || last.Target != "Johnny" || last.Reason != "expired" {
+		t.Errorf("Expected the expiry to be audited, got %v", last)
+	}
+	if got := actions(moderator.AuditLog()); !slices.Equal(got, []AuditAction{AuditMute, AuditReject, AuditReject, AuditReject, AuditUnmute}) {
+		t.Errorf("Unexpected audit log %v", got)
+	}
+}
+
+func TestChatHub_Ban(t *testing.T) {
+	hub := NewChatHub(0)
+	if err := hub.Ban("John", 0); !errors.Is(err, ErrNoModerator) {
+		t.Errorf("Expected ErrNoModerator, got %v", err)
+	}
+	moderator, clock := newTestModerator()
+	hub.SetModerator(moderator)
+	people := connect(t, hub, "John", "Jane")
+	john, jane := people[0], people[1]
+	john.Join("general")
+	jane.Join("general")
+
+	if err := hub.Ban("John", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := john.Join("general"); !errors.Is(err, ErrBanned) {
+		t.Errorf("Expected ErrBanned, got %v", err)
+	}
+	if err := john.PrivateMessage("Jane", "let me in"); !errors.Is(err, ErrBanned) || !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected a rejection for the ban, got %v", err)
+	}
+	hub.Flush()
+	if !slices.Contains(jane.ChatLog(), "#general Room: John leaves the chat") {
+		t.Errorf("Expected John to be removed from the room, got %v", jane.ChatLog())
+	}
+	if !slices.Contains(john.ChatLog(), "(private) Room: you are banned") {
+		t.Errorf("Expected John to be told, got %v", john.ChatLog())
+	}
+
+	clock.advance(time.Hour)
+	if err := john.Join("general"); err != nil {
+		t.Errorf("Expected the ban to expire, got %v", err)
+	}
+}
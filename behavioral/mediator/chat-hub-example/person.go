@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Message is what the hub hands to a Person. Room is empty for private
+// messages.
+type Message struct {
+	Room   string
+	Sender string
+	Text   string
+	notice bool // a reply meant for one person, shown as is
+}
+
+func (m Message) String() string {
+	if m.notice {
+		return m.Text
+	}
+	if m.Room == "" {
+		return fmt.Sprintf("(private) %s: %s", m.Sender, m.Text)
+	}
+	return fmt.Sprintf("#%s %s: %s", m.Room, m.Sender, m.Text)
+}
+
+// Person receives messages from its mailbox goroutine, one at a time. Once
+// connected, its Name is changed by the hub and must only be set through
+// SetNickname.
+type Person struct {
+	Name    string
+	hub     *ChatHub
+	mailbox *mailbox
+	deliver func(Message)
+	onKick  func()
+
+	mu      sync.Mutex
+	chatLog []string
+}
+
+func NewPerson(name string) *Person {
+	return &Person{Name: name}
+}
+
+// NewRemotePerson hands every message to deliver instead of printing and
+// logging it, e.g. to write it to a network connection. onKick is called if
+// the hub disconnects the person because it could not keep up; it may be nil.
+func NewRemotePerson(name string, deliver func(Message), onKick func()) *Person {
+	return &Person{Name: name, deliver: deliver, onKick: onKick}
+}
+
+func (p *Person) Receive(m Message) {
+	if p.deliver != nil {
+		p.deliver(m)
+		return
+	}
+	s := m.String()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Printf("[%s's chat session]: %s\n", p.Name, s)
+	p.chatLog = append(p.chatLog, s)
+}
+
+// ChatLog returns the messages received so far.
+func (p *Person) ChatLog() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.chatLog)
+}
+
+// Dropped reports how many messages the mailbox discarded because it was
+// full.
+func (p *Person) Dropped() int {
+	if p.mailbox == nil {
+		return 0
+	}
+	return p.mailbox.droppedCount()
+}
+
+func (p *Person) Join(room string) error {
+	if p.hub == nil {
+		return ErrNotConnected
+	}
+	return p.hub.Join(p, room)
+}
+
+func (p *Person) Leave(room string) error {
+	if p.hub == nil {
+		return ErrNotConnected
+	}
+	return p.hub.Leave(p, room)
+}
+
+func (p *Person) Say(room, message string) error {
+	if p.hub == nil {
+		return ErrNotConnected
+	}
+	return p.hub.say(p, room, message)
+}
+
+func (p *Person) PrivateMessage(who, message string) error {
+	if p.hub == nil {
+		return ErrNotConnected
+	}
+	return p.hub.privateMessage(p, who, message)
+}
+
+func (p *Person) SetNickname(nickname string) error {
+	if p.hub == nil {
+		return ErrNotConnected
+	}
+	return p.hub.Rename(p, nickname)
+}
+
+func (p *Person) Quit() {
+	if p.hub != nil {
+		p.hub.Disconnect(p)
+	}
+}
+
+// notify queues text for p behind the messages already in its mailbox.
+func (p *Person) notify(text string) {
+	p.mailbox.post(Message{Text: text, notice: true})
+}
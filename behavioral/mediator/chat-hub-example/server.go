@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A client that does not read for this long is considered gone.
+const writeTimeout = 5 * time.Second
+
+// lineConn is a client connection that carries one command or message per
+// line, over TCP or WebSocket.
+type lineConn interface {
+	ReadLine() (string, error)
+	WriteLine(line string) error
+	Close() error
+}
+
+type tcpConn struct {
+	conn    net.Conn
+	scanner *bufio.Scanner
+	mu      sync.Mutex // serialises writes
+}
+
+func newTCPConn(conn net.Conn) *tcpConn {
+	return &tcpConn{conn: conn, scanner: bufio.NewScanner(conn)}
+}
+
+func (c *tcpConn) ReadLine() (string, error) {
+	if !c.scanner.Scan() {
+		if err := c.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", net.ErrClosed
+	}
+	return strings.TrimRight(c.scanner.Text(), "\r"), nil
+}
+
+func (c *tcpConn) WriteLine(line string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write([]byte(line + "\n"))
+	return err
+}
+
+func (c *tcpConn) Close() error {
+	return c.conn.Close()
+}
+
+// Server is a network front end for a ChatHub. Every connection is backed
+// by a Person, and every command is routed through the hub.
+//
+// Commands:
+//
+//	/nick <nickname>     change nickname
+//	/join <room>         join a room and make it the current one
+//	/leave [room]        leave a room, the current one by default
+//	/msg <nick> <text>   private message
+//	/quit                disconnect
+//
+// Any other line is said in the current room.
+type Server struct {
+	hub       *ChatHub
+	mu        sync.Mutex // guards guests, conns, listeners and closed
+	guests    int
+	conns     map[lineConn]struct{}
+	listeners []net.Listener
+	closed    bool
+}
+
+func NewServer(hub *ChatHub) *Server {
+	return &Server{hub: hub, conns: make(map[lineConn]struct{})}
+}
+
+// ServeTCP accepts line protocol clients until l is closed.
+func (s *Server) ServeTCP(l net.Listener) error {
+	s.mu.Lock()
+	s.listeners = append(s.listeners, l)
+	s.mu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(newTCPConn(conn))
+	}
+}
+
+// ServeHTTP upgrades the request to a WebSocket, where every text message
+// is a line of the same protocol.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgradeWebsocket(w, r)
+	if err != nil {
+		return
+	}
+	s.handle(conn)
+}
+
+// Close stops the TCP listeners and disconnects every client.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	for c := range s.conns {
+		c.Close()
+	}
+	return nil
+}
+
+type session struct {
+	conn   lineConn
+	person *Person
+	room   string
+}
+
+func (s *Server) handle(c lineConn) {
+	sess := &session{conn: c}
+	sess.person = NewRemotePerson("", func(m Message) {
+		c.WriteLine(m.String())
+	}, func() {
+		c.Close()
+	})
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		c.Close()
+		return
+	}
+	s.conns[c] = struct{}{}
+	for {
+		s.guests++
+		sess.person.Name = fmt.Sprintf("guest-%d", s.guests)
+		if s.hub.Connect(sess.person) == nil {
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	mailbox := sess.person.mailbox
+	defer func() {
+		sess.person.Quit()
+		mailbox.flush()
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	// replies go through the mailbox too, so they stay in order with the
+	// messages from the hub
+	sess.person.notify("* welcome " + sess.person.Name)
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		reply, quit := s.execute(sess, line)
+		if reply != "" {
+			sess.person.notify(reply)
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// execute runs one line from a client and returns the reply for it. Replies
+// start with "*" on success and "!" on failure.
+func (s *Server) execute(sess *session, line string) (reply string, quit bool) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", false
+	}
+	p := sess.person
+	if !strings.HasPrefix(line, "/") {
+		if sess.room == "" {
+			return "! join a room first", false
+		}
+		return failure(p.Say(sess.room, line)), false
+	}
+
+	command, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+	switch command {
+	case "/nick":
+		if args == "" || strings.ContainsAny(args, " \t") {
+			return "! usage: /nick <nickname>", false
+		}
+		if err := p.SetNickname(args); err != nil {
+			return failure(err), false
+		}
+		return "* you are now " + p.Name, false
+	case "/join":
+		if args == "" {
+			return "! usage: /join <room>", false
+		}
+		room := strings.TrimPrefix(args, "#")
+		if err := p.Join(room); err != nil {
+			return failure(err), false
+		}
+		sess.room = room
+		return "* joined #" + room, false
+	case "/leave":
+		room := strings.TrimPrefix(args, "#")
+		if room == "" {
+			room = sess.room
+		}
+		if room == "" {
+			return "! usage: /leave [room]", false
+		}
+		if err := p.Leave(room); err != nil {
+			return failure(err), false
+		}
+		if room == sess.room {
+			sess.room = ""
+		}
+		return "* left #" + room, false
+	case "/msg":
+		who, text, ok := strings.Cut(args, " ")
+		if !ok || strings.TrimSpace(text) == "" {
+			return "! usage: /msg <nick> <text>", false
+		}
+		return failure(p.PrivateMessage(who, strings.TrimSpace(text))), false
+	case "/quit":
+		return "* bye", true
+	}
+	return "! unknown command " + command, false
+}
+
+func failure(err error) string {
+	// the hub already told the sender why the message was rejected
+	if err == nil || errors.Is(err, ErrRejected) {
+		return ""
+	}
+	return "! " + err.Error()
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testClient interface {
+	send(line string)
+	recv() (string, error)
+	close()
+}
+
+type tcpTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialTCP(t *testing.T, addr string) *tcpTestClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tcpTestClient{t, conn, bufio.NewReader(conn)}
+}
+
+func (c *tcpTestClient) send(line string) {
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *tcpTestClient) recv() (string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	line, err := c.r.ReadString('\n')
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+func (c *tcpTestClient) close() { c.conn.Close() }
+
+type wsTestClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialWebsocket(t *testing.T, url string) *wsTestClient {
+	t.Helper()
+	addr := strings.TrimPrefix(url, "http://")
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	fmt.Fprintf(conn, "GET /chat HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n", addr, key)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Unexpected handshake status %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-Websocket-Accept") != websocketAccept(key) {
+		t.Fatal("Wrong Sec-WebSocket-Accept")
+	}
+	return &wsTestClient{t, conn, r}
+}
+
+// writeFrame sends a masked frame, as clients must.
+func (c *wsTestClient) writeFrame(opcode byte, payload []byte) {
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *wsTestClient) send(line string) {
+	c.writeFrame(opText, []byte(line))
+}
+
+func (c *wsTestClient) recv() (string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return "", err
+	}
+	if op := header[0] & 0x0F; op == opClose {
+		return "", io.EOF
+	}
+	payload := make([]byte, header[1]&0x7F)
+	_, err := io.ReadFull(c.r, payload)
+	return string(payload), err
+}
+
+func (c *wsTestClient) close() { c.conn.Close() }
+
+func expect(t *testing.T, c testClient, expected string) {
+	t.Helper()
+	line, err := c.recv()
+	if err != nil {
+		t.Fatalf("Expected %q, got error %v", expected, err)
+	}
+	if line != expected {
+		t.Fatalf("Expected %q, got %q", expected, line)
+	}
+}
+
+func expectClosed(t *testing.T, c testClient) {
+	t.Helper()
+	if line, err := c.recv(); err == nil {
+		t.Fatalf("Expected the connection to be closed, got %q", line)
+	}
+}
+
+func startServer(t *testing.T, hub *ChatHub) (*Server, string, string) {
+	t.Helper()
+	server := NewServer(hub)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeTCP(l)
+	ws := httptest.NewServer(server)
+	t.Cleanup(func() {
+		server.Close()
+		ws.Close()
+	})
+	return server, l.Addr().String(), ws.URL
+}
+
+func TestServer_Session(t *testing.T) {
+	server, tcpAddr, wsURL := startServer(t, NewChatHub(10))
+
+	alice := dialTCP(t, tcpAddr)
+	defer alice.close()
+	expect(t, alice, "* welcome guest-1")
+	alice.send("/nick alice")
+	expect(t, alice, "* you are now alice")
+	alice.send("hello?")
+	expect(t, alice, "! join a room first")
+	alice.send("/join #general")
+	expect(t, alice, "* joined #general")
+
+	bob := dialWebsocket(t, wsURL)
+	defer bob.close()
+	expect(t, bob, "* welcome guest-2")
+	bob.send("/nick bob")
+	expect(t, bob, "* you are now bob")
+	bob.send("/join general")
+	expect(t, bob, "#general Room: alice joins the chat") // history
+	expect(t, bob, "* joined #general")
+	expect(t, alice, "#general Room: bob joins the chat")
+
+	alice.send("hi bob")
+	expect(t, bob, "#general alice: hi bob")
+
+	carol := dialTCP(t, tcpAddr)
+	defer carol.close()
+	expect(t, carol, "* welcome guest-3")
+	carol.send("/msg bob psst")
+	expect(t, bob, "(private) guest-3: psst")
+	carol.send("/msg nobody hi")
+	expect(t, carol, "! unknown recipient: nobody")
+	carol.send("/msg bob")
+	expect(t, carol, "! usage: /msg <nick> <text>")
+	carol.send("/nick alice")
+	expect(t, carol, "! nickname already taken: alice")
+	carol.send("/dance")
+	expect(t, carol, "! unknown command /dance")
+
+	bob.send("/quit")
+	expect(t, bob, "* bye")
+	expectClosed(t, bob)
+	expect(t, alice, "#general Room: bob leaves the chat")
+
+	// bob keeps his nickname while offline
+	carol.send("/msg bob see you")
+	carol.send("/leave")
+	expect(t, carol, "! usage: /leave [room]")
+	carol.send("/leave general")
+	expect(t, carol, "! not in room: general")
+
+	server.Close()
+	expectClosed(t, alice)
+	expectClosed(t, carol)
+}
+
+func TestServer_ConcurrentClients(t *testing.T) {
+	// no message may be lost, so slow readers hold up the senders instead
+	hub := NewChatHub(0)
+	hub.SetMailbox(MailboxConfig{Size: 8, Policy: BlockWithTimeout, Timeout: 5 * time.Second})
+	_, tcpAddr, wsURL := startServer(t, hub)
+	const clients, messages = 6, 20
+
+	var all []testClient
+	for i := range clients {
+		var c testClient
+		if i%2 == 0 {
+			c = dialTCP(t, tcpAddr)
+		} else {
+			c = dialWebsocket(t, wsURL)
+		}
+		defer c.close()
+		c.recv() // welcome
+		c.send(fmt.Sprintf("/nick user%d", i))
+		expect(t, c, fmt.Sprintf("* you are now user%d", i))
+		all = append(all, c)
+	}
+	for i, c := range all {
+		c.send("/join lobby")
+		expect(t, c, "* joined #lobby")
+		for _, other := range all[:i] {
+			expect(t, other, fmt.Sprintf("#lobby Room: user%d joins the chat", i))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range all {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range messages {
+				c.send(fmt.Sprintf("message %d from user%d", j, i))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			next := make([]int, clients)
+			for range (clients - 1) * messages {
+				line, err := c.recv()
+				if err != nil {
+					t.Errorf("user%d: %v", i, err)
+					return
+				}
+				var j, from int
+				if _, err := fmt.Sscanf(line, "#lobby user%d: message %d from", &from, &j); err != nil {
+					t.Errorf("user%d: unexpected line %q", i, line)
+					return
+				}
+				if from == i || j != next[from] {
+					t.Errorf("user%d: out of order line %q", i, line)
+					return
+				}
+				next[from]++
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestServer_Moderation(t *testing.T) {
+	hub := NewChatHub(0)
+	hub.SetModerator(NewModerator(nil, NewRateLimiter(0, 1)))
+	_, tcpAddr, _ := startServer(t, hub)
+
+	alice := dialTCP(t, tcpAddr)
+	defer alice.close()
+	expect(t, alice, "* welcome guest-1")
+	alice.send("/join general")
+	expect(t, alice, "* joined #general")
+	alice.send("one")
+	alice.send("two")
+	// the notice from the hub is the only reply
+	expect(t, alice, "(private) Room: message rejected: slow down")
+	alice.send("/leave")
+	expect(t, alice, "* left #general")
+}
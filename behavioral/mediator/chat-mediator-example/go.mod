@@ -0,0 +1,3 @@
+module mediator-chat
+
+go 1.23.6
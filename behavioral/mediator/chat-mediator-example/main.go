@@ -1,23 +1,52 @@
 package main
 
-import "fmt"
-
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// Person gets its messages from a mailbox goroutine, one at a time, so a
+// slow person never holds up the room.
 type Person struct {
 	Name    string
 	Room    *ChatRoom
+	out     io.Writer // where the chat session is shown
+	mailbox *mailbox
+
+	mu      sync.Mutex
 	chatLog []string
 }
 
 func NewPerson(name string) *Person {
-	return &Person{Name: name}
+	return &Person{Name: name, out: os.Stdout}
 }
 
 func (p *Person) Receive(sender, message string) {
 	s := fmt.Sprintf("%s: %s", sender, message)
-	fmt.Printf("[%s's chat session]: %s\n", p.Name, s)
+	fmt.Fprintf(p.out, "[%s's chat session]: %s\n", p.Name, s)
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.chatLog = append(p.chatLog, s)
 }
 
+func (p *Person) ChatLog() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.chatLog)
+}
+
+// Dropped reports how many messages the mailbox discarded because it was
+// full.
+func (p *Person) Dropped() int {
+	if p.mailbox == nil {
+		return 0
+	}
+	return p.mailbox.droppedCount()
+}
+
 func (p *Person) Say(message string) {
 	p.Room.Broadcast(p.Name, message)
 }
@@ -27,37 +56,86 @@ func (p *Person) PrivateMessage(who, message string) {
 }
 
 type ChatRoom struct {
-	people []*Person
+	mu      sync.RWMutex
+	people  []*Person
+	mailbox MailboxConfig
 }
 
-// Show the message to the others
+func NewChatRoom() *ChatRoom {
+	return &ChatRoom{mailbox: DefaultMailbox}
+}
+
+// SetMailbox configures the mailboxes of people joining from now on.
+func (c *ChatRoom) SetMailbox(config MailboxConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mailbox = config
+}
+
+// Show the message to the others. No lock is held while it is posted, as
+// BlockWithTimeout may make the sender wait.
 func (c *ChatRoom) Broadcast(source, message string) {
-	for _, p := range c.people {
+	for _, p := range c.snapshot() {
 		if p.Name != source {
-			p.Receive(source, message)
+			p.mailbox.post(envelope{source, message})
 		}
 	}
 }
 
-// Show the message only yo the destination
+// Show the message only to the destination
 func (c *ChatRoom) Message(source, destination, message string) {
-	for _, p := range c.people {
+	for _, p := range c.snapshot() {
 		if p.Name == destination {
-			p.Receive(source, message)
+			p.mailbox.post(envelope{source, message})
 		}
 	}
 }
 
+func (c *ChatRoom) snapshot() []*Person {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Clone(c.people)
+}
+
 func (c *ChatRoom) Join(p *Person) {
 	joinMsg := p.Name + " joins the chat"
 	c.Broadcast("Room", joinMsg)
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	p.Room = c
+	var mb *mailbox
+	mb = newMailbox(c.mailbox, func(m envelope) { p.Receive(m.sender, m.text) }, func() {
+		// post calls this holding mb.sending, which closing mb waits for
+		go c.remove(p, mb)
+	})
+	p.mailbox = mb
 	c.people = append(c.people, p)
 }
 
+// remove takes out a person whose mailbox mb could not keep up.
+func (c *ChatRoom) remove(p *Person, mb *mailbox) {
+	c.mu.Lock()
+	i := slices.Index(c.people, p)
+	if i < 0 || p.mailbox != mb {
+		c.mu.Unlock()
+		return
+	}
+	c.people = slices.Delete(c.people, i, i+1)
+	c.mu.Unlock()
+	mb.close()
+	c.Broadcast("Room", p.Name+" leaves the chat")
+}
+
+// Flush waits until the messages posted so far are delivered or dropped.
+func (c *ChatRoom) Flush() {
+	for _, p := range c.snapshot() {
+		p.mailbox.flush()
+	}
+}
+
 func main() {
-	room := ChatRoom{}
+	room := NewChatRoom()
 	john := NewPerson("John")
 	jane := NewPerson("Jane")
 
@@ -72,4 +150,6 @@ func main() {
 	simon.Say("Hi guys!")
 
 	jane.PrivateMessage("Simon", "glad you could join us!")
+	// messages are delivered from the mailboxes in the background
+	room.Flush()
 }
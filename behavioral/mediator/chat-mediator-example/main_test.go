@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockedWriter holds up the first write until release is closed.
+type blockedWriter struct {
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func newBlockedWriter() *blockedWriter {
+	return &blockedWriter{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (w *blockedWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.started)
+		<-w.release
+	})
+	return len(p), nil
+}
+
+func quietPerson(name string) *Person {
+	p := NewPerson(name)
+	p.out = io.Discard
+	return p
+}
+
+func TestMailbox_DropOldest(t *testing.T) {
+	w := newBlockedWriter()
+	var received []string
+	mb := newMailbox(MailboxConfig{Size: 3, Policy: DropOldest}, func(m envelope) {
+		w.Write(nil)
+		received = append(received, m.text)
+	}, nil)
+	mb.post(envelope{text: "0"})
+	<-w.started
+	for i := 1; i <= 5; i++ {
+		mb.post(envelope{text: fmt.Sprint(i)})
+	}
+	close(w.release)
+	mb.flush()
+
+	if !slices.Equal(received, []string{"0", "3", "4", "5"}) {
+		t.Errorf("Expected the oldest messages to be dropped, got %v", received)
+	}
+	if mb.droppedCount() != 2 {
+		t.Errorf("Expected 2 dropped messages, got %d", mb.droppedCount())
+	}
+}
+
+func TestChatRoom_DisconnectSlowReceiver(t *testing.T) {
+	room := NewChatRoom()
+	room.SetMailbox(MailboxConfig{Size: 2, Policy: Disconnect})
+	w := newBlockedWriter()
+	slow := NewPerson("slow")
+	slow.out = w
+	room.Join(slow)
+
+	room.SetMailbox(DefaultMailbox)
+	talker, fast := quietPerson("talker"), quietPerson("fast")
+	room.Join(talker)
+	<-w.started
+	room.Join(fast)
+	for i := range 5 {
+		talker.Say(fmt.Sprint(i))
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for slices.Contains(room.snapshot(), slow) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the slow receiver to be removed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(w.release)
+	room.Flush()
+	slow.mailbox.flush()
+
+	if slow.Dropped() == 0 {
+		t.Error("Expected the messages to the slow receiver to be counted as dropped")
+	}
+	if !slices.Contains(fast.ChatLog(), "Room: slow leaves the chat") {
+		t.Errorf("Expected a leave notice, got %v", fast.ChatLog())
+	}
+	if !slices.Contains(fast.ChatLog(), "talker: 4") {
+		t.Errorf("The fast receiver should get every message, got %v", fast.ChatLog())
+	}
+}
+
+func TestChatRoom_ConcurrentSay(t *testing.T) {
+	const people, messages = 8, 200
+	room := NewChatRoom()
+	room.SetMailbox(MailboxConfig{Size: 16, Policy: BlockWithTimeout, Timeout: 10 * time.Second})
+	var everyone []*Person
+	for i := range people {
+		p := quietPerson(fmt.Sprint("user", i))
+		room.Join(p)
+		everyone = append(everyone, p)
+	}
+
+	var wg sync.WaitGroup
+	for _, p := range everyone {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range messages {
+				p.Say(fmt.Sprint("message ", j))
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			everyone[0].PrivateMessage("user1", "psst")
+		}
+	}()
+	wg.Wait()
+	room.Flush()
+
+	for _, p := range everyone {
+		if p.Dropped() != 0 {
+			t.Errorf("%s dropped %d messages", p.Name, p.Dropped())
+		}
+		// every sender's messages arrive in order
+		next := map[string]int{}
+		for _, line := range p.ChatLog() {
+			var sender string
+			var j int
+			if n, _ := fmt.Sscanf(line, "%s message %d", &sender, &j); n != 2 {
+				continue
+			}
+			if j != next[sender] {
+				t.Fatalf("%s got %q, expected message %d", p.Name, line, next[sender])
+			}
+			next[sender]++
+		}
+		for _, other := range everyone {
+			if other != p && next[other.Name+":"] != messages {
+				t.Errorf("%s got %d messages from %s", p.Name, next[other.Name+":"], other.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Clock is the virtual clock of a discrete-event simulation. Time does not
+// pass on its own: Run jumps from one scheduled event to the next, so a
+// whole day is simulated in no time.
+type Clock struct {
+	now    time.Time
+	events eventQueue
+	seq    int
+}
+
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+func (c *Clock) Now() time.Time {
+	return c.now
+}
+
+// At schedules fn to run at t. Events in the past run at the current time.
+// Events at the same time run in the order they were scheduled.
+func (c *Clock) At(t time.Time, fn func()) {
+	if t.Before(c.now) {
+		t = c.now
+	}
+	c.seq++
+	heap.Push(&c.events, &event{at: t, seq: c.seq, fn: fn})
+}
+
+func (c *Clock) After(d time.Duration, fn func()) {
+	c.At(c.now.Add(d), fn)
+}
+
+// Run runs the events in order until none are left, and returns how many
+// ran.
+func (c *Clock) Run() int {
+	n := 0
+	for c.events.Len() > 0 {
+		c.step()
+		n++
+	}
+	return n
+}
+
+// RunUntil runs the events up to and including t, then sets the clock to t.
+func (c *Clock) RunUntil(t time.Time) int {
+	n := 0
+	for c.events.Len() > 0 && !c.events[0].at.After(t) {
+		c.step()
+		n++
+	}
+	if t.After(c.now) {
+		c.now = t
+	}
+	return n
+}
+
+func (c *Clock) step() {
+	e := heap.Pop(&c.events).(*event)
+	c.now = e.at
+	e.fn()
+}
+
+type event struct {
+	at  time.Time
+	seq int
+	fn  func()
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if !q[i].at.Equal(q[j].at) {
+		return q[i].at.Before(q[j].at)
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x any) { *q = append(*q, x.(*event)) }
+
+func (q *eventQueue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
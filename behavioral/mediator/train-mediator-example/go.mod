@@ -0,0 +1,3 @@
+module mediator-train
+
+go 1.23.6
@@ -1,105 +1,213 @@
 package main
 
-import "fmt"
+import (
+	"cmp"
+	_ "embed"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
 
 // train
-type Train interface {
-	arrive()
-	depart()
-	permitArrival()
-}
-
-// passenger
-type PassengerTrain struct {
+type Train struct {
+	Entry
 	mediator Mediator
+	clock    *Clock
+	log      io.Writer
+	day      time.Time // the timetable is relative to it
+
+	platform int // 0 until the train is at a platform
+	arrived  time.Time
+	departed time.Time
 }
 
-func (g *PassengerTrain) arrive() {
-	if !g.mediator.canArrive(g) {
-		fmt.Println("PassengerTrain: Arrival blocked, waiting")
+// arrive is called at the scheduled arrival time.
+func (t *Train) arrive() {
+	if !t.mediator.canArrive(t) {
+		t.logf("arrival blocked, waiting")
 		return
 	}
-	fmt.Println("PassengerTrain: Arrived")
+	t.logf("arrived at platform %d", t.platform)
 }
-func (g *PassengerTrain) depart() {
-	fmt.Println("PassengerTrain: leaving")
-	g.mediator.notifyAboutDeparture()
-}
-func (g *PassengerTrain) permitArrival() {
-	fmt.Println("PassengerTrain: Arrival permitted, arriving")
-	g.arrive()
+
+func (t *Train) depart() {
+	t.departed = t.clock.Now()
+	t.logf("leaving platform %d", t.platform)
+	t.mediator.notifyAboutDeparture(t)
 }
 
-// freit train
-type FreightTrain struct {
-	mediator Mediator
+func (t *Train) permitArrival() {
+	t.logf("arrival permitted, arrived at platform %d", t.platform)
 }
 
-func (g *FreightTrain) arrive() {
-	if !g.mediator.canArrive(g) {
-		fmt.Println("FreightTrain: Arrival blocked, waiting")
-		return
+func (t *Train) logf(format string, args ...any) {
+	if t.log != nil {
+		fmt.Fprintf(t.log, "%s %s (%s): %s\n", t.clock.Now().Format("15:04"), t.ID, t.Kind, fmt.Sprintf(format, args...))
 	}
-	fmt.Println("FreightTrain: Arrived")
-}
-func (g *FreightTrain) depart() {
-	fmt.Println("FreightTrain: leaving")
-	g.mediator.notifyAboutDeparture()
-}
-func (g *FreightTrain) permitArrival() {
-	fmt.Println("FreightTrain: Arrival permitted, arriving")
-	g.arrive()
 }
 
 // Mediator
 type Mediator interface {
-	canArrive(Train) bool
-	notifyAboutDeparture()
+	canArrive(*Train) bool
+	notifyAboutDeparture(*Train)
+}
+
+// Policy orders the trains waiting for a platform: it returns a negative
+// number when a should get a platform before b.
+type Policy func(a, b *Waiting) int
+
+type Waiting struct {
+	Train *Train
+	Since time.Time
+}
+
+// PriorityFirst lets passenger trains in before freight trains, and trains
+// of the same kind in the order they were scheduled.
+func PriorityFirst(a, b *Waiting) int {
+	if p := b.Train.Kind.Priority() - a.Train.Kind.Priority(); p != 0 {
+		return p
+	}
+	return FirstCome(a, b)
+}
+
+// FirstCome lets trains in the order they were scheduled to arrive, whatever
+// their kind.
+func FirstCome(a, b *Waiting) int {
+	if c := cmp.Compare(a.Train.Arrival, b.Train.Arrival); c != 0 {
+		return c
+	}
+	return a.Since.Compare(b.Since)
+}
+
+type Config struct {
+	Platforms int
+	// MinDwell is how long a late train stays at least, so that it may
+	// leave before its scheduled departure would allow.
+	MinDwell time.Duration
+	// MaxDwell caps the time a train of each kind may hold a platform,
+	// whatever the timetable says. Kinds without an entry are not capped.
+	MaxDwell map[Kind]time.Duration
+	Policy   Policy
 }
 
 // Statios Manager
 type StationManager struct {
-	isPlatformFree bool
-	trainQueue     []Train
+	clock     *Clock
+	config    Config
+	platforms []*Train // nil when free
+	queue     []*Waiting
 }
 
-func NewStationManager() *StationManager {
+func NewStationManager(clock *Clock, config Config) *StationManager {
+	if config.Platforms < 1 {
+		config.Platforms = 1
+	}
+	if config.Policy == nil {
+		config.Policy = PriorityFirst
+	}
 	return &StationManager{
-		isPlatformFree: true,
+		clock:     clock,
+		config:    config,
+		platforms: make([]*Train, config.Platforms),
 	}
 }
 
-func (s *StationManager) canArrive(train Train) bool {
-	if s.isPlatformFree {
-		s.isPlatformFree = false
-		return true
+func (s *StationManager) canArrive(train *Train) bool {
+	for i, t := range s.platforms {
+		if t == nil {
+			s.dock(train, i)
+			return true
+		}
 	}
-	s.trainQueue = append(s.trainQueue, train)
+	s.queue = append(s.queue, &Waiting{Train: train, Since: s.clock.Now()})
 	return false
 }
 
-func (s *StationManager) notifyAboutDeparture() {
-	if !s.isPlatformFree {
-		s.isPlatformFree = true
+func (s *StationManager) notifyAboutDeparture(train *Train) {
+	i := train.platform - 1
+	s.platforms[i] = nil
+	if len(s.queue) > 0 {
+		next := 0
+		for j, w := range s.queue {
+			if s.config.Policy(w, s.queue[next]) < 0 {
+				next = j
+			}
+		}
+		w := s.queue[next]
+		s.queue = slices.Delete(s.queue, next, next+1)
+		s.dock(w.Train, i)
+		w.Train.permitArrival()
+	}
+}
+
+// dock puts the train on platform i and schedules its departure: on time
+// if it can, after MinDwell if it is late, and after MaxDwell at the latest.
+func (s *StationManager) dock(train *Train, i int) {
+	now := s.clock.Now()
+	s.platforms[i] = train
+	train.platform = i + 1
+	train.arrived = now
+
+	departure := train.day.Add(train.Departure)
+	if earliest := now.Add(s.config.MinDwell); departure.Before(earliest) {
+		departure = earliest
 	}
-	if len(s.trainQueue) > 0 {
-		firstTrainQueue := s.trainQueue[0]
-		s.trainQueue = s.trainQueue[1:]
-		firstTrainQueue.permitArrival()
+	if limit, ok := s.config.MaxDwell[train.Kind]; ok && departure.Sub(now) > limit {
+		departure = now.Add(limit)
 	}
+	s.clock.At(departure, train.depart)
+}
+
+// Waiting returns the trains waiting for a platform, in no particular order.
+func (s *StationManager) Waiting() []*Waiting {
+	return slices.Clone(s.queue)
 }
 
+//go:embed timetable.csv
+var defaultTimetable string
+
 func main() {
-	stationManager := NewStationManager()
+	timetablePath := flag.String("timetable", "", "timetable CSV file, the built-in one by default")
+	platforms := flag.Int("platforms", 2, "number of platforms")
+	policy := flag.String("policy", "priority", "priority or fifo")
+	verbose := flag.Bool("v", false, "log every arrival and departure")
+	flag.Parse()
 
-	passengerTrain := &PassengerTrain{
-		mediator: stationManager,
+	var timetable Timetable
+	var err error
+	if *timetablePath != "" {
+		timetable, err = LoadTimetable(*timetablePath)
+	} else {
+		timetable, err = ParseTimetable(strings.NewReader(defaultTimetable))
 	}
-	freightTrain := &FreightTrain{
-		mediator: stationManager,
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	passengerTrain.arrive()
-	freightTrain.arrive()
-	passengerTrain.depart()
+	config := Config{
+		Platforms: *platforms,
+		MinDwell:  2 * time.Minute,
+		MaxDwell:  map[Kind]time.Duration{Passenger: 10 * time.Minute, Freight: 45 * time.Minute},
+	}
+	switch *policy {
+	case "priority":
+		config.Policy = PriorityFirst
+	case "fifo":
+		config.Policy = FirstCome
+	default:
+		log.Fatalf("unknown policy %q", *policy)
+	}
+
+	var out io.Writer
+	if *verbose {
+		out = os.Stdout
+	}
+	day := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	report := NewSimulation(day, timetable, config, out).Run()
+	fmt.Print(report)
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Simulation runs a day of the timetable on a virtual clock. Every train
+// asks the station manager for a platform at its scheduled arrival time.
+type Simulation struct {
+	Clock   *Clock
+	Station *StationManager
+	day     time.Time
+	trains  []*Train
+}
+
+// NewSimulation schedules the timetable from day, which should be a
+// midnight. Arrivals and departures are logged to log, if it is not nil.
+func NewSimulation(day time.Time, timetable Timetable, config Config, log io.Writer) *Simulation {
+	clock := NewClock(day)
+	sim := &Simulation{Clock: clock, Station: NewStationManager(clock, config), day: day}
+	for _, e := range timetable {
+		t := &Train{Entry: e, mediator: sim.Station, clock: clock, log: log, day: day}
+		sim.trains = append(sim.trains, t)
+		clock.At(day.Add(e.Arrival), t.arrive)
+	}
+	return sim
+}
+
+// Run simulates until every train has left, and reports the delays.
+func (s *Simulation) Run() Report {
+	s.Clock.Run()
+	return s.Report()
+}
+
+// Report describes every train as far as the simulation has got. Trains
+// that have not arrived or left yet have a zero time.
+func (s *Simulation) Report() Report {
+	r := Report{Day: s.day}
+	for _, t := range s.trains {
+		row := ReportRow{Entry: t.Entry, Platform: t.platform, Arrived: t.arrived, Departed: t.departed}
+		if !t.arrived.IsZero() {
+			row.ArrivalDelay = t.arrived.Sub(s.day.Add(t.Arrival))
+		}
+		if !t.departed.IsZero() {
+			row.DepartureDelay = t.departed.Sub(s.day.Add(t.Departure))
+		}
+		r.Rows = append(r.Rows, row)
+	}
+	return r
+}
+
+type ReportRow struct {
+	Entry
+	Platform int
+	Arrived  time.Time
+	Departed time.Time
+	// ArrivalDelay is how long the train waited for a platform.
+	ArrivalDelay time.Duration
+	// DepartureDelay is negative when MaxDwell sent the train off early.
+	DepartureDelay time.Duration
+}
+
+type Report struct {
+	Day  time.Time
+	Rows []ReportRow
+}
+
+// Stats sums up the arrival delays of a group of trains.
+type Stats struct {
+	Trains       int
+	Delayed      int // arrived late
+	TotalDelay   time.Duration
+	MaxDelay     time.Duration
+	AverageDelay time.Duration
+}
+
+// Stats sums up the trains of the given kinds, or every train if no kind is
+// given.
+func (r Report) Stats(kinds ...Kind) Stats {
+	var s Stats
+	for _, row := range r.Rows {
+		if len(kinds) > 0 && !slices.Contains(kinds, row.Kind) {
+			continue
+		}
+		s.Trains++
+		if row.ArrivalDelay > 0 {
+			s.Delayed++
+		}
+		s.TotalDelay += row.ArrivalDelay
+		s.MaxDelay = max(s.MaxDelay, row.ArrivalDelay)
+	}
+	if s.Trains > 0 {
+		s.AverageDelay = s.TotalDelay / time.Duration(s.Trains)
+	}
+	return s
+}
+
+func (r Report) String() string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "train\tkind\tplatform\tscheduled\tarrived\tdeparted\tarrival delay\tdeparture delay")
+	for _, row := range r.Rows {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s-%s\t%s\t%s\t%s\t%s\n",
+			row.ID, row.Kind, platformName(row.Platform),
+			clockTime(r.Day.Add(row.Arrival)), clockTime(r.Day.Add(row.Departure)),
+			clockTime(row.Arrived), clockTime(row.Departed), row.ArrivalDelay, row.DepartureDelay)
+	}
+	w.Flush()
+
+	for _, k := range []Kind{Passenger, Freight} {
+		s := r.Stats(k)
+		fmt.Fprintf(&b, "%s: %d trains, %d delayed, average delay %s, max %s\n",
+			k, s.Trains, s.Delayed, s.AverageDelay, s.MaxDelay)
+	}
+	return b.String()
+}
+
+func platformName(p int) string {
+	if p == 0 {
+		return "-"
+	}
+	return fmt.Sprint(p)
+}
+
+func clockTime(t time.Time) string {
+	if t.IsZero() {
+		return "--:--"
+	}
+	return t.Format("15:04")
+}
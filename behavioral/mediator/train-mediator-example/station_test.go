@@ -0,0 +1,167 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testDay = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+func at(hhmm string) time.Duration {
+	d, err := parseTimeOfDay(hhmm)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func simulate(t *testing.T, timetable string, config Config) Report {
+	t.Helper()
+	tt, err := ParseTimetable(strings.NewReader(timetable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSimulation(testDay, tt, config, nil).Run()
+}
+
+func row(t *testing.T, r Report, id string) ReportRow {
+	t.Helper()
+	i := slices.IndexFunc(r.Rows, func(row ReportRow) bool { return row.ID == id })
+	if i < 0 {
+		t.Fatalf("Train %s is not in the report", id)
+	}
+	return r.Rows[i]
+}
+
+func expectArrival(t *testing.T, r Report, id, expected string, platform int) {
+	t.Helper()
+	row := row(t, r, id)
+	if got := clockTime(row.Arrived); got != expected || row.Platform != platform {
+		t.Errorf("Expected %s to arrive at %s on platform %d, got %s on platform %d", id, expected, platform, got, row.Platform)
+	}
+}
+
+func TestClock(t *testing.T) {
+	clock := NewClock(testDay)
+	var order []string
+	log := func(name string) func() {
+		return func() { order = append(order, clockTime(clock.Now())+" "+name) }
+	}
+	clock.At(testDay.Add(at("08:00")), log("b"))
+	clock.At(testDay.Add(at("07:00")), log("a"))
+	clock.At(testDay.Add(at("08:00")), log("c"))
+	clock.At(testDay.Add(at("09:00")), func() {
+		clock.After(time.Hour, log("e"))
+		clock.At(testDay, log("d")) // in the past: runs now
+	})
+
+	if n := clock.RunUntil(testDay.Add(at("08:00"))); n != 3 {
+		t.Errorf("Expected 3 events up to 08:00, ran %d", n)
+	}
+	clock.Run()
+	expected := []string{"07:00 a", "08:00 b", "08:00 c", "09:00 d", "10:00 e"}
+	if !slices.Equal(order, expected) {
+		t.Errorf("Expected %v, got %v", expected, order)
+	}
+}
+
+func TestParseTimetable(t *testing.T) {
+	tt, err := ParseTimetable(strings.NewReader("# id, kind, arrival, departure\nP1, Passenger, 6:00, 06:05:30\n\nF1,freight,23:59,23:59\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Timetable{
+		{ID: "P1", Kind: Passenger, Arrival: 6 * time.Hour, Departure: 6*time.Hour + 5*time.Minute + 30*time.Second},
+		{ID: "F1", Kind: Freight, Arrival: at("23:59"), Departure: at("23:59")},
+	}
+	if !slices.Equal(tt, expected) {
+		t.Errorf("Expected %v, got %v", expected, tt)
+	}
+
+	for _, bad := range []struct{ input, err string }{
+		{"P1, passenger, 06:00, 05:00", "line 1: train P1 departs before it arrives"},
+		{"P1, passenger, 06:00, 06:05\nP1, passenger, 07:00, 07:05", "line 2: duplicate train P1"},
+		{"P1, tram, 06:00, 06:05", `line 1: unknown kind of train "tram"`},
+		{"P1, passenger, 24:00, 24:05", `line 1: invalid time "24:00"`},
+		{"P1, passenger, 06:00", "wrong number of fields"},
+	} {
+		if _, err := ParseTimetable(strings.NewReader(bad.input)); err == nil || !strings.Contains(err.Error(), bad.err) {
+			t.Errorf("Expected an error with %q for %q, got %v", bad.err, bad.input, err)
+		}
+	}
+}
+
+// P1 holds the only platform while a freight train and then a passenger
+// train arrive.
+const contended = `
+P1, passenger, 06:00, 06:10
+F1, freight,   06:01, 06:20
+P2, passenger, 06:02, 06:05
+`
+
+func TestStation_PriorityFirst(t *testing.T) {
+	r := simulate(t, contended, Config{Platforms: 1, MinDwell: 2 * time.Minute, Policy: PriorityFirst})
+	expectArrival(t, r, "P1", "06:00", 1)
+	expectArrival(t, r, "P2", "06:10", 1)
+	expectArrival(t, r, "F1", "06:12", 1) // P2 is late and dwells the minimum
+}
+
+func TestStation_FirstCome(t *testing.T) {
+	r := simulate(t, contended, Config{Platforms: 1, MinDwell: 2 * time.Minute, Policy: FirstCome})
+	expectArrival(t, r, "F1", "06:10", 1)
+	expectArrival(t, r, "P2", "06:20", 1)
+}
+
+func TestStation_Platforms(t *testing.T) {
+	r := simulate(t, contended, Config{Platforms: 3})
+	expectArrival(t, r, "P1", "06:00", 1)
+	expectArrival(t, r, "F1", "06:01", 2)
+	expectArrival(t, r, "P2", "06:02", 3)
+	if s := r.Stats(); s.Delayed != 0 {
+		t.Errorf("Expected no delays with a platform each, got %+v", s)
+	}
+}
+
+func TestStation_Dwell(t *testing.T) {
+	config := Config{
+		Platforms: 1,
+		MinDwell:  3 * time.Minute,
+		MaxDwell:  map[Kind]time.Duration{Freight: 15 * time.Minute},
+	}
+	r := simulate(t, "F1, freight, 06:00, 07:00\nP1, passenger, 06:05, 06:10\n", config)
+
+	f1 := row(t, r, "F1")
+	if f1.Departed.Sub(f1.Arrived) != 15*time.Minute || f1.DepartureDelay != -45*time.Minute {
+		t.Errorf("Expected F1 to be sent off after 15 minutes, got %+v", f1)
+	}
+	// P1 is 10 minutes late and catches up 2 minutes by dwelling 3 minutes
+	p1 := row(t, r, "P1")
+	if p1.ArrivalDelay != 10*time.Minute || p1.DepartureDelay != 8*time.Minute {
+		t.Errorf("Expected P1 to dwell the minimum, got %+v", p1)
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := simulate(t, contended, Config{Platforms: 1, MinDwell: 2 * time.Minute, Policy: PriorityFirst})
+
+	all := r.Stats()
+	if all.Trains != 3 || all.Delayed != 2 || all.TotalDelay != 19*time.Minute || all.MaxDelay != 11*time.Minute {
+		t.Errorf("Unexpected stats %+v", all)
+	}
+	freight := r.Stats(Freight)
+	if freight.Trains != 1 || freight.AverageDelay != 11*time.Minute {
+		t.Errorf("Unexpected freight stats %+v", freight)
+	}
+	if s := r.String(); !strings.Contains(s, "passenger: 2 trains, 1 delayed, average delay 4m0s, max 8m0s") {
+		t.Errorf("Expected a summary per kind, got\n%s", s)
+	}
+
+	// a report in the middle of the day
+	sim := NewSimulation(testDay, Timetable{{ID: "P1", Kind: Passenger, Arrival: at("06:00"), Departure: at("06:05")}}, Config{}, nil)
+	sim.Clock.RunUntil(testDay.Add(at("06:01")))
+	if p1 := sim.Report().Rows[0]; p1.Platform != 1 || !p1.Departed.IsZero() {
+		t.Errorf("Expected P1 at the platform, got %+v", p1)
+	}
+}
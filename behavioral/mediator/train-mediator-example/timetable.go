@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Kind int
+
+const (
+	Freight Kind = iota
+	Passenger
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Freight:
+		return "freight"
+	case Passenger:
+		return "passenger"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+func ParseKind(s string) (Kind, error) {
+	switch strings.ToLower(s) {
+	case "freight":
+		return Freight, nil
+	case "passenger":
+		return Passenger, nil
+	}
+	return 0, fmt.Errorf("unknown kind of train %q", s)
+}
+
+// Priority orders waiting trains under PriorityFirst: passenger trains go
+// before freight trains.
+func (k Kind) Priority() int {
+	if k == Passenger {
+		return 1
+	}
+	return 0
+}
+
+// Entry is one train of the timetable. Arrival and Departure are offsets
+// from the start of the day.
+type Entry struct {
+	ID        string
+	Kind      Kind
+	Arrival   time.Duration
+	Departure time.Duration
+}
+
+type Timetable []Entry
+
+// ParseTimetable reads a timetable in CSV, one train per line:
+//
+//	# id, kind, arrival, departure
+//	P101, passenger, 06:00, 06:05
+//	F7,   freight,   06:02, 06:40
+//
+// Times are HH:MM or HH:MM:SS. Lines starting with # are comments.
+func ParseTimetable(r io.Reader) (Timetable, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 4
+	cr.TrimLeadingSpace = true
+
+	var timetable Timetable
+	seen := make(map[string]bool)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return timetable, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		e, err := parseEntry(record)
+		if err != nil {
+			return nil, fmt.Errorf("timetable line %d: %w", line, err)
+		}
+		if seen[e.ID] {
+			return nil, fmt.Errorf("timetable line %d: duplicate train %s", line, e.ID)
+		}
+		seen[e.ID] = true
+		timetable = append(timetable, e)
+	}
+}
+
+func LoadTimetable(path string) (Timetable, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseTimetable(f)
+}
+
+func parseEntry(record []string) (Entry, error) {
+	var e Entry
+	var err error
+	e.ID = strings.TrimSpace(record[0])
+	if e.ID == "" {
+		return e, errors.New("missing train id")
+	}
+	if e.Kind, err = ParseKind(strings.TrimSpace(record[1])); err != nil {
+		return e, err
+	}
+	if e.Arrival, err = parseTimeOfDay(record[2]); err != nil {
+		return e, err
+	}
+	if e.Departure, err = parseTimeOfDay(record[3]); err != nil {
+		return e, err
+	}
+	if e.Departure < e.Arrival {
+		return e, fmt.Errorf("train %s departs before it arrives", e.ID)
+	}
+	return e, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	limits := []int{24, 60, 60}
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || n >= limits[i] {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		d += time.Duration(n) * units[i]
+	}
+	return d, nil
+}
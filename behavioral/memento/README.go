@@ -11,47 +11,87 @@
 ```go
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrForeignMemento = errors.New("memento belongs to another originator")
+	ErrEmptyMemento   = errors.New("memento was not taken by an originator")
+)
 
 // The State type is the core business object
 type State struct {
 	Description string
 }
 
+// memento remembers which originator took it, and when.
 type memento struct {
-	state State
+	state  State
+	origin any
+	taken  time.Time
 }
 
 type originator struct {
 	state State
+	now   func() time.Time // time.Now if nil
 }
 
 func (o *originator) NewMemento() memento {
-	return memento{state: o.state}
-}
-
-func (o *originator) ExtractAndStoreState(m memento) {
+	now := time.Now
+	if o.now != nil {
+		now = o.now
+	}
+	return memento{state: o.state, origin: o, taken: now()}
+}
+
+// ExtractAndStoreState refuses a memento taken by another originator, of
+// this type or not, and the zero memento, which would wipe the state.
+func (o *originator) ExtractAndStoreState(m memento) error {
+	switch origin := m.origin.(type) {
+	case nil:
+		return ErrEmptyMemento
+	case *originator:
+		if origin != o {
+			return fmt.Errorf("%w: another instance", ErrForeignMemento)
+		}
+	default:
+		return fmt.Errorf("%w: a %T", ErrForeignMemento, origin)
+	}
 	o.state = m.state
+	return nil
 }
 
+// careTaker keeps the last limit mementos, or all of them if limit is 0.
 type careTaker struct {
-	mementoList []memento
+	mementoList []Checkpoint
+	limit       int
 }
 
 func (c *careTaker) Add(m memento) {
-	c.mementoList = append(c.mementoList, m)
+	c.add(Checkpoint{memento: m})
+}
+
+func (c *careTaker) add(cp Checkpoint) {
+	c.mementoList = append(c.mementoList, cp)
+	if c.limit > 0 && len(c.mementoList) > c.limit {
+		c.mementoList = c.mementoList[len(c.mementoList)-c.limit:]
+	}
 }
 
 func (c *careTaker) Memento(i int) (memento, error) {
-	if len(c.mementoList) < i || i < 0 {
+	if len(c.mementoList) <= i || i < 0 {
 		return memento{}, fmt.Errorf("Index not found\n")
 	}
-	return c.mementoList[i], nil
+	return c.mementoList[i].memento, nil
 }
 
 func main() {
 	state := State{"Short description"}
-	originator := originator{state}
+	originator := originator{state: state}
 
 	fmt.Println(state)
 
@@ -61,90 +101,111 @@ func main() {
 
 	fmt.Println(state)
 
-	m, _ := careTaker.Memento(0)
-	originator.ExtractAndStoreState(m)
+	m, err := careTaker.Memento(0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := originator.ExtractAndStoreState(m); err != nil {
+		log.Fatal(err)
+	}
 	state = originator.state
 
 	fmt.Println(state)
+
+	checkpoints()
 }
+
 ```
 
-### Undo Redo
+#### Named checkpoints
 
-```go
-package main
+Each memento records when it was taken and which originator took it. `ExtractAndStoreState` returns `ErrForeignMemento` when the memento was taken by another originator, whether of another type or another instance, and `ErrEmptyMemento` for the zero memento, which would wipe the state. The careTaker can give a memento a name. It finds mementos by name, or by time with `AsOf`, which returns the last memento taken at or before that time. `Checkpoints` lists the mementos with their names and times. `Compare` lists the fields of `State` that differ between two mementos.
 
-import "fmt"
+```go
+careTaker.Checkpoint("before-import", document.NewMemento())
+// ... import ...
+careTaker.Checkpoint("release-1.2", document.NewMemento())
 
-type Memento struct {
-	Balance int
+asOf, err := careTaker.AsOf(time.Date(2024, 5, 2, 14, 5, 0, 0, time.Local))
+if err != nil {
+	log.Fatal(err)
 }
-
-type BankAccount struct {
-	balance int
-	changes []*Memento
-	current int
+if err := document.ExtractAndStoreState(asOf); err != nil {
+	log.Fatal(err)
 }
+// the state as of 14:05
 
-func NewBankAccount(balance int) *BankAccount {
-	b := &BankAccount{balance: balance}
-	b.changes = append(b.changes, &Memento{balance})
-	return b
-}
+before, _ := careTaker.Named("before-import")
+release, _ := careTaker.Named("release-1.2")
+fmt.Println(Compare(before, release)) // [Description: Empty -> Release notes]
+```
 
-func (b *BankAccount) String() string {
-	return fmt.Sprint("Balance = $", b.balance)
-}
+### Undo Redo
 
-func (b *BankAccount) Deposit(amount int) *Memento {
-	b.balance += amount
-	m := Memento{b.balance}
-	b.changes = append(b.changes, &m)
-	b.current++
-	fmt.Println("Deposited", amount, "\b, balance is now", b.balance)
-	return &m
-}
+The bank account keeps its balances in a `History[T]`, a generic caretaker that stores the states as an undo tree. A deposit made after an undo starts a new branch, so the redo states are kept. `Redo` follows the branch the last `Undo` came from. `Goto` jumps to any state, and `Branches` lists the tip of every branch.
 
-func (b *BankAccount) Restore(m *Memento) {
-	if m != nil {
-		b.balance = m.Balance
-		b.changes = append(b.changes, m)
-		b.current = len(b.changes) - 1
-	}
-}
+```go
+h := NewHistory(100, 50, DropStaleBranches) // keep at most 50 balances
+h.Save(150)
+top := h.Save(175)
+h.Undo()          // 150
+h.Save(160)       // a second branch: [100 150 175] and [100 150 160]
+h.Goto(top.ID())  // back to 175
+```
 
-func (b *BankAccount) Undo() *Memento {
-	if b.current > 0 {
-		b.current--
-		m := b.changes[b.current]
-		b.balance = m.Balance
-		return m
-	}
-	return nil
-}
+When the history is full it forgets a state according to its policy:
 
-func (b *BankAccount) Redo() *Memento {
-	if b.current+1 < len(b.changes) {
-		b.current++
-		m := b.changes[b.current]
-		b.balance = m.Balance
-		return m
-	}
+- `DropOldest` forgets the root, together with the branches that split off there.
+- `DropStaleBranches` first prunes the tips of the branches visited least recently.
+
+`Restore` takes the account back to a memento without adding a state, so undo and redo keep working afterwards.
+
+#### Persistent history
+
+A `Store[T]` writes a memento to a file so that undo history survives restarts. Each snapshot starts with a JSON header that holds its format (`JSON` or `Gob`) and its schema version. The payload follows the header. Every `Save` writes to a temporary file, syncs it, and renames it over the old one. A crash therefore leaves either the old snapshot or the new one, never a mix.
+
+When a snapshot is older than the store's version, `Load` upgrades it with the registered migrations, one version at a time:
+
+```go
+store := NewStore[HistorySnapshot[int]]("account.json", JSON, 2)
+store.Register(1, MigrateJSON(func(o map[string]any) error {
+	o["Capacity"] = 100 // version 1 had no capacity
 	return nil
-}
+}))
 
-func main() {
-	ba := NewBankAccount(100)
-	fmt.Println(ba)
-	ba.Deposit(50)
-	ba.Deposit(25)
-	fmt.Println(ba)
-
-	ba.Undo()
-	fmt.Println("Undo 1", ba)
-	ba.Undo()
-	fmt.Println("Undo 2", ba)
-	ba.Redo()
-	fmt.Println("Redo 1", ba)
+account, err := OpenBankAccount(store, 100) // the saved history, or a new one
+account.Deposit(10)
+err = account.Save(store)
+```
+
+Run `go run . -state account.json` a few times, then `go run . -state account.json -undo`.
+
+### Delta mementos
+
+A `DeltaHistory[T]` stores a full copy of a state only once every N saves. These full copies are the checkpoints. For every other save it stores only the delta from the previous state. A `Differ[T]` computes the deltas:
+
+- `TextDiffer` diffs strings by lines or by characters, using the linear-space variant of Myers' algorithm.
+- `JSONDiffer[T]` diffs any JSON-encodable struct field by field, and produces a JSON-patch-style list of `add`, `remove` and `replace` operations. It is also a `Cloner[T]`, so the history keeps its own copy of each state and the caller may go on changing the value it saved.
+
+```go
+h := NewDeltaHistory[Document](JSONDiffer[Document]{}, 10) // a checkpoint every 10 states
+h.Save(doc.CreateMemento())
+...
+doc.Restore(must(h.Restore(3))) // checkpoint 0, then the deltas 1, 2 and 3
+```
+
+Restoring a state applies at most N-1 deltas to the checkpoint before it, so N trades memory for restore time. `FullHistory[T]` keeps every state whole, for comparison. `go test -bench .` saves 200 small edits of a 2000 line text. It reports the memory each strategy keeps (`retained-B`) and the time to restore a state.
+
+#### Sealed snapshots
+
+A snapshot can hold sensitive state. `Seal` gives a store a 32-byte key. From then on, `Save` encrypts the payload with AES-256-GCM and signs the whole file, header included, with HMAC-SHA256. Two separate keys for these are derived from the caller's key.
+
+```go
+store := NewAccountStore("account.json", JSON)
+if err := store.Seal(key); err != nil { // key is KeySize bytes, e.g. from a secret manager
+	return err
 }
+account, err := OpenBankAccount(store, 100)
 ```
+
+`Load` checks the signature before it decrypts or migrates anything. It fails with `ErrTampered` in three cases: the snapshot was changed, truncated, or signed with another key; or a plain snapshot was put in place of a sealed one. A store without a key fails with `ErrSealed` on a sealed snapshot. The example seals its file when `MEMENTO_KEY` holds a hex key: `MEMENTO_KEY=$(openssl rand -hex 32) go run . -state account.json`.
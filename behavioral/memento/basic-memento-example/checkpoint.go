@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var ErrNoCheckpoint = errors.New("no such checkpoint")
+
+// Checkpoint is a memento as the careTaker keeps it, with an optional name
+// such as "before-import" or "release-1.2".
+type Checkpoint struct {
+	Name string
+	memento
+}
+
+func (cp Checkpoint) Time() time.Time { return cp.taken }
+func (cp Checkpoint) State() State    { return cp.state }
+
+func (cp Checkpoint) String() string {
+	name := cp.Name
+	if name == "" {
+		name = "-"
+	}
+	return fmt.Sprintf("%s %s %T %+v", name, cp.taken.Format(time.DateTime), cp.origin, cp.state)
+}
+
+// Checkpoint adds a named memento. Names are unique among the mementos the
+// careTaker still keeps.
+func (c *careTaker) Checkpoint(name string, m memento) error {
+	if name == "" {
+		return errors.New("a checkpoint needs a name")
+	}
+	if _, err := c.Named(name); err == nil {
+		return fmt.Errorf("checkpoint %q already exists", name)
+	}
+	c.add(Checkpoint{Name: name, memento: m})
+	return nil
+}
+
+func (c *careTaker) Named(name string) (memento, error) {
+	for _, cp := range c.mementoList {
+		if cp.Name == name {
+			return cp.memento, nil
+		}
+	}
+	return memento{}, fmt.Errorf("%w: %q", ErrNoCheckpoint, name)
+}
+
+// AsOf returns the state as of t: the memento taken last at or before t.
+func (c *careTaker) AsOf(t time.Time) (memento, error) {
+	var found *Checkpoint
+	for i, cp := range c.mementoList {
+		if !cp.taken.After(t) && (found == nil || !cp.taken.Before(found.taken)) {
+			found = &c.mementoList[i]
+		}
+	}
+	if found == nil {
+		return memento{}, fmt.Errorf("%w as of %s", ErrNoCheckpoint, t.Format(time.DateTime))
+	}
+	return found.memento, nil
+}
+
+// Checkpoints lists every memento kept, named or not, oldest first.
+func (c *careTaker) Checkpoints() []Checkpoint {
+	return append([]Checkpoint(nil), c.mementoList...)
+}
+
+// FieldChange is a field of State that differs between two mementos.
+type FieldChange struct {
+	Field    string
+	From, To any
+}
+
+func (f FieldChange) String() string {
+	return fmt.Sprintf("%s: %v -> %v", f.Field, f.From, f.To)
+}
+
+// Compare lists the fields of State that differ from a to b, in the order
+// they are declared.
+func Compare(a, b memento) []FieldChange {
+	var changes []FieldChange
+	va, vb := reflect.ValueOf(a.state), reflect.ValueOf(b.state)
+	for i := range va.NumField() {
+		from, to := va.Field(i).Interface(), vb.Field(i).Interface()
+		if !reflect.DeepEqual(from, to) {
+			changes = append(changes, FieldChange{va.Type().Field(i).Name, from, to})
+		}
+	}
+	return changes
+}
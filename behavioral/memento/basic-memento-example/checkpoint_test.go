@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// ticking returns an originator whose mementos are taken a minute apart,
+// the first at 14:01.
+func ticking(description string) *originator {
+	clock := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	return &originator{state: State{description}, now: func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}}
+}
+
+func at(hour, minute int) time.Time {
+	return time.Date(2024, 5, 2, hour, minute, 0, 0, time.UTC)
+}
+
+func TestCareTaker_Checkpoint(t *testing.T) {
+	o := ticking("one")
+	careTaker := careTaker{}
+	if err := careTaker.Checkpoint("first", o.NewMemento()); err != nil {
+		t.Fatal(err)
+	}
+	o.state.Description = "two"
+	careTaker.Add(o.NewMemento())
+	if err := careTaker.Checkpoint("first", o.NewMemento()); err == nil {
+		t.Error("Expected an error for a duplicate name")
+	}
+	if err := careTaker.Checkpoint("", o.NewMemento()); err == nil {
+		t.Error("Expected an error for an empty name")
+	}
+
+	mem, err := careTaker.Named("first")
+	if err != nil || mem.state.Description != "one" {
+		t.Errorf("Expected the first checkpoint, got %v, %v", mem.state, err)
+	}
+	if _, err := careTaker.Named("missing"); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("Expected ErrNoCheckpoint, got %v", err)
+	}
+
+	list := careTaker.Checkpoints()
+	if len(list) != 2 || list[0].Name != "first" || list[1].Name != "" {
+		t.Fatalf("Unexpected checkpoints %v", list)
+	}
+	if !list[0].Time().Equal(at(14, 1)) || list[1].State().Description != "two" {
+		t.Errorf("Unexpected metadata %v", list)
+	}
+}
+
+func TestCareTaker_CheckpointEvicted(t *testing.T) {
+	o := ticking("one")
+	careTaker := careTaker{limit: 1}
+	careTaker.Checkpoint("first", o.NewMemento())
+	careTaker.Add(o.NewMemento())
+
+	if _, err := careTaker.Named("first"); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("Expected the name to go with its memento, got %v", err)
+	}
+	if err := careTaker.Checkpoint("first", o.NewMemento()); err != nil {
+		t.Errorf("Expected the name to be free again, got %v", err)
+	}
+}
+
+func TestCareTaker_AsOf(t *testing.T) {
+	o := ticking("")
+	careTaker := careTaker{}
+	for _, description := range []string{"14:01", "14:02", "14:03"} {
+		o.state.Description = description
+		careTaker.Add(o.NewMemento())
+	}
+
+	tests := []struct {
+		asOf     time.Time
+		expected string
+	}{
+		{at(14, 1), "14:01"},
+		{at(14, 2).Add(30 * time.Second), "14:02"},
+		{at(15, 0), "14:03"},
+	}
+	for _, test := range tests {
+		mem, err := careTaker.AsOf(test.asOf)
+		if err != nil || mem.state.Description != test.expected {
+			t.Errorf("Expected the state of %s as of %v, got %q, %v", test.expected, test.asOf, mem.state.Description, err)
+		}
+	}
+	if _, err := careTaker.AsOf(at(14, 0)); !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("Expected ErrNoCheckpoint before the first memento, got %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a := memento{state: State{"one"}}
+	if changes := Compare(a, a); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+	changes := Compare(a, memento{state: State{"two"}})
+	if len(changes) != 1 || changes[0].String() != "Description: one -> two" {
+		t.Errorf("Unexpected changes %v", changes)
+	}
+}
+
+type otherOriginator struct{}
+
+func TestOriginator_RefusesForeignMemento(t *testing.T) {
+	o, other := ticking("mine"), ticking("theirs")
+
+	if err := o.ExtractAndStoreState(other.NewMemento()); !errors.Is(err, ErrForeignMemento) {
+		t.Errorf("Expected ErrForeignMemento for another instance, got %v", err)
+	}
+	if err := o.ExtractAndStoreState(memento{state: State{"x"}, origin: &otherOriginator{}}); !errors.Is(err, ErrForeignMemento) {
+		t.Errorf("Expected ErrForeignMemento for another type, got %v", err)
+	}
+	if err := o.ExtractAndStoreState(memento{}); !errors.Is(err, ErrEmptyMemento) {
+		t.Errorf("Expected ErrEmptyMemento for the zero memento, got %v", err)
+	}
+	if o.state.Description != "mine" {
+		t.Errorf("Expected the state to be unchanged, got %q", o.state.Description)
+	}
+	if err := o.ExtractAndStoreState(o.NewMemento()); err != nil {
+		t.Errorf("Expected its own memento to be accepted, got %v", err)
+	}
+}
@@ -1,46 +1,86 @@
 package main
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+var (
+	ErrForeignMemento = errors.New("memento belongs to another originator")
+	ErrEmptyMemento   = errors.New("memento was not taken by an originator")
+)
 
 // The State type is the core business object
 type State struct {
 	Description string
 }
 
+// memento remembers which originator took it, and when.
 type memento struct {
-	state State
+	state  State
+	origin any
+	taken  time.Time
 }
 
 type originator struct {
 	state State
+	now   func() time.Time // time.Now if nil
 }
 
 func (o *originator) NewMemento() memento {
-	return memento{state: o.state}
+	now := time.Now
+	if o.now != nil {
+		now = o.now
+	}
+	return memento{state: o.state, origin: o, taken: now()}
 }
 
-func (o *originator) ExtractAndStoreState(m memento) {
+// ExtractAndStoreState refuses a memento taken by another originator, of
+// this type or not, and the zero memento, which would wipe the state.
+func (o *originator) ExtractAndStoreState(m memento) error {
+	switch origin := m.origin.(type) {
+	case nil:
+		return ErrEmptyMemento
+	case *originator:
+		if origin != o {
+			return fmt.Errorf("%w: another instance", ErrForeignMemento)
+		}
+	default:
+		return fmt.Errorf("%w: a %T", ErrForeignMemento, origin)
+	}
 	o.state = m.state
+	return nil
 }
 
+// careTaker keeps the last limit mementos, or all of them if limit is 0.
 type careTaker struct {
-	mementoList []memento
+	mementoList []Checkpoint
+	limit       int
 }
 
 func (c *careTaker) Add(m memento) {
-	c.mementoList = append(c.mementoList, m)
+	c.add(Checkpoint{memento: m})
+}
+
+func (c *careTaker) add(cp Checkpoint) {
+	c.mementoList = append(c.mementoList, cp)
+	if c.limit > 0 && len(c.mementoList) > c.limit {
+		c.mementoList = c.mementoList[len(c.mementoList)-c.limit:]
+	}
 }
 
 func (c *careTaker) Memento(i int) (memento, error) {
-	if len(c.mementoList) < i || i < 0 {
+	if len(c.mementoList) <= i || i < 0 {
 		return memento{}, fmt.Errorf("Index not found\n")
 	}
-	return c.mementoList[i], nil
+	return c.mementoList[i].memento, nil
 }
 
 func main() {
 	state := State{"Short description"}
-	originator := originator{state}
+	originator := originator{state: state}
 
 	fmt.Println(state)
 
@@ -50,9 +90,68 @@ func main() {
 
 	fmt.Println(state)
 
-	m, _ := careTaker.Memento(0)
-	originator.ExtractAndStoreState(m)
+	m, err := careTaker.Memento(0)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := originator.ExtractAndStoreState(m); err != nil {
+		log.Fatal(err)
+	}
 	state = originator.state
 
 	fmt.Println(state)
+
+	checkpoints()
+}
+
+// checkpoints names mementos and goes back in time.
+func checkpoints() {
+	clock := time.Date(2024, 5, 2, 14, 0, 0, 0, time.Local)
+	document := originator{
+		state: State{"Empty"},
+		now: func() time.Time {
+			clock = clock.Add(3 * time.Minute)
+			return clock
+		},
+	}
+	careTaker := careTaker{}
+
+	if err := careTaker.Checkpoint("before-import", document.NewMemento()); err != nil { // 14:03
+		log.Fatal(err)
+	}
+	document.state.Description = "Imported 120 rows"
+	careTaker.Add(document.NewMemento()) // 14:06
+	document.state.Description = "Release notes"
+	if err := careTaker.Checkpoint("release-1.2", document.NewMemento()); err != nil { // 14:09
+		log.Fatal(err)
+	}
+
+	fmt.Println("Checkpoints:")
+	for _, cp := range careTaker.Checkpoints() {
+		fmt.Println(" ", cp)
+	}
+
+	asOf, err := careTaker.AsOf(time.Date(2024, 5, 2, 14, 5, 0, 0, time.Local))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := document.ExtractAndStoreState(asOf); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("As of 14:05:", document.state)
+
+	before, err := careTaker.Named("before-import")
+	if err != nil {
+		log.Fatal(err)
+	}
+	release, err := careTaker.Named("release-1.2")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("From before-import to release-1.2:", Compare(before, release))
+
+	other := originator{state: State{"Another document"}}
+	if err := other.ExtractAndStoreState(release); err != nil {
+		fmt.Println("Refused:", err)
+	}
 }
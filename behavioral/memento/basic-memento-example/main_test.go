@@ -45,8 +45,34 @@ func TestOriginator_ExtractAndStoreState(t *testing.T) {
 	originator := originator{state: State{"Idle"}}
 	idleMemento := originator.NewMemento()
 
-	originator.ExtractAndStoreState(idleMemento)
+	if err := originator.ExtractAndStoreState(idleMemento); err != nil {
+		t.Fatal(err)
+	}
 	if originator.state.Description != "Idle" {
 		t.Error("Unexpected state found")
 	}
 }
+
+func TestCareTaker_MementoOutOfRange(t *testing.T) {
+	careTaker := careTaker{}
+	careTaker.Add(memento{state: State{"Idle"}})
+
+	if _, err := careTaker.Memento(1); err == nil {
+		t.Error("An error is expected when asking past the last memento")
+	}
+}
+
+func TestCareTaker_Limit(t *testing.T) {
+	careTaker := careTaker{limit: 2}
+	for _, description := range []string{"one", "two", "three"} {
+		careTaker.Add(memento{state: State{description}})
+	}
+
+	if len(careTaker.mementoList) != 2 {
+		t.Fatalf("Expected 2 mementos, got %d", len(careTaker.mementoList))
+	}
+	mem, _ := careTaker.Memento(0)
+	if mem.state.Description != "two" {
+		t.Errorf("Expected the oldest memento to be dropped, got %q", mem.state.Description)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrDeltaMismatch = errors.New("delta does not apply to this state")
+
+// Granularity is the unit a TextDiffer compares.
+type Granularity int
+
+const (
+	Lines Granularity = iota
+	Chars
+)
+
+// EditOp is one step of a text delta: keep or delete N units of the old
+// text, or insert Text.
+type EditOp struct {
+	Kind byte // '=', '-' or '+'
+	N    int
+	Text string
+}
+
+func (op EditOp) String() string {
+	if op.Kind == '+' {
+		return fmt.Sprintf("+%q", op.Text)
+	}
+	return fmt.Sprintf("%c%d", op.Kind, op.N)
+}
+
+type TextDelta []EditOp
+
+func (d TextDelta) String() string {
+	ops := make([]string, len(d))
+	for i, op := range d {
+		ops[i] = op.String()
+	}
+	return strings.Join(ops, " ")
+}
+
+// TextDiffer diffs strings line by line or character by character, with
+// Myers' algorithm. Only the inserted text is stored, so a delta is about
+// as large as the edit.
+type TextDiffer struct {
+	Granularity Granularity
+}
+
+func (d TextDiffer) Diff(from, to string) (any, error) {
+	a, b := d.split(from), d.split(to)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var delta TextDelta
+	delta = appendOp(delta, EditOp{Kind: '=', N: prefix})
+	script := editScript(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])
+	for i := 0; i < len(script); {
+		run := EditOp{Kind: script[i].Kind}
+		// a copy, so that the delta does not keep the whole new text alive
+		var text strings.Builder
+		for ; i < len(script) && script[i].Kind == run.Kind; i++ {
+			run.N += script[i].N
+			text.WriteString(script[i].Text)
+		}
+		run.Text = text.String()
+		delta = appendOp(delta, run)
+	}
+	delta = appendOp(delta, EditOp{Kind: '=', N: suffix})
+	return delta, nil
+}
+
+func (d TextDiffer) Apply(from string, delta any) (string, error) {
+	ops, ok := delta.(TextDelta)
+	if !ok {
+		return "", fmt.Errorf("%w: not a text delta", ErrDeltaMismatch)
+	}
+	units := d.split(from)
+	var b strings.Builder
+	i := 0
+	for _, op := range ops {
+		switch op.Kind {
+		case '=', '-':
+			if i+op.N > len(units) {
+				return "", fmt.Errorf("%w: it is longer than the text", ErrDeltaMismatch)
+			}
+			if op.Kind == '=' {
+				for _, u := range units[i : i+op.N] {
+					b.WriteString(u)
+				}
+			}
+			i += op.N
+		case '+':
+			b.WriteString(op.Text)
+		}
+	}
+	if i != len(units) {
+		return "", fmt.Errorf("%w: it is shorter than the text", ErrDeltaMismatch)
+	}
+	return b.String(), nil
+}
+
+// split cuts s into lines, each with its line break, or into characters.
+func (d TextDiffer) split(s string) []string {
+	if d.Granularity == Chars {
+		units := make([]string, 0, len(s))
+		for _, r := range s {
+			units = append(units, string(r))
+		}
+		return units
+	}
+	return strings.SplitAfter(s, "\n")
+}
+
+// appendOp merges op into the last step when they are of the same kind.
+func appendOp(delta TextDelta, op EditOp) TextDelta {
+	if op.Kind != '+' && op.N == 0 || op.Kind == '+' && op.Text == "" {
+		return delta
+	}
+	if n := len(delta); n > 0 && delta[n-1].Kind == op.Kind {
+		delta[n-1].N += op.N
+		delta[n-1].Text += op.Text
+		return delta
+	}
+	return append(delta, op)
+}
+
+// editScript returns the shortest edit script from a to b, one op per unit.
+// It splits the problem at the middle snake of Myers' algorithm and solves
+// both halves in turn, so it needs space linear in the input and not in the
+// square of the edit distance.
+func editScript(a, b []string) []EditOp {
+	var ops []EditOp
+	var walk func(a, b []string)
+	walk = func(a, b []string) {
+		if len(a) == 0 || len(b) == 0 {
+			ops = appendEdits(ops, a, b)
+			return
+		}
+		x, y, u, v, d := middleSnake(a, b)
+		switch {
+		case d == 0:
+			ops = appendKeeps(ops, len(a))
+		case d == 1:
+			// a and b differ by one unit: keep the common prefix, and the
+			// rest follows the edit
+			prefix := 0
+			for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+				prefix++
+			}
+			ops = appendKeeps(ops, prefix)
+			if len(a) > len(b) {
+				ops = appendEdits(ops, a[prefix:prefix+1], nil)
+			} else {
+				ops = appendEdits(ops, nil, b[prefix:prefix+1])
+			}
+			ops = appendKeeps(ops, min(len(a), len(b))-prefix)
+		default:
+			walk(a[:x], b[:y])
+			ops = appendKeeps(ops, u-x)
+			walk(a[u:], b[v:])
+		}
+	}
+	walk(a, b)
+
+	// the halves may interleave insertions and deletions: put the deletions
+	// of each change first, so that equal edits give equal deltas
+	for i := 0; i < len(ops); {
+		j := i
+		for j < len(ops) && ops[j].Kind != '=' {
+			j++
+		}
+		slices.SortStableFunc(ops[i:j], func(p, q EditOp) int { return int(q.Kind) - int(p.Kind) }) // '-' > '+'
+		i = j + 1
+	}
+	return ops
+}
+
+// middleSnake runs Myers' search from both ends of a and b until the paths
+// meet. It returns the snake where they meet, from (x, y) to (u, v), and
+// the length d of the shortest edit script.
+func middleSnake(a, b []string) (x, y, u, v, d int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	odd := delta%2 != 0
+	limit := (n + m + 1) / 2
+	offset := limit + 1
+	// forward[k] is the furthest x on diagonal x-y = k from the start, and
+	// backward[k] the furthest distance from the end on diagonal
+	// (n-x)-(m-y) = k
+	forward := make([]int, 2*limit+3)
+	backward := make([]int, 2*limit+3)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && forward[offset+k-1] < forward[offset+k+1] {
+				x = forward[offset+k+1] // down: insert b[y-1]
+			} else {
+				x = forward[offset+k-1] + 1 // right: delete a[x-1]
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+			if back := delta - k; odd && back >= -(d-1) && back <= d-1 && x+backward[offset+back] >= n {
+				return startX, startY, x, y, 2*d - 1
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && backward[offset+k-1] < backward[offset+k+1] {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+			if front := delta - k; !odd && front >= -d && front <= d && x+forward[offset+front] >= n {
+				return n - x, m - y, n - startX, m - startY, 2 * d
+			}
+		}
+	}
+	panic("unreachable: the paths always meet")
+}
+
+// appendEdits deletes all of a and inserts all of b.
+func appendEdits(ops []EditOp, a, b []string) []EditOp {
+	for range a {
+		ops = append(ops, EditOp{Kind: '-', N: 1})
+	}
+	for _, unit := range b {
+		ops = append(ops, EditOp{Kind: '+', Text: unit})
+	}
+	return ops
+}
+
+func appendKeeps(ops []EditOp, n int) []EditOp {
+	for range n {
+		ops = append(ops, EditOp{Kind: '=', N: 1})
+	}
+	return ops
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// edit makes a few random insertions, deletions and replacements of words
+// and lines.
+func edit(r *rand.Rand, text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	for range 1 + r.Intn(4) {
+		i := r.Intn(len(lines))
+		switch r.Intn(3) {
+		case 0:
+			lines = append(lines[:i], append([]string{randomLine(r)}, lines[i:]...)...)
+		case 1:
+			if len(lines) > 1 {
+				lines = append(lines[:i], lines[i+1:]...)
+			}
+		case 2:
+			words := strings.Fields(lines[i])
+			if len(words) > 0 {
+				words[r.Intn(len(words))] = randomWord(r)
+			}
+			lines[i] = strings.Join(words, " ") + "\n"
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+func randomWord(r *rand.Rand) string {
+	words := []string{"memento", "caretaker", "originator", "état", "undo", "", "x"}
+	return words[r.Intn(len(words))]
+}
+
+func randomLine(r *rand.Rand) string {
+	words := make([]string, 1+r.Intn(6))
+	for i := range words {
+		words[i] = randomWord(r)
+	}
+	return strings.Join(words, " ") + "\n"
+}
+
+func TestTextDiffer_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, granularity := range []Granularity{Lines, Chars} {
+		d := TextDiffer{Granularity: granularity}
+		text := ""
+		for range 300 {
+			next := edit(r, text)
+			delta, err := d.Diff(text, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := d.Apply(text, delta)
+			if err != nil {
+				t.Fatalf("Apply %v to %q: %v", delta, text, err)
+			}
+			if got != next {
+				t.Fatalf("Expected %q from %q with %v, got %q", next, text, delta, got)
+			}
+			text = next
+		}
+	}
+}
+
+func TestTextDiffer_Delta(t *testing.T) {
+	tests := []struct {
+		granularity Granularity
+		from, to    string
+		expected    string
+	}{
+		{Lines, "a\nb\nc\n", "a\nb\nc\n", "=4"},
+		{Lines, "a\nb\nc\n", "a\nx\nc\n", `=1 -1 +"x\n" =2`},
+		{Lines, "", "a\n", `+"a\n" =1`},
+		{Chars, "kitten", "sitting", `-1 +"s" =3 -1 +"i" =1 +"g"`},
+		{Chars, "état", "étais", `=3 -1 +"is"`},
+		{Chars, "abc", "", "-3"},
+	}
+	for _, test := range tests {
+		delta, _ := TextDiffer{Granularity: test.granularity}.Diff(test.from, test.to)
+		if got := delta.(TextDelta).String(); got != test.expected {
+			t.Errorf("Expected %q -> %q to be %s, got %s", test.from, test.to, test.expected, got)
+		}
+	}
+}
+
+// distance is the length of the shortest edit script from a to b, from their
+// longest common subsequence.
+func distance(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func TestEditScript_Shortest(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []string {
+		units := make([]string, r.Intn(12))
+		for i := range units {
+			units[i] = string(rune('a' + r.Intn(3)))
+		}
+		return units
+	}
+	for range 2000 {
+		a, b := random(), random()
+		ops := editScript(a, b)
+		edits := 0
+		for _, op := range ops {
+			if op.Kind != '=' {
+				edits++
+			}
+		}
+		if want := distance(a, b); edits != want {
+			t.Fatalf("Expected %d edits from %v to %v, got %v", want, a, b, TextDelta(ops))
+		}
+		got, err := TextDiffer{Granularity: Chars}.Apply(strings.Join(a, ""), TextDelta(ops))
+		if err != nil || got != strings.Join(b, "") {
+			t.Fatalf("Expected %v from %v with %v, got %q, %v", b, a, TextDelta(ops), got, err)
+		}
+	}
+}
+
+func TestTextDiffer_LargeEditInLinearSpace(t *testing.T) {
+	const lines = 5000
+	var from, to strings.Builder
+	for i := range lines {
+		fmt.Fprintf(&from, "old %d\n", i)
+		fmt.Fprintf(&to, "new %d\n", i)
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	delta, err := TextDiffer{Granularity: Lines}.Diff(from.String(), to.String())
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keeping V for every d would take lines² words, hundreds of MiB here
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("Expected the diff to allocate less than 16 MiB, got %d MiB", allocated>>20)
+	}
+	if got := delta.(TextDelta); len(got) != 3 || got[0].N != lines || got[1].Text != to.String() {
+		t.Errorf("Expected the old lines deleted and the new ones inserted, got %d ops", len(got))
+	}
+}
+
+func TestTextDiffer_Mismatch(t *testing.T) {
+	d := TextDiffer{Granularity: Chars}
+	delta, _ := d.Diff("hello", "help")
+	for _, from := range []string{"hell", "hello!"} {
+		if _, err := d.Apply(from, delta); !errors.Is(err, ErrDeltaMismatch) {
+			t.Errorf("Expected ErrDeltaMismatch applying to %q, got %v", from, err)
+		}
+	}
+	if _, err := d.Apply("hello", JSONPatch{}); !errors.Is(err, ErrDeltaMismatch) {
+		t.Errorf("Expected ErrDeltaMismatch for a JSON patch, got %v", err)
+	}
+}
+
+type record struct {
+	Name   string            `json:"name"`
+	Count  int               `json:"count,omitempty"`
+	Tags   []string          `json:"tags"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Child  *record           `json:"child,omitempty"`
+}
+
+func TestJSONDiffer(t *testing.T) {
+	from := record{
+		Name:   "a",
+		Count:  1,
+		Tags:   []string{"x", "y", "z"},
+		Labels: map[string]string{"a/b": "1", "c~d": "2"},
+		Child:  &record{Name: "child", Tags: []string{"t"}},
+	}
+	tests := []struct {
+		name     string
+		to       record
+		expected string
+	}{
+		{"same", from, ""},
+		{"field", record{Name: "b", Count: 1, Tags: from.Tags, Labels: from.Labels, Child: from.Child},
+			`replace /name "b"`},
+		{"omitted field", record{Name: "a", Tags: from.Tags, Labels: from.Labels, Child: from.Child},
+			"remove /count"},
+		{"escaped keys", record{Name: "a", Count: 1, Tags: from.Tags, Labels: map[string]string{"a/b": "3", "e": "4"}, Child: from.Child},
+			`replace /labels/a~1b "3"; remove /labels/c~0d; add /labels/e "4"`},
+		{"shorter array", record{Name: "a", Count: 1, Tags: []string{"w"}, Labels: from.Labels, Child: from.Child},
+			`replace /tags/0 "w"; remove /tags/2; remove /tags/1`},
+		{"longer array", record{Name: "a", Count: 1, Tags: []string{"x", "y", "z", "v", "w"}, Labels: from.Labels, Child: from.Child},
+			`add /tags/3 "v"; add /tags/4 "w"`},
+		{"nested", record{Name: "a", Count: 1, Tags: from.Tags, Labels: from.Labels, Child: &record{Name: "child", Count: 2, Tags: nil}},
+			`add /child/count 2; replace /child/tags null`},
+		{"null array", record{Name: "a", Count: 1, Labels: from.Labels, Child: from.Child},
+			"replace /tags null"},
+	}
+	d := JSONDiffer[record]{}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			delta, err := d.Diff(from, test.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := delta.(JSONPatch).String(); got != test.expected {
+				t.Errorf("Expected patch %s, got %s", test.expected, got)
+			}
+			got, err := d.Apply(from, delta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.to) {
+				t.Errorf("Expected %+v, got %+v", test.to, got)
+			}
+		})
+	}
+}
+
+func TestJSONPatch_Encoding(t *testing.T) {
+	d := JSONDiffer[record]{}
+	from := record{Name: "a", Tags: []string{"x"}, Labels: map[string]string{"k": "v"}}
+	to := record{Name: "a", Count: 1<<60 + 1}
+	delta, err := d.Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(delta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"op":"add","path":"/count","value":1152921504606846977},{"op":"remove","path":"/labels"},{"op":"replace","path":"/tags","value":null}]`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+
+	var patch JSONPatch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.Apply(from, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, to) {
+		t.Errorf("Expected %+v after a round trip, got %+v", to, got)
+	}
+}
+
+func TestJSONDiffer_Mismatch(t *testing.T) {
+	d := JSONDiffer[record]{}
+	patch := JSONPatch{{Op: "remove", Path: "/tags/3"}}
+	if _, err := d.Apply(record{Tags: []string{"x"}}, patch); !errors.Is(err, ErrDeltaMismatch) {
+		t.Errorf("Expected ErrDeltaMismatch, got %v", err)
+	}
+	patch = JSONPatch{{Op: "replace", Path: "/child/name", Value: "x"}}
+	if _, err := d.Apply(record{}, patch); !errors.Is(err, ErrDeltaMismatch) {
+		t.Errorf("Expected ErrDeltaMismatch, got %v", err)
+	}
+}
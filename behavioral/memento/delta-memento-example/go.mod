@@ -0,0 +1,3 @@
+module memento-delta
+
+go 1.23.6
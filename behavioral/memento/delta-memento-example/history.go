@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Differ computes the delta between two states of type T and applies it
+// again. Apply(from, Diff(from, to)) must give back to.
+type Differ[T any] interface {
+	Diff(from, to T) (any, error)
+	Apply(from T, delta any) (T, error)
+}
+
+// Cloner is implemented by a Differ whose states share memory with the
+// values they were made from, through slices, maps or pointers.
+// DeltaHistory keeps clones of such states, so that the caller may go on
+// changing the value it saved.
+type Cloner[T any] interface {
+	Clone(state T) (T, error)
+}
+
+// Caretaker keeps a linear list of states and restores any of them by
+// index, 0 being the first state saved.
+type Caretaker[T any] interface {
+	Save(state T) error
+	Restore(i int) (T, error)
+	Len() int
+}
+
+// FullHistory keeps a full copy of every state. Restore is immediate, but
+// each state costs its whole size.
+type FullHistory[T any] struct {
+	states []T
+}
+
+func NewFullHistory[T any]() *FullHistory[T] {
+	return &FullHistory[T]{}
+}
+
+func (h *FullHistory[T]) Save(state T) error {
+	h.states = append(h.states, state)
+	return nil
+}
+
+func (h *FullHistory[T]) Restore(i int) (T, error) {
+	if i < 0 || i >= len(h.states) {
+		var zero T
+		return zero, fmt.Errorf("no state %d, have %d", i, len(h.states))
+	}
+	return h.states[i], nil
+}
+
+func (h *FullHistory[T]) Len() int { return len(h.states) }
+
+// deltaEntry is either a full checkpoint or the delta from the state
+// before it.
+type deltaEntry[T any] struct {
+	checkpoint bool
+	state      T
+	delta      any
+}
+
+// DeltaHistory keeps only the delta from the previous state, except for a
+// full checkpoint every so many states. Restoring a state applies at most
+// checkpointEvery-1 deltas to the checkpoint before it.
+type DeltaHistory[T any] struct {
+	differ          Differ[T]
+	checkpointEvery int
+	entries         []deltaEntry[T]
+	last            T // the state saved last, which the next delta starts from
+}
+
+// NewDeltaHistory takes a full checkpoint every checkpointEvery states. With
+// 1 every state is a checkpoint, like in a FullHistory.
+func NewDeltaHistory[T any](differ Differ[T], checkpointEvery int) *DeltaHistory[T] {
+	return &DeltaHistory[T]{differ: differ, checkpointEvery: max(checkpointEvery, 1)}
+}
+
+func (h *DeltaHistory[T]) Save(state T) error {
+	state, err := h.clone(state)
+	if err != nil {
+		return fmt.Errorf("clone state %d: %w", len(h.entries), err)
+	}
+	entry := deltaEntry[T]{checkpoint: len(h.entries)%h.checkpointEvery == 0}
+	if entry.checkpoint {
+		entry.state = state
+	} else {
+		delta, err := h.differ.Diff(h.last, state)
+		if err != nil {
+			return fmt.Errorf("diff state %d: %w", len(h.entries), err)
+		}
+		entry.delta = delta
+	}
+	h.entries = append(h.entries, entry)
+	h.last = state
+	return nil
+}
+
+func (h *DeltaHistory[T]) Restore(i int) (T, error) {
+	if i < 0 || i >= len(h.entries) {
+		var zero T
+		return zero, fmt.Errorf("no state %d, have %d", i, len(h.entries))
+	}
+	start := i - i%h.checkpointEvery
+	// the checkpoint must not change with the state returned
+	state, err := h.clone(h.entries[start].state)
+	if err != nil {
+		return state, fmt.Errorf("restore state %d: %w", start, err)
+	}
+	for j := start + 1; j <= i; j++ {
+		if state, err = h.differ.Apply(state, h.entries[j].delta); err != nil {
+			return state, fmt.Errorf("restore state %d: %w", j, err)
+		}
+	}
+	return state, nil
+}
+
+func (h *DeltaHistory[T]) clone(state T) (T, error) {
+	if cloner, ok := h.differ.(Cloner[T]); ok {
+		return cloner.Clone(state)
+	}
+	return state, nil
+}
+
+func (h *DeltaHistory[T]) Len() int { return len(h.entries) }
+
+// Checkpoints returns the number of full states kept.
+func (h *DeltaHistory[T]) Checkpoints() int {
+	return (len(h.entries) + h.checkpointEvery - 1) / h.checkpointEvery
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// versions returns n versions of a long text, each a small edit of the one
+// before.
+func versions(n, lines int) []string {
+	r := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for range lines {
+		b.WriteString(randomLine(r))
+	}
+	texts := []string{b.String()}
+	for len(texts) < n {
+		texts = append(texts, edit(r, texts[len(texts)-1]))
+	}
+	return texts
+}
+
+func TestDeltaHistory_RestoresEveryState(t *testing.T) {
+	texts := versions(50, 40)
+	for _, every := range []int{0, 1, 7, 100} {
+		h := NewDeltaHistory[string](TextDiffer{Granularity: Lines}, every)
+		for _, text := range texts {
+			if err := h.Save(text); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if h.Len() != len(texts) {
+			t.Fatalf("Expected %d states, got %d", len(texts), h.Len())
+		}
+		for i, text := range texts {
+			got, err := h.Restore(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != text {
+				t.Fatalf("Checkpoint every %d: state %d was not restored", every, i)
+			}
+		}
+	}
+}
+
+func TestDeltaHistory_Checkpoints(t *testing.T) {
+	h := NewDeltaHistory[record](JSONDiffer[record]{}, 3)
+	for i := range 7 {
+		if err := h.Save(record{Name: "r", Count: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// states 0, 3 and 6
+	if h.Checkpoints() != 3 {
+		t.Errorf("Expected 3 checkpoints, got %d", h.Checkpoints())
+	}
+	for i, entry := range h.entries {
+		if entry.checkpoint != (i%3 == 0) {
+			t.Errorf("Expected state %d to be a checkpoint: %v", i, i%3 == 0)
+		}
+	}
+	if got, _ := h.Restore(5); got.Count != 5 {
+		t.Errorf("Expected count 5, got %d", got.Count)
+	}
+	if _, err := h.Restore(7); err == nil {
+		t.Error("Expected an error for a state that was not saved")
+	}
+}
+
+func TestDeltaHistory_KeepsClones(t *testing.T) {
+	h := NewDeltaHistory[record](JSONDiffer[record]{}, 2)
+	state := record{Name: "r", Tags: []string{"a", "b"}}
+	h.Save(state)
+	state.Tags[0] = "changed after save"
+	h.Save(record{Name: "r", Tags: []string{"a", "b", "c"}})
+
+	restored, _ := h.Restore(0)
+	if restored.Tags[0] != "a" {
+		t.Fatalf("Expected the checkpoint to keep its own tags, got %v", restored.Tags)
+	}
+	restored.Tags[1] = "changed after restore"
+	if got, _ := h.Restore(1); !slices.Equal(got.Tags, []string{"a", "b", "c"}) {
+		t.Errorf("Expected the restored states to be independent, got %v", got.Tags)
+	}
+	if got, _ := h.Restore(0); got.Tags[1] != "b" {
+		t.Errorf("Expected the checkpoint to be unchanged, got %v", got.Tags)
+	}
+}
+
+type strategy struct {
+	name         string
+	newCaretaker func() Caretaker[string]
+}
+
+// strategies returns the caretakers to compare: full snapshots, and deltas
+// with checkpoints at different intervals.
+func strategies() []strategy {
+	s := []strategy{{"full", func() Caretaker[string] { return NewFullHistory[string]() }}}
+	for _, every := range []int{10, 50} {
+		s = append(s, strategy{fmt.Sprintf("delta-every-%d", every), func() Caretaker[string] {
+			return NewDeltaHistory[string](TextDiffer{Granularity: Lines}, every)
+		}})
+	}
+	return s
+}
+
+func heapInUse() uint64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// BenchmarkSave saves 200 versions of a 2000 line text. The texts are
+// built anew for every version, as an editor would, so that a full
+// snapshot cannot share memory with the one before. retained-B is the heap
+// the caretaker keeps.
+func BenchmarkSave(b *testing.B) {
+	texts := versions(200, 2000)
+	for _, s := range strategies() {
+		b.Run(s.name, func(b *testing.B) {
+			var retained int64
+			for range b.N {
+				b.StopTimer()
+				before := heapInUse()
+				b.StartTimer()
+				c := s.newCaretaker()
+				for _, text := range texts {
+					if err := c.Save(strings.Clone(text)); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+				// the heap may shrink if garbage from before was collected
+				retained += max(int64(heapInUse())-int64(before), 0)
+				runtime.KeepAlive(c)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(retained)/float64(b.N), "retained-B")
+		})
+	}
+}
+
+// BenchmarkRestore restores every state of the history in turn.
+func BenchmarkRestore(b *testing.B) {
+	texts := versions(200, 2000)
+	for _, s := range strategies() {
+		b.Run(s.name, func(b *testing.B) {
+			c := s.newCaretaker()
+			for _, text := range texts {
+				c.Save(strings.Clone(text))
+			}
+			b.ResetTimer()
+			for i := range b.N {
+				if _, err := c.Restore(i % c.Len()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
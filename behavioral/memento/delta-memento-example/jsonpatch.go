@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PatchOp is one operation of a JSON patch (RFC 6902): add, remove or
+// replace the value at Path, a JSON pointer (RFC 6901).
+type PatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// MarshalJSON leaves the value out of a remove, but keeps it in an add or a
+// replace, even when it is null.
+func (op PatchOp) MarshalJSON() ([]byte, error) {
+	type plain PatchOp
+	if op.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+	return json.Marshal(plain(op))
+}
+
+// UnmarshalJSON keeps the numbers of the value as json.Number, so that large
+// integers do not lose precision.
+func (op *PatchOp) UnmarshalJSON(data []byte) error {
+	type plain PatchOp
+	return decodeJSON(data, (*plain)(op))
+}
+
+func (op PatchOp) String() string {
+	if op.Op == "remove" {
+		return op.Op + " " + op.Path
+	}
+	value, _ := json.Marshal(op.Value)
+	return fmt.Sprintf("%s %s %s", op.Op, op.Path, value)
+}
+
+type JSONPatch []PatchOp
+
+func (p JSONPatch) String() string {
+	ops := make([]string, len(p))
+	for i, op := range p {
+		ops[i] = op.String()
+	}
+	return strings.Join(ops, "; ")
+}
+
+// JSONDiffer diffs any value that encodes to JSON, field by field. Array
+// elements are compared by position.
+type JSONDiffer[T any] struct{}
+
+func (JSONDiffer[T]) Diff(from, to T) (any, error) {
+	a, err := toJSONValue(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toJSONValue(to)
+	if err != nil {
+		return nil, err
+	}
+	return diffJSON(nil, "", a, b), nil
+}
+
+func (JSONDiffer[T]) Apply(from T, delta any) (T, error) {
+	var result T
+	patch, ok := delta.(JSONPatch)
+	if !ok {
+		return result, fmt.Errorf("%w: not a JSON patch", ErrDeltaMismatch)
+	}
+	doc, err := toJSONValue(from)
+	if err != nil {
+		return result, err
+	}
+	for _, op := range patch {
+		if doc, err = applyPatchOp(doc, pointerTokens(op.Path), op); err != nil {
+			return result, fmt.Errorf("%w: %s %s: %v", ErrDeltaMismatch, op.Op, op.Path, err)
+		}
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return result, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+// Clone copies state through JSON, which keeps what Diff and Apply see.
+func (JSONDiffer[T]) Clone(state T) (T, error) {
+	var clone T
+	data, err := json.Marshal(state)
+	if err != nil {
+		return clone, err
+	}
+	err = json.Unmarshal(data, &clone)
+	return clone, err
+}
+
+// toJSONValue turns v into maps, slices and scalars, as json.Unmarshal
+// into an any would, except that numbers are json.Number.
+func toJSONValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	err = decodeJSON(data, &value)
+	return value, err
+}
+
+func decodeJSON(data []byte, v any) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+func diffJSON(patch JSONPatch, path string, a, b any) JSONPatch {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			av, inA := a[k]
+			bv, inB := b[k]
+			p := path + "/" + escapePointer(k)
+			switch {
+			case !inB:
+				patch = append(patch, PatchOp{Op: "remove", Path: p})
+			case !inA:
+				patch = append(patch, PatchOp{Op: "add", Path: p, Value: bv})
+			default:
+				patch = diffJSON(patch, p, av, bv)
+			}
+		}
+		return patch
+	case []any:
+		b, ok := b.([]any)
+		if !ok {
+			break
+		}
+		common := min(len(a), len(b))
+		for i := range common {
+			patch = diffJSON(patch, path+"/"+strconv.Itoa(i), a[i], b[i])
+		}
+		for i := common; i < len(b); i++ {
+			patch = append(patch, PatchOp{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: b[i]})
+		}
+		// from the end, so that the indexes stay valid
+		for i := len(a) - 1; i >= common; i-- {
+			patch = append(patch, PatchOp{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		return patch
+	}
+	if !reflect.DeepEqual(a, b) {
+		patch = append(patch, PatchOp{Op: "replace", Path: path, Value: b})
+	}
+	return patch
+}
+
+// applyPatchOp applies op at the pointer tokens below doc, and returns the
+// new doc.
+func applyPatchOp(doc any, tokens []string, op PatchOp) (any, error) {
+	if len(tokens) == 0 {
+		if op.Op == "remove" {
+			return nil, nil
+		}
+		return op.Value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+	switch doc := doc.(type) {
+	case map[string]any:
+		if len(rest) > 0 {
+			child, ok := doc[token]
+			if !ok {
+				return nil, fmt.Errorf("no member %q", token)
+			}
+			child, err := applyPatchOp(child, rest, op)
+			doc[token] = child
+			return doc, err
+		}
+		_, exists := doc[token]
+		switch {
+		case op.Op == "add":
+			doc[token] = op.Value
+		case !exists:
+			return nil, fmt.Errorf("no member %q", token)
+		case op.Op == "remove":
+			delete(doc, token)
+		default:
+			doc[token] = op.Value
+		}
+		return doc, nil
+	case []any:
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i > len(doc) || i == len(doc) && op.Op != "add" || len(rest) > 0 && i == len(doc) {
+			return nil, fmt.Errorf("bad index %q", token)
+		}
+		if len(rest) > 0 {
+			doc[i], err = applyPatchOp(doc[i], rest, op)
+			return doc, err
+		}
+		switch op.Op {
+		case "add":
+			return slices.Insert(doc, i, op.Value), nil
+		case "remove":
+			return slices.Delete(doc, i, i+1), nil
+		default:
+			doc[i] = op.Value
+			return doc, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot go into %T", doc)
+}
+
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+func pointerTokens(path string) []string {
+	if path == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens
+}
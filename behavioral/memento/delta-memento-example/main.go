@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Document is the originator. Its mementos are copies of the whole
+// document, but the caretakers only store what changed between them.
+type Document struct {
+	Title string
+	Body  string
+	Tags  []string
+}
+
+func (d *Document) CreateMemento() Document {
+	memento := *d
+	memento.Tags = slices.Clone(d.Tags)
+	return memento
+}
+
+func (d *Document) Restore(m Document) {
+	*d = m
+	d.Tags = slices.Clone(m.Tags)
+}
+
+func main() {
+	doc := &Document{Title: "Draft", Body: "Dear team,\nthe release is on Monday.\nRegards\n"}
+	documents := NewDeltaHistory[Document](JSONDiffer[Document]{}, 4)
+	bodies := NewDeltaHistory[string](TextDiffer{Granularity: Lines}, 4)
+
+	save := func() {
+		m := doc.CreateMemento()
+		if err := documents.Save(m); err != nil {
+			panic(err)
+		}
+		if err := bodies.Save(m.Body); err != nil {
+			panic(err)
+		}
+	}
+	save()
+
+	doc.Body = strings.Replace(doc.Body, "Monday", "Tuesday", 1)
+	doc.Tags = append(doc.Tags, "release")
+	save()
+	doc.Title = "Release date"
+	doc.Body += "PS: the notes are in the wiki.\n"
+	save()
+	doc.Tags = append(doc.Tags, "urgent")
+	save()
+	doc.Body = strings.Replace(doc.Body, "Regards", "Thanks", 1)
+	save()
+
+	delta, _ := JSONDiffer[Document]{}.Diff(must(documents.Restore(1)), must(documents.Restore(2)))
+	fmt.Println("The document delta from 1 to 2:", delta)
+	delta, _ = TextDiffer{Granularity: Lines}.Diff(must(bodies.Restore(3)), must(bodies.Restore(4)))
+	fmt.Println("The body delta from 3 to 4:", delta)
+	fmt.Println(documents.Len(), "states kept as", documents.Checkpoints(), "checkpoints and deltas")
+
+	doc.Restore(must(documents.Restore(2)))
+	fmt.Printf("Restored state 2: %q %v\n%s", doc.Title, doc.Tags, doc.Body)
+	fmt.Printf("Body of state 0:\n%s", must(bodies.Restore(0)))
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
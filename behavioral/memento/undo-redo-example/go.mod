@@ -0,0 +1,3 @@
+module memento-undo-redo
+
+go 1.23.6
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrNoSuchState = errors.New("no such state in the history")
+
+// EvictionPolicy decides which state a full History forgets.
+type EvictionPolicy int
+
+const (
+	// DropOldest forgets the root. The branches that split off at the root
+	// go with it.
+	DropOldest EvictionPolicy = iota
+	// DropStaleBranches forgets the tip of the branch visited least
+	// recently, and only falls back to DropOldest while there is a single
+	// branch.
+	DropStaleBranches
+)
+
+// Node is a saved state. Its children are the states saved after undoing
+// back to it, one per branch.
+type Node[T any] struct {
+	id       int
+	value    T
+	parent   *Node[T]
+	children []*Node[T]
+	redo     *Node[T] // the child Redo moves to
+	visited  int
+}
+
+func (n *Node[T]) ID() int              { return n.id }
+func (n *Node[T]) Value() T             { return n.value }
+func (n *Node[T]) Parent() *Node[T]     { return n.parent }
+func (n *Node[T]) Children() []*Node[T] { return slices.Clone(n.children) }
+
+// History is a generic caretaker that keeps the states of an originator in
+// an undo tree: saving after an undo starts a new branch instead of
+// throwing the redo states away.
+type History[T any] struct {
+	root     *Node[T]
+	current  *Node[T]
+	nodes    map[int]*Node[T]
+	capacity int
+	policy   EvictionPolicy
+	lastID   int
+	clock    int // orders visits for DropStaleBranches
+}
+
+// NewHistory starts a history at the initial state. It keeps up to capacity
+// states, or every state if capacity is 0.
+func NewHistory[T any](initial T, capacity int, policy EvictionPolicy) *History[T] {
+	h := &History[T]{nodes: make(map[int]*Node[T]), capacity: capacity, policy: policy}
+	h.root = h.newNode(initial, nil)
+	h.current = h.root
+	return h
+}
+
+func (h *History[T]) newNode(value T, parent *Node[T]) *Node[T] {
+	h.lastID++
+	n := &Node[T]{id: h.lastID, value: value, parent: parent}
+	h.nodes[n.id] = n
+	h.visit(n)
+	return n
+}
+
+func (h *History[T]) visit(n *Node[T]) {
+	h.clock++
+	n.visited = h.clock
+}
+
+func (h *History[T]) Len() int          { return len(h.nodes) }
+func (h *History[T]) Root() *Node[T]    { return h.root }
+func (h *History[T]) Current() *Node[T] { return h.current }
+func (h *History[T]) Value() T          { return h.current.value }
+
+// Save records a new state after the current one and makes it current.
+func (h *History[T]) Save(value T) *Node[T] {
+	n := h.newNode(value, h.current)
+	h.current.children = append(h.current.children, n)
+	h.current.redo = n
+	h.current = n
+	h.evict()
+	return n
+}
+
+// Undo moves to the previous state. It reports false at the root.
+func (h *History[T]) Undo() (T, bool) {
+	if h.current.parent == nil {
+		return h.current.value, false
+	}
+	h.current.parent.redo = h.current
+	h.current = h.current.parent
+	h.visit(h.current)
+	return h.current.value, true
+}
+
+// Redo moves back along the branch the last Undo came from, or else the
+// branch saved last. It reports false at the tip of a branch.
+func (h *History[T]) Redo() (T, bool) {
+	if h.current.redo == nil {
+		return h.current.value, false
+	}
+	h.current = h.current.redo
+	h.visit(h.current)
+	return h.current.value, true
+}
+
+// Goto makes any state of the tree current. Redo from its ancestors then
+// leads back to it.
+func (h *History[T]) Goto(id int) (T, error) {
+	n, ok := h.nodes[id]
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%w: %d", ErrNoSuchState, id)
+	}
+	for child := n; child.parent != nil; child = child.parent {
+		child.parent.redo = child
+	}
+	h.current = n
+	h.visit(n)
+	return n.value, nil
+}
+
+// Branches returns the tip of every branch, oldest first. Following Parent
+// from a tip to the root gives the states of the branch.
+func (h *History[T]) Branches() []*Node[T] {
+	var tips []*Node[T]
+	for _, n := range h.nodes {
+		if len(n.children) == 0 {
+			tips = append(tips, n)
+		}
+	}
+	slices.SortFunc(tips, func(a, b *Node[T]) int { return a.id - b.id })
+	return tips
+}
+
+// Path returns the states from the root to n.
+func (h *History[T]) Path(n *Node[T]) []T {
+	var values []T
+	for ; n != nil; n = n.parent {
+		values = append(values, n.value)
+	}
+	slices.Reverse(values)
+	return values
+}
+
+func (h *History[T]) evict() {
+	for h.capacity > 0 && len(h.nodes) > h.capacity {
+		if h.policy == DropStaleBranches {
+			if tip := h.staleTip(); tip != nil {
+				h.remove(tip)
+				continue
+			}
+		}
+		h.dropRoot()
+	}
+}
+
+// staleTip returns the least recently visited tip other than the current
+// state.
+func (h *History[T]) staleTip() *Node[T] {
+	var stale *Node[T]
+	for _, tip := range h.Branches() {
+		if tip == h.current {
+			continue
+		}
+		if stale == nil || tip.visited < stale.visited {
+			stale = tip
+		}
+	}
+	return stale
+}
+
+// dropRoot makes the child on the way to the current state the new root.
+func (h *History[T]) dropRoot() {
+	next := h.current
+	for next.parent != h.root {
+		next = next.parent
+	}
+	for _, child := range h.root.children {
+		if child != next {
+			h.removeTree(child)
+		}
+	}
+	delete(h.nodes, h.root.id)
+	next.parent = nil
+	h.root = next
+}
+
+// remove deletes a tip of the tree.
+func (h *History[T]) remove(n *Node[T]) {
+	parent := n.parent
+	parent.children = slices.DeleteFunc(parent.children, func(c *Node[T]) bool { return c == n })
+	if parent.redo == n {
+		parent.redo = nil
+		if len(parent.children) > 0 {
+			parent.redo = parent.children[len(parent.children)-1]
+		}
+	}
+	delete(h.nodes, n.id)
+}
+
+func (h *History[T]) removeTree(n *Node[T]) {
+	for _, child := range n.children {
+		h.removeTree(child)
+	}
+	delete(h.nodes, n.id)
+}
+
+// HistorySnapshot is the whole tree of a History in a form that can be
+// encoded, e.g. by a Store. Parent and Redo are node IDs, 0 for none.
+type HistorySnapshot[T any] struct {
+	Nodes    []NodeSnapshot[T]
+	Current  int
+	Capacity int
+	Policy   EvictionPolicy
+}
+
+type NodeSnapshot[T any] struct {
+	ID      int
+	Parent  int
+	Redo    int
+	Visited int
+	Value   T
+}
+
+// Snapshot lists the nodes parents first, so that every parent comes before
+// its children.
+func (h *History[T]) Snapshot() HistorySnapshot[T] {
+	s := HistorySnapshot[T]{Current: h.current.id, Capacity: h.capacity, Policy: h.policy}
+	var walk func(n *Node[T])
+	walk = func(n *Node[T]) {
+		ns := NodeSnapshot[T]{ID: n.id, Visited: n.visited, Value: n.value}
+		if n.parent != nil {
+			ns.Parent = n.parent.id
+		}
+		if n.redo != nil {
+			ns.Redo = n.redo.id
+		}
+		s.Nodes = append(s.Nodes, ns)
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(h.root)
+	return s
+}
+
+// RestoreHistory rebuilds a History from a snapshot.
+func RestoreHistory[T any](s HistorySnapshot[T]) (*History[T], error) {
+	h := &History[T]{nodes: make(map[int]*Node[T]), capacity: s.Capacity, policy: s.Policy}
+	for _, ns := range s.Nodes {
+		if _, ok := h.nodes[ns.ID]; ok || ns.ID <= 0 {
+			return nil, fmt.Errorf("invalid history snapshot: bad node id %d", ns.ID)
+		}
+		n := &Node[T]{id: ns.ID, value: ns.Value, visited: ns.Visited}
+		if ns.Parent == 0 {
+			if h.root != nil {
+				return nil, errors.New("invalid history snapshot: more than one root")
+			}
+			h.root = n
+		} else {
+			parent, ok := h.nodes[ns.Parent]
+			if !ok {
+				return nil, fmt.Errorf("invalid history snapshot: node %d comes before its parent", ns.ID)
+			}
+			n.parent = parent
+			parent.children = append(parent.children, n)
+		}
+		h.nodes[n.id] = n
+		h.lastID = max(h.lastID, n.id)
+		h.clock = max(h.clock, n.visited)
+	}
+	for _, ns := range s.Nodes {
+		if ns.Redo != 0 {
+			redo, ok := h.nodes[ns.Redo]
+			if !ok || redo.parent != h.nodes[ns.ID] {
+				return nil, fmt.Errorf("invalid history snapshot: bad redo of node %d", ns.ID)
+			}
+			h.nodes[ns.ID].redo = redo
+		}
+	}
+	if h.current = h.nodes[s.Current]; h.root == nil || h.current == nil {
+		return nil, errors.New("invalid history snapshot: no root or current node")
+	}
+	return h, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func paths(h *History[string]) [][]string {
+	var paths [][]string
+	for _, tip := range h.Branches() {
+		paths = append(paths, h.Path(tip))
+	}
+	return paths
+}
+
+func expectPaths(t *testing.T, h *History[string], expected ...[]string) {
+	t.Helper()
+	if got := paths(h); !slices.EqualFunc(got, expected, slices.Equal) {
+		t.Errorf("Expected branches %v, got %v", expected, got)
+	}
+}
+
+func TestHistory_UndoRedo(t *testing.T) {
+	h := NewHistory("a", 0, DropOldest)
+	if _, ok := h.Undo(); ok {
+		t.Error("Expected nothing to undo at the root")
+	}
+	h.Save("b")
+	h.Save("c")
+
+	for _, expected := range []string{"b", "a"} {
+		if v, ok := h.Undo(); !ok || v != expected {
+			t.Fatalf("Expected undo to %q, got %q", expected, v)
+		}
+	}
+	for _, expected := range []string{"b", "c"} {
+		if v, ok := h.Redo(); !ok || v != expected {
+			t.Fatalf("Expected redo to %q, got %q", expected, v)
+		}
+	}
+	if _, ok := h.Redo(); ok {
+		t.Error("Expected nothing to redo at the tip")
+	}
+}
+
+func TestHistory_Branches(t *testing.T) {
+	h := NewHistory("a", 0, DropOldest)
+	h.Save("b")
+	c := h.Save("c")
+	h.Undo()
+	h.Save("d") // a branch instead of losing c
+
+	expectPaths(t, h, []string{"a", "b", "c"}, []string{"a", "b", "d"})
+	if h.Value() != "d" {
+		t.Errorf("Expected d to be current, got %q", h.Value())
+	}
+
+	// redo follows the branch undo came from
+	h.Undo()
+	if v, _ := h.Redo(); v != "d" {
+		t.Errorf("Expected redo to d, got %q", v)
+	}
+
+	if v, err := h.Goto(c.ID()); err != nil || v != "c" {
+		t.Fatalf("Expected to go to c, got %q, %v", v, err)
+	}
+	h.Undo()
+	h.Undo()
+	h.Redo()
+	if v, _ := h.Redo(); v != "c" {
+		t.Errorf("Expected redo to lead back to c, got %q", v)
+	}
+	if _, err := h.Goto(42); !errors.Is(err, ErrNoSuchState) {
+		t.Errorf("Expected ErrNoSuchState, got %v", err)
+	}
+}
+
+func TestHistory_DropOldest(t *testing.T) {
+	h := NewHistory("a", 3, DropOldest)
+	h.Save("b")
+	h.Undo()
+	h.Save("c")
+	h.Save("d")
+
+	// a goes, and b with it since it branched off a
+	expectPaths(t, h, []string{"c", "d"})
+	if h.Len() != 2 {
+		t.Errorf("Expected 2 states, got %d", h.Len())
+	}
+	h.Save("e")
+	h.Save("f")
+	expectPaths(t, h, []string{"d", "e", "f"})
+	if _, ok := h.Undo(); !ok {
+		t.Error("Expected to undo to e")
+	}
+}
+
+func TestHistory_DropStaleBranches(t *testing.T) {
+	h := NewHistory("a", 4, DropStaleBranches)
+	h.Save("b")
+	h.Undo()
+	h.Save("c")
+	h.Undo()
+	h.Save("d")
+	h.Save("e") // b is the stalest tip
+
+	expectPaths(t, h, []string{"a", "c"}, []string{"a", "d", "e"})
+
+	h.Save("f") // then c
+	expectPaths(t, h, []string{"a", "d", "e", "f"})
+
+	h.Save("g") // a single branch: the root goes
+	expectPaths(t, h, []string{"d", "e", "f", "g"})
+}
+
+func TestBankAccount_Restore(t *testing.T) {
+	ba := NewBankAccount(100)
+	ba.Deposit(50)
+	m := ba.Deposit(25)
+	ba.Undo()
+	ba.Undo()
+	ba.Deposit(10)
+
+	ba.Restore(m)
+	if ba.balance != 175 {
+		t.Fatalf("Expected the restored balance, got %d", ba.balance)
+	}
+	// restoring does not add states, so undo and redo still work
+	if ba.history.Len() != 4 {
+		t.Errorf("Expected 4 states, got %d", ba.history.Len())
+	}
+	if ba.Undo(); ba.balance != 150 {
+		t.Errorf("Expected undo to 150, got %d", ba.balance)
+	}
+	if ba.Redo(); ba.balance != 175 {
+		t.Errorf("Expected redo to 175, got %d", ba.balance)
+	}
+}
@@ -1,21 +1,57 @@
 package main
 
-import "fmt"
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
 
+// Memento is a state of the account. It stays valid after undoing past it,
+// as long as the history keeps it.
 type Memento struct {
 	Balance int
+	id      int
 }
 
 type BankAccount struct {
 	balance int
-	changes []*Memento
-	current int
+	history *History[int]
 }
 
+// NewBankAccount keeps the last 100 balances.
 func NewBankAccount(balance int) *BankAccount {
-	b := &BankAccount{balance: balance}
-	b.changes = append(b.changes, &Memento{balance})
-	return b
+	return &BankAccount{balance: balance, history: NewHistory(balance, 100, DropStaleBranches)}
+}
+
+// The schema version of the account snapshots
+const accountVersion = 1
+
+func NewAccountStore(path string, codec Codec) *Store[HistorySnapshot[int]] {
+	return NewStore[HistorySnapshot[int]](path, codec, accountVersion)
+}
+
+// OpenBankAccount picks up the account and its whole undo history where
+// Save left it, or starts a new account with the balance.
+func OpenBankAccount(store *Store[HistorySnapshot[int]], balance int) (*BankAccount, error) {
+	snapshot, err := store.Load()
+	if errors.Is(err, os.ErrNotExist) {
+		return NewBankAccount(balance), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	history, err := RestoreHistory(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return &BankAccount{balance: history.Value(), history: history}, nil
+}
+
+func (b *BankAccount) Save(store *Store[HistorySnapshot[int]]) error {
+	return store.Save(b.history.Snapshot())
 }
 
 func (b *BankAccount) String() string {
@@ -24,46 +60,56 @@ func (b *BankAccount) String() string {
 
 func (b *BankAccount) Deposit(amount int) *Memento {
 	b.balance += amount
-	m := Memento{b.balance}
-	b.changes = append(b.changes, &m)
-	b.current++
+	n := b.history.Save(b.balance)
 	fmt.Println("Deposited", amount, "\b, balance is now", b.balance)
-	return &m
+	return &Memento{b.balance, n.ID()}
 }
 
+// Restore goes back to m. If the history has forgotten m, its balance is
+// saved as a new state instead.
 func (b *BankAccount) Restore(m *Memento) {
-	if m != nil {
-		b.balance = m.Balance
-		b.changes = append(b.changes, m)
-		b.current = len(b.changes) - 1
+	if m == nil {
+		return
+	}
+	if _, err := b.history.Goto(m.id); err != nil {
+		b.history.Save(m.Balance)
 	}
+	b.balance = m.Balance
 }
 
 func (b *BankAccount) Undo() *Memento {
-	if b.current > 0 {
-		b.current--
-		m := b.changes[b.current]
-		b.balance = m.Balance
-		return m
+	if _, ok := b.history.Undo(); !ok {
+		return nil
 	}
-	return nil
+	return b.sync()
 }
 
 func (b *BankAccount) Redo() *Memento {
-	if b.current+1 < len(b.changes) {
-		b.current++
-		m := b.changes[b.current]
-		b.balance = m.Balance
-		return m
+	if _, ok := b.history.Redo(); !ok {
+		return nil
 	}
-	return nil
+	return b.sync()
+}
+
+func (b *BankAccount) sync() *Memento {
+	n := b.history.Current()
+	b.balance = n.Value()
+	return &Memento{n.Value(), n.ID()}
 }
 
 func main() {
+	path := flag.String("state", "", "keep the account in this file, so that undo works across runs")
+	undo := flag.Bool("undo", false, "with -state, undo instead of depositing")
+	flag.Parse()
+	if *path != "" {
+		persistent(*path, *undo)
+		return
+	}
+
 	ba := NewBankAccount(100)
 	fmt.Println(ba)
 	ba.Deposit(50)
-	ba.Deposit(25)
+	m := ba.Deposit(25)
 	fmt.Println(ba)
 
 	ba.Undo()
@@ -72,4 +118,45 @@ func main() {
 	fmt.Println("Undo 2", ba)
 	ba.Redo()
 	fmt.Println("Redo 1", ba)
+
+	// a deposit after undoing starts a branch, the old one can still be restored
+	ba.Deposit(10)
+	fmt.Println("Branches:")
+	for _, tip := range ba.history.Branches() {
+		fmt.Println(" ", ba.history.Path(tip))
+	}
+	ba.Restore(m)
+	fmt.Println("Restored", ba)
+}
+
+// persistent deposits 10, or undoes the last change, which may have been
+// made by an earlier run. With a hex key in $MEMENTO_KEY the file is
+// encrypted and signed.
+func persistent(path string, undo bool) {
+	store := NewAccountStore(path, JSON)
+	if hexKey := os.Getenv("MEMENTO_KEY"); hexKey != "" {
+		key, err := hex.DecodeString(hexKey)
+		if err != nil {
+			log.Fatal("MEMENTO_KEY: ", err)
+		}
+		if err := store.Seal(key); err != nil {
+			log.Fatal("MEMENTO_KEY: ", err)
+		}
+	}
+	ba, err := OpenBankAccount(store, 100)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Opened", ba)
+	if undo {
+		if ba.Undo() == nil {
+			fmt.Println("Nothing to undo")
+		}
+		fmt.Println("Undo", ba)
+	} else {
+		ba.Deposit(10)
+	}
+	if err := ba.Save(store); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrTampered means that a sealed snapshot was modified after it was
+	// written, was written with another key, or is corrupt.
+	ErrTampered = errors.New("snapshot was tampered with or is corrupt")
+	// ErrSealed means that a snapshot is encrypted but the store has no key.
+	ErrSealed = errors.New("snapshot is encrypted and the store has no key")
+)
+
+// KeySize is the size of the key Seal takes.
+const KeySize = 32
+
+// The sealing of a snapshot, as written in its header
+const sealAlgorithm = "aes-256-gcm+hmac-sha256"
+
+// sealer encrypts snapshot payloads with AES-GCM and signs the whole
+// snapshot, header included, with HMAC-SHA256. Both keys are derived from
+// the caller's key, so that neither is used for two purposes.
+type sealer struct {
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("the key must be %d bytes, not %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(deriveKey(key, "memento snapshot encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead, macKey: deriveKey(key, "memento snapshot signature")}, nil
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// seal returns the sealed payload: the signature of the header and the
+// rest, the nonce, and the encrypted payload.
+func (s *sealer) seal(header, payload []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	body := s.aead.Seal(nonce, nonce, payload, header)
+	return append(s.sign(header, body), body...), nil
+}
+
+// open checks the signature and decrypts the payload.
+func (s *sealer) open(header, sealed []byte) ([]byte, error) {
+	if len(sealed) < sha256.Size+s.aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated", ErrTampered)
+	}
+	signature, body := sealed[:sha256.Size], sealed[sha256.Size:]
+	if !hmac.Equal(signature, s.sign(header, body)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrTampered)
+	}
+	nonce, ciphertext := body[:s.aead.NonceSize()], body[s.aead.NonceSize():]
+	payload, err := s.aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTampered, err)
+	}
+	return payload, nil
+}
+
+func (s *sealer) sign(header, body []byte) []byte {
+	mac := hmac.New(sha256.New, s.macKey)
+	mac.Write(header)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func sealedStore[T any](t *testing.T, path string, codec Codec, key []byte) *Store[T] {
+	t.Helper()
+	store := NewStore[T](path, codec, 1)
+	if err := store.Seal(key); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+type customer struct {
+	Name string
+	IBAN string
+}
+
+func TestStore_Sealed(t *testing.T) {
+	for _, codec := range []Codec{JSON, Gob} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "customer")
+			store := sealedStore[customer](t, path, codec, testKey(1))
+			secret := customer{"Ada", "DE89370400440532013000"}
+			if err := store.Save(secret); err != nil {
+				t.Fatal(err)
+			}
+
+			data, _ := os.ReadFile(path)
+			if bytes.Contains(data, []byte(secret.IBAN)) || bytes.Contains(data, []byte(secret.Name)) {
+				t.Error("Expected the payload to be encrypted")
+			}
+			got, err := store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != secret {
+				t.Errorf("Expected %v, got %v", secret, got)
+			}
+		})
+	}
+}
+
+func TestStore_SealedTampered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customer")
+	store := sealedStore[customer](t, path, JSON, testKey(1))
+	store.Save(customer{"Ada", "DE89370400440532013000"})
+	data, _ := os.ReadFile(path)
+
+	// flipping any single byte, header, signature, nonce or ciphertext, is
+	// detected
+	for i := range data {
+		tampered := bytes.Clone(data)
+		tampered[i] ^= 0x01
+		os.WriteFile(path, tampered, 0o600)
+		if _, err := store.Load(); !errors.Is(err, ErrTampered) {
+			t.Fatalf("Byte %d: expected ErrTampered, got %v", i, err)
+		}
+	}
+	for name, content := range map[string][]byte{
+		"truncated": data[:len(data)-1],
+		"header":    data[:bytes.IndexByte(data, '\n')+1],
+		"extended":  append(bytes.Clone(data), 0),
+	} {
+		os.WriteFile(path, content, 0o600)
+		if _, err := store.Load(); !errors.Is(err, ErrTampered) {
+			t.Errorf("%s: expected ErrTampered, got %v", name, err)
+		}
+	}
+}
+
+func TestStore_SealedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "customer")
+	sealedStore[customer](t, path, JSON, testKey(1)).Save(customer{Name: "Ada"})
+
+	if _, err := sealedStore[customer](t, path, JSON, testKey(2)).Load(); !errors.Is(err, ErrTampered) {
+		t.Errorf("Expected ErrTampered with another key, got %v", err)
+	}
+	if _, err := NewStore[customer](path, JSON, 1).Load(); !errors.Is(err, ErrSealed) {
+		t.Errorf("Expected ErrSealed without a key, got %v", err)
+	}
+
+	// a sealed store does not accept a plain snapshot in place of its own
+	NewStore[customer](path, JSON, 1).Save(customer{Name: "Mallory"})
+	if _, err := sealedStore[customer](t, path, JSON, testKey(1)).Load(); !errors.Is(err, ErrTampered) {
+		t.Errorf("Expected ErrTampered for a plain snapshot, got %v", err)
+	}
+
+	if err := NewStore[customer](path, JSON, 1).Seal(testKey(1)[:16]); err == nil {
+		t.Error("Expected an error for a short key")
+	}
+}
+
+func TestStore_SealedMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings")
+	sealedStore[settingsV1](t, path, JSON, testKey(1)).Save(settingsV1{Name: "main", Width: 80})
+
+	store := NewStore[map[string]any](path, JSON, 2)
+	store.Seal(testKey(1))
+	store.Register(1, MigrateJSON(func(o map[string]any) error {
+		o["Title"] = o["Name"]
+		delete(o, "Name")
+		return nil
+	}))
+	got, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["Title"] != "main" {
+		t.Errorf("Expected the migration to run on the decrypted payload, got %v", got)
+	}
+}
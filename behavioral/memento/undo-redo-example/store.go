@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	ErrNoMigration   = errors.New("no migration registered")
+	ErrNewerSnapshot = errors.New("snapshot is newer than this program")
+	ErrUnknownFormat = errors.New("unknown snapshot format")
+)
+
+// Codec encodes the payload of a snapshot.
+type Codec interface {
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                       { return "json" }
+func (jsonCodec) Marshal(v any) ([]byte, error)      { return json.MarshalIndent(v, "", "  ") }
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+var (
+	JSON Codec = jsonCodec{}
+	Gob  Codec = gobCodec{}
+)
+
+var codecs = map[string]Codec{JSON.Name(): JSON, Gob.Name(): Gob}
+
+// Migration upgrades a payload written at one schema version to the next.
+// It gets the payload in the codec the snapshot was written with.
+type Migration func(payload []byte, codec Codec) ([]byte, error)
+
+// MigrateJSON turns a function that edits a decoded JSON object into a
+// Migration for JSON snapshots.
+func MigrateJSON(edit func(object map[string]any) error) Migration {
+	return func(payload []byte, codec Codec) ([]byte, error) {
+		if codec != JSON {
+			return nil, fmt.Errorf("%w: this migration only reads json, not %s", ErrNoMigration, codec.Name())
+		}
+		var object map[string]any
+		if err := json.Unmarshal(payload, &object); err != nil {
+			return nil, err
+		}
+		if err := edit(object); err != nil {
+			return nil, err
+		}
+		return codec.Marshal(object)
+	}
+}
+
+// snapshotHeader is the first line of every snapshot file. It is always
+// JSON, whatever the codec of the payload that follows it.
+type snapshotHeader struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	Saved   time.Time `json:"saved"`
+	Sealed  string    `json:"sealed,omitempty"` // how the payload is sealed, if it is
+}
+
+// Store keeps a memento of type T in a file. Every Save replaces the whole
+// file atomically, so a crash leaves either the old or the new snapshot,
+// never a mix of both.
+type Store[T any] struct {
+	path       string
+	codec      Codec
+	version    int
+	migrations map[int]Migration
+	sealer     *sealer
+}
+
+// NewStore writes snapshots at the given schema version with codec.
+func NewStore[T any](path string, codec Codec, version int) *Store[T] {
+	return &Store[T]{path: path, codec: codec, version: version, migrations: make(map[int]Migration)}
+}
+
+// Register adds the migration from version to version+1.
+func (s *Store[T]) Register(version int, m Migration) {
+	s.migrations[version] = m
+}
+
+// Seal makes the store encrypt the payload of its snapshots and sign them
+// with a key of KeySize bytes. Load then fails with ErrTampered for any
+// snapshot that was changed, or that is not sealed with the same key.
+func (s *Store[T]) Seal(key []byte) error {
+	sealer, err := newSealer(key)
+	if err != nil {
+		return err
+	}
+	s.sealer = sealer
+	return nil
+}
+
+func (s *Store[T]) Save(v T) error {
+	payload, err := s.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+	header := snapshotHeader{Format: s.codec.Name(), Version: s.version, Saved: time.Now().UTC()}
+	if s.sealer != nil {
+		header.Sealed = sealAlgorithm
+	}
+	line, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if s.sealer != nil {
+		if payload, err = s.sealer.seal(line, payload); err != nil {
+			return fmt.Errorf("seal snapshot: %w", err)
+		}
+	}
+	return writeFileAtomic(s.path, append(line, payload...))
+}
+
+// Load reads the snapshot and runs the migrations from its version up to
+// the version of the store. It returns an error wrapping os.ErrNotExist if
+// nothing was saved yet.
+func (s *Store[T]) Load() (T, error) {
+	var v T
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return v, err
+	}
+	header, payload, err := readSnapshot(data)
+	if err != nil {
+		if s.sealer != nil {
+			return v, fmt.Errorf("%s: %w: %v", s.path, ErrTampered, err)
+		}
+		return v, fmt.Errorf("%s: %w", s.path, err)
+	}
+	switch {
+	case s.sealer != nil && header.Sealed != sealAlgorithm:
+		return v, fmt.Errorf("%s: %w: expected a snapshot sealed with %s, got %q", s.path, ErrTampered, sealAlgorithm, header.Sealed)
+	case s.sealer != nil:
+		line := data[:len(data)-len(payload)]
+		if payload, err = s.sealer.open(line, payload); err != nil {
+			return v, fmt.Errorf("%s: %w", s.path, err)
+		}
+	case header.Sealed != "":
+		return v, fmt.Errorf("%s: %w", s.path, ErrSealed)
+	}
+	codec, ok := codecs[header.Format]
+	if !ok {
+		return v, fmt.Errorf("%s: %w %q", s.path, ErrUnknownFormat, header.Format)
+	}
+	if header.Version > s.version {
+		return v, fmt.Errorf("%s: %w: version %d, expected at most %d", s.path, ErrNewerSnapshot, header.Version, s.version)
+	}
+	for version := header.Version; version < s.version; version++ {
+		migrate, ok := s.migrations[version]
+		if !ok {
+			return v, fmt.Errorf("%s: %w from version %d", s.path, ErrNoMigration, version)
+		}
+		if payload, err = migrate(payload, codec); err != nil {
+			return v, fmt.Errorf("%s: migrate from version %d: %w", s.path, version, err)
+		}
+	}
+	if err := codec.Unmarshal(payload, &v); err != nil {
+		return v, fmt.Errorf("%s: decode snapshot: %w", s.path, err)
+	}
+	return v, nil
+}
+
+func readSnapshot(data []byte) (snapshotHeader, []byte, error) {
+	var header snapshotHeader
+	line, err := bufio.NewReader(bytes.NewReader(data)).ReadBytes('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("truncated snapshot")
+		}
+		return header, nil, err
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		return header, nil, fmt.Errorf("invalid snapshot header: %w", err)
+	}
+	return header, data[len(line):], nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path, flushes it
+// to disk and renames it over path.
+func writeFileAtomic(path string, data []byte) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	// make the rename itself durable; not every platform can sync a directory
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
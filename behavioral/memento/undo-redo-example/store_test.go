@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStore_History(t *testing.T) {
+	for _, codec := range []Codec{JSON, Gob} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			store := NewStore[HistorySnapshot[string]](filepath.Join(t.TempDir(), "history"), codec, 1)
+			h := NewHistory("a", 10, DropStaleBranches)
+			h.Save("b")
+			c := h.Save("c")
+			h.Undo()
+			h.Save("d")
+			h.Goto(c.ID())
+			h.Undo()
+			if err := store.Save(h.Snapshot()); err != nil {
+				t.Fatal(err)
+			}
+
+			snapshot, err := store.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			restored, err := RestoreHistory(snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.EqualFunc(paths(restored), paths(h), slices.Equal) {
+				t.Errorf("Expected branches %v, got %v", paths(h), paths(restored))
+			}
+			if restored.Value() != "b" {
+				t.Errorf("Expected b to be current, got %q", restored.Value())
+			}
+			if v, _ := restored.Redo(); v != "c" {
+				t.Errorf("Expected redo to c after the restart, got %q", v)
+			}
+			if n := restored.Save("e"); n.ID() != 5 {
+				t.Errorf("Expected new ids to follow the restored ones, got %d", n.ID())
+			}
+		})
+	}
+}
+
+func TestStore_NotSaved(t *testing.T) {
+	store := NewAccountStore(filepath.Join(t.TempDir(), "account"), JSON)
+	if _, err := store.Load(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist, got %v", err)
+	}
+	ba, err := OpenBankAccount(store, 100)
+	if err != nil || ba.balance != 100 {
+		t.Errorf("Expected a new account, got %v, %v", ba, err)
+	}
+}
+
+type settingsV1 struct {
+	Name  string
+	Width int
+}
+
+type settingsV3 struct {
+	Title string
+	Size  [2]int
+}
+
+func TestStore_JSONMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings")
+	if err := NewStore[settingsV1](path, JSON, 1).Save(settingsV1{"doc", 80}); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewStore[settingsV3](path, JSON, 3)
+	store.Register(1, MigrateJSON(func(o map[string]any) error {
+		o["Title"] = o["Name"]
+		delete(o, "Name")
+		return nil
+	}))
+	if _, err := store.Load(); !errors.Is(err, ErrNoMigration) {
+		t.Errorf("Expected ErrNoMigration for version 2, got %v", err)
+	}
+	store.Register(2, MigrateJSON(func(o map[string]any) error {
+		o["Size"] = []any{o["Width"], 25}
+		delete(o, "Width")
+		return nil
+	}))
+
+	v, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != (settingsV3{"doc", [2]int{80, 25}}) {
+		t.Errorf("Unexpected migrated value %+v", v)
+	}
+}
+
+func TestStore_GobMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings")
+	NewStore[settingsV1](path, Gob, 1).Save(settingsV1{"doc", 80})
+
+	store := NewStore[settingsV3](path, Gob, 2)
+	store.Register(1, func(payload []byte, codec Codec) ([]byte, error) {
+		var old settingsV1
+		if err := codec.Unmarshal(payload, &old); err != nil {
+			return nil, err
+		}
+		return codec.Marshal(settingsV3{old.Name, [2]int{old.Width, 25}})
+	})
+	v, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != (settingsV3{"doc", [2]int{80, 25}}) {
+		t.Errorf("Unexpected migrated value %+v", v)
+	}
+
+	if _, err := NewStore[settingsV3](path, Gob, 0).Load(); !errors.Is(err, ErrNewerSnapshot) {
+		t.Errorf("Expected ErrNewerSnapshot, got %v", err)
+	}
+}
+
+func TestStore_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "account")
+	store := NewAccountStore(path, Gob)
+	store.Save(NewHistory(100, 0, DropOldest).Snapshot())
+	data, _ := os.ReadFile(path)
+
+	for name, content := range map[string]string{
+		"empty":     "",
+		"no header": "garbage\n",
+		"format":    strings.Replace(string(data), `"gob"`, `"xml"`, 1),
+		"payload":   string(data[:len(data)-3]),
+	} {
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := store.Load(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestStore_AtomicWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "values")
+	store := NewStore[any](path, JSON, 1)
+	if err := store.Save(1); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(path)
+
+	if err := store.Save(make(chan int)); err == nil {
+		t.Fatal("Expected an encoding error")
+	}
+	if after, _ := os.ReadFile(path); string(before) != string(after) {
+		t.Error("A failed save should leave the old snapshot alone")
+	}
+
+	// the rename fails when a directory is in the way
+	blocked := filepath.Join(dir, "blocked")
+	os.MkdirAll(filepath.Join(blocked, "child"), 0o755)
+	if err := NewStore[any](blocked, JSON, 1).Save(1); err == nil {
+		t.Fatal("Expected the rename to fail")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected no temporary files left behind, got %v", entries)
+	}
+}
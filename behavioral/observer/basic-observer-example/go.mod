@@ -0,0 +1,3 @@
+module observer-basic
+
+go 1.23.6
@@ -1,47 +1,14 @@
 package main
 
-import (
-	"container/list"
-	"fmt"
-)
-
-type Observable struct {
-	subscriptions *list.List
-}
-
-func (o *Observable) Subscribe(x Observer) {
-	o.subscriptions.PushBack(x)
-}
-
-func (o *Observable) Unsubscribe(x Observer) {
-	for z := o.subscriptions.Front(); z != nil; z = z.Next() {
-		// if z.Value == x {
-		if z.Value.(Observer) == x {
-			o.subscriptions.Remove(z)
-		}
-	}
-}
-
-func (o *Observable) Fire(data interface{}) {
-	for z := o.subscriptions.Front(); z != nil; z = z.Next() {
-		z.Value.(Observer).Notify(data)
-	}
-}
-
-type Observer interface {
-	Notify(data interface{})
-}
+import "fmt"
 
 type Person struct {
-	Observable
+	Observable[string]
 	Name string
 }
 
 func NewPerson(name string) *Person {
-	return &Person{
-		Observable: Observable{new(list.List)},
-		Name:       name,
-	}
+	return &Person{Name: name}
 }
 
 func (p *Person) CatchACold() {
@@ -51,15 +18,15 @@ func (p *Person) CatchACold() {
 // Concrete observer
 type DoctorService struct{}
 
-func (d *DoctorService) Notify(data interface{}) {
-	fmt.Printf("A doctor has been called for %s\n", data.(string))
+func (d *DoctorService) Notify(name string) {
+	fmt.Printf("A doctor has been called for %s\n", name)
 }
 
 // Concrete observer
 type Mother struct{}
 
-func (m *Mother) Notify(data interface{}) {
-	fmt.Printf("Mommy says: \"poor boy... let me take care of you %s\"\n", data.(string))
+func (m *Mother) Notify(name string) {
+	fmt.Printf("Mommy says: \"poor boy... let me take care of you %s\"\n", name)
 }
 
 func main() {
@@ -68,5 +35,12 @@ func main() {
 	mm := &Mother{}
 	p1.Subscribe(ds)
 	p1.Subscribe(mm)
+	school := p1.SubscribeFunc(func(name string) {
+		fmt.Printf("The school marks %s as absent\n", name)
+	})
+	p1.CatchACold()
+
+	// holidays: the school no longer needs to know
+	school.Cancel()
 	p1.CatchACold()
 }
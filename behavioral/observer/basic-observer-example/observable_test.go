@@ -0,0 +1,140 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+type recorder struct {
+	got []int
+}
+
+func (r *recorder) Notify(data int) {
+	r.got = append(r.got, data)
+}
+
+func TestObservable_Fire(t *testing.T) {
+	var o Observable[int]
+	o.Fire(0) // no observers yet
+
+	r := &recorder{}
+	var order []string
+	o.Subscribe(r)
+	o.SubscribeFunc(func(int) { order = append(order, "first") })
+	o.SubscribeFunc(func(int) { order = append(order, "second") })
+	o.Fire(1)
+	o.Fire(2)
+
+	if !slices.Equal(r.got, []int{1, 2}) {
+		t.Errorf("Expected [1 2], got %v", r.got)
+	}
+	if !slices.Equal(order, []string{"first", "second", "first", "second"}) {
+		t.Errorf("Expected the observers in subscription order, got %v", order)
+	}
+}
+
+func TestSubscription_Cancel(t *testing.T) {
+	var o Observable[int]
+	var calls []string
+	a := o.SubscribeFunc(func(int) { calls = append(calls, "a") })
+	o.SubscribeFunc(func(int) { calls = append(calls, "b") })
+
+	a.Cancel()
+	a.Cancel()              // a second time does nothing
+	Subscription{}.Cancel() // neither does a zero handle
+	o.Fire(1)
+	if !slices.Equal(calls, []string{"b"}) {
+		t.Errorf("Expected only b to be notified, got %v", calls)
+	}
+}
+
+func TestSubscription_CancelWhileNotified(t *testing.T) {
+	var o Observable[int]
+	var calls []string
+	var once Subscription
+	once = o.SubscribeFunc(func(int) {
+		calls = append(calls, "once")
+		once.Cancel()
+	})
+	o.SubscribeFunc(func(int) { calls = append(calls, "always") })
+
+	o.Fire(1)
+	o.Fire(2)
+	if !slices.Equal(calls, []string{"once", "always", "always"}) {
+		t.Errorf("Expected once to be notified once, got %v", calls)
+	}
+}
+
+func TestObservable_CancelOtherWhileNotified(t *testing.T) {
+	var o Observable[int]
+	var calls []string
+	var b Subscription
+	o.SubscribeFunc(func(data int) {
+		calls = append(calls, "a")
+		b.Cancel()
+	})
+	b = o.SubscribeFunc(func(data int) { calls = append(calls, "b") })
+	o.SubscribeFunc(func(data int) { calls = append(calls, "c") })
+
+	o.Fire(1)
+	o.Fire(2)
+	// b still gets the event it was cancelled during, but not the next one
+	if !slices.Equal(calls, []string{"a", "b", "c", "a", "c"}) {
+		t.Errorf("Unexpected notifications %v", calls)
+	}
+}
+
+func TestObservable_SubscribeWhileNotified(t *testing.T) {
+	var o Observable[int]
+	var calls []string
+	o.SubscribeFunc(func(data int) {
+		calls = append(calls, "a")
+		if data == 1 {
+			o.SubscribeFunc(func(int) { calls = append(calls, "late") })
+		}
+	})
+
+	o.Fire(1)
+	o.Fire(2)
+	if !slices.Equal(calls, []string{"a", "a", "late"}) {
+		t.Errorf("Expected the new observer from the next event on, got %v", calls)
+	}
+}
+
+func TestObservable_NestedFire(t *testing.T) {
+	var o Observable[int]
+	var first, second []int
+	o.SubscribeFunc(func(data int) {
+		first = append(first, data)
+		if data < 3 {
+			o.Fire(data + 1) // queued until every observer got data
+		}
+	})
+	o.SubscribeFunc(func(data int) { second = append(second, data) })
+
+	o.Fire(1)
+	if !slices.Equal(first, []int{1, 2, 3}) || !slices.Equal(second, []int{1, 2, 3}) {
+		t.Errorf("Expected both observers to see 1 2 3 in order, got %v and %v", first, second)
+	}
+}
+
+func TestObservable_PanicResets(t *testing.T) {
+	var o Observable[int]
+	var got []int
+	o.SubscribeFunc(func(data int) {
+		got = append(got, data)
+		if data == 1 {
+			o.Fire(2)
+			panic("boom")
+		}
+	})
+
+	func() {
+		defer func() { recover() }()
+		o.Fire(1)
+	}()
+	o.Fire(3)
+	if !slices.Equal(got, []int{1, 3}) {
+		t.Errorf("Expected the observable to work after a panic, got %v", got)
+	}
+}
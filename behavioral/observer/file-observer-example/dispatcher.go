@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+)
+
+var ErrClosed = errors.New("dispatcher is closed")
+
+type DispatcherConfig struct {
+	// Workers is the number of goroutines shared by all the subscribers.
+	// A subscriber only ever runs on one of them at a time.
+	Workers int
+	// QueueSize is the number of events a subscriber may have waiting
+	// before Publish blocks, or 0 for no limit. With a limit, the
+	// subscribers must not publish themselves.
+	QueueSize int
+	// ErrorBuffer is the capacity of the Errors channel. Errors that do not
+	// fit are counted and dropped, so that the workers never wait for a
+	// reader.
+	ErrorBuffer int
+}
+
+var DefaultDispatcher = DispatcherConfig{Workers: 4, ErrorBuffer: 16}
+
+// PanicError is sent to the Errors channel when a subscriber panics. The
+// subscriber keeps getting the next events.
+type PanicError struct {
+	Subscriber string
+	Event      any
+	Value      any
+	Stack      []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("subscriber %s panicked on %v: %v", e.Subscriber, e.Event, e.Value)
+}
+
+// Dispatcher delivers events asynchronously. Every subscriber has its own
+// queue and gets its events in the order they were published, but the
+// subscribers run in parallel on a pool of workers, so a slow one only
+// delays itself.
+type Dispatcher[T any] struct {
+	config DispatcherConfig
+	errs   chan error
+
+	mu            sync.Mutex
+	changed       *sync.Cond
+	subscribers   []*subscriber[T]
+	ready         []*subscriber[T] // with events and not on a worker
+	pending       int              // events queued or being handled
+	publishing    int              // Publish calls waiting for room
+	closed        bool
+	droppedErrors int
+	workers       sync.WaitGroup
+}
+
+type subscriber[T any] struct {
+	name      string
+	handle    func(T)
+	queue     []T
+	scheduled bool // in ready or on a worker
+	cancelled bool
+}
+
+func NewDispatcher[T any](config DispatcherConfig) *Dispatcher[T] {
+	config.Workers = max(config.Workers, 1)
+	d := &Dispatcher[T]{config: config, errs: make(chan error, config.ErrorBuffer)}
+	d.changed = sync.NewCond(&d.mu)
+	d.workers.Add(config.Workers)
+	for range config.Workers {
+		go d.work()
+	}
+	return d
+}
+
+// DispatcherSubscription is the handle Subscribe returns.
+type DispatcherSubscription[T any] struct {
+	d *Dispatcher[T]
+	s *subscriber[T]
+}
+
+// Cancel stops the deliveries to the subscriber, and discards the events
+// it has not got yet. An event it is handling right now is finished.
+func (sub DispatcherSubscription[T]) Cancel() {
+	d := sub.d
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if sub.s.cancelled {
+		return
+	}
+	sub.s.cancelled = true
+	d.pending -= len(sub.s.queue)
+	sub.s.queue = nil
+	d.subscribers = slices.DeleteFunc(d.subscribers, func(s *subscriber[T]) bool { return s == sub.s })
+	d.changed.Broadcast()
+}
+
+// Subscribe adds a subscriber for the events published from now on. The
+// name identifies it in a PanicError.
+func (d *Dispatcher[T]) Subscribe(name string, handle func(event T)) DispatcherSubscription[T] {
+	s := &subscriber[T]{name: name, handle: handle}
+	d.mu.Lock()
+	d.subscribers = append(d.subscribers, s)
+	d.mu.Unlock()
+	return DispatcherSubscription[T]{d, s}
+}
+
+// Publish queues the event for every subscriber and returns without
+// waiting for them, unless a bounded queue is full. A subscriber may
+// publish from its handler only when QueueSize is 0: with a bounded queue
+// it could wait for room in its own queue, which only it can make.
+func (d *Dispatcher[T]) Publish(event T) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClosed
+	}
+	d.publishing++
+	for _, s := range slices.Clone(d.subscribers) {
+		for d.config.QueueSize > 0 && len(s.queue) >= d.config.QueueSize && !s.cancelled {
+			d.changed.Wait()
+		}
+		if s.cancelled {
+			continue
+		}
+		s.queue = append(s.queue, event)
+		d.pending++
+		if !s.scheduled {
+			s.scheduled = true
+			d.ready = append(d.ready, s)
+		}
+		d.changed.Broadcast()
+	}
+	d.publishing--
+	d.changed.Broadcast()
+	return nil
+}
+
+func (d *Dispatcher[T]) work() {
+	defer d.workers.Done()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for {
+		for len(d.ready) == 0 && !(d.closed && d.publishing == 0) {
+			d.changed.Wait()
+		}
+		if len(d.ready) == 0 {
+			return
+		}
+		s := d.ready[0]
+		d.ready = d.ready[1:]
+		if len(s.queue) == 0 {
+			s.scheduled = false // cancelled while it waited
+			continue
+		}
+		// one event per turn, so that the subscribers share the workers
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		d.changed.Broadcast() // there is room in the queue
+
+		d.mu.Unlock()
+		err := deliver(s, event)
+		d.mu.Lock()
+
+		if err != nil {
+			select {
+			case d.errs <- err:
+			default:
+				d.droppedErrors++
+			}
+		}
+		d.pending--
+		if len(s.queue) > 0 {
+			d.ready = append(d.ready, s)
+		} else {
+			s.scheduled = false
+		}
+		d.changed.Broadcast()
+	}
+}
+
+func deliver[T any](s *subscriber[T], event T) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = &PanicError{Subscriber: s.name, Event: event, Value: v, Stack: debug.Stack()}
+		}
+	}()
+	s.handle(event)
+	return nil
+}
+
+// Errors returns the channel the panics of the subscribers are sent to. It
+// is closed by Close.
+func (d *Dispatcher[T]) Errors() <-chan error {
+	return d.errs
+}
+
+// DroppedErrors returns the number of errors that did not fit in the
+// Errors channel.
+func (d *Dispatcher[T]) DroppedErrors() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.droppedErrors
+}
+
+// Flush waits until every event published so far has been handled. A
+// subscriber must not call it, since it would wait for itself.
+func (d *Dispatcher[T]) Flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for d.pending > 0 {
+		d.changed.Wait()
+	}
+}
+
+// Close stops accepting events, waits until the ones already published have
+// been handled, and stops the workers.
+func (d *Dispatcher[T]) Close() {
+	d.mu.Lock()
+	first := !d.closed
+	d.closed = true
+	d.changed.Broadcast()
+	d.mu.Unlock()
+
+	d.workers.Wait()
+	if first {
+		close(d.errs)
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// collector records the events of a subscriber.
+type collector struct {
+	mu  sync.Mutex
+	got []int
+}
+
+func (c *collector) handle(event int) {
+	c.mu.Lock()
+	c.got = append(c.got, event)
+	c.mu.Unlock()
+}
+
+func (c *collector) events() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.got)
+}
+
+func sequence(n int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+func TestDispatcher_FIFOPerSubscriber(t *testing.T) {
+	d := NewDispatcher[int](DispatcherConfig{Workers: 8})
+	defer d.Close()
+	collectors := make([]*collector, 10)
+	for i := range collectors {
+		collectors[i] = &collector{}
+		d.Subscribe("collector", collectors[i].handle)
+	}
+
+	for i := range 500 {
+		if err := d.Publish(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d.Flush()
+	for i, c := range collectors {
+		if got := c.events(); !slices.Equal(got, sequence(500)) {
+			t.Fatalf("Collector %d got the events out of order or incomplete: %v", i, got)
+		}
+	}
+}
+
+func TestDispatcher_SlowSubscriber(t *testing.T) {
+	d := NewDispatcher[int](DispatcherConfig{Workers: 2})
+	defer d.Close()
+	release := make(chan struct{})
+	slow := &collector{}
+	d.Subscribe("slow", func(event int) {
+		<-release
+		slow.handle(event)
+	})
+	fast := &collector{}
+	d.Subscribe("fast", fast.handle)
+
+	for i := range 3 {
+		d.Publish(i) // does not wait for slow
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fast.events()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := fast.events(); !slices.Equal(got, sequence(3)) {
+		t.Errorf("Expected the fast subscriber not to wait for the slow one, got %v", got)
+	}
+	close(release)
+	d.Flush()
+	if got := slow.events(); !slices.Equal(got, sequence(3)) {
+		t.Errorf("Expected the slow subscriber to get every event, got %v", got)
+	}
+}
+
+func TestDispatcher_Panic(t *testing.T) {
+	d := NewDispatcher[int](DispatcherConfig{Workers: 2, ErrorBuffer: 1})
+	c := &collector{}
+	d.Subscribe("picky", func(event int) {
+		if event%2 == 1 {
+			panic("odd")
+		}
+		c.handle(event)
+	})
+
+	for i := range 5 {
+		d.Publish(i)
+	}
+	d.Flush()
+	if got := c.events(); !slices.Equal(got, []int{0, 2, 4}) {
+		t.Errorf("Expected the subscriber to go on after a panic, got %v", got)
+	}
+
+	var p *PanicError
+	if err := <-d.Errors(); !errors.As(err, &p) || p.Subscriber != "picky" || p.Event != 1 || p.Value != "odd" || len(p.Stack) == 0 {
+		t.Errorf("Unexpected error %#v", err)
+	}
+	if d.DroppedErrors() != 1 {
+		t.Errorf("Expected the error that did not fit to be dropped, got %d", d.DroppedErrors())
+	}
+	d.Close()
+	if _, ok := <-d.Errors(); ok {
+		t.Error("Expected Close to close the error channel")
+	}
+}
+
+func TestDispatcher_Close(t *testing.T) {
+	d := NewDispatcher[int](DispatcherConfig{Workers: 1})
+	c := &collector{}
+	d.Subscribe("slow", func(event int) {
+		time.Sleep(time.Millisecond)
+		c.handle(event)
+	})
+	for i := range 20 {
+		d.Publish(i)
+	}
+	d.Close()
+	if got := c.events(); !slices.Equal(got, sequence(20)) {
+		t.Errorf("Expected Close to deliver the queued events first, got %v", got)
+	}
+	if err := d.Publish(20); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	d.Close() // a second time does nothing
+}
+
+func TestDispatcher_QueueSize(t *testing.T) {
+	d := NewDispatcher[int](DispatcherConfig{Workers: 1, QueueSize: 2})
+	defer d.Close()
+	release := make(chan struct{})
+	d.Subscribe("blocked", func(int) { <-release })
+
+	published := make(chan int)
+	go func() {
+		for i := range 5 {
+			d.Publish(i)
+			published <- i
+		}
+	}()
+	// one event on the worker and two queued, then Publish waits
+	for range 3 {
+		<-published
+	}
+	select {
+	case i := <-published:
+		t.Fatalf("Expected Publish to wait for room, but event %d got through", i)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	for range 2 {
+		<-published
+	}
+}
+
+func TestDispatcher_Cancel(t *testing.T) {
+	d := NewDispatcher[int](DispatcherConfig{Workers: 1})
+	defer d.Close()
+	release := make(chan struct{})
+	c := &collector{}
+	sub := d.Subscribe("cancelled", func(event int) {
+		<-release
+		c.handle(event)
+	})
+	for i := range 3 {
+		d.Publish(i)
+	}
+	time.Sleep(10 * time.Millisecond) // event 0 is on the worker
+	sub.Cancel()
+	sub.Cancel()
+	close(release)
+	d.Flush()
+	d.Publish(3)
+	d.Flush()
+	if got := c.events(); len(got) > 1 {
+		t.Errorf("Expected the queued events to be discarded, got %v", got)
+	}
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrStoreClosed = errors.New("event store is closed")
+	// ErrRotated is returned by a replay from a sequence number whose
+	// segment has been rotated away.
+	ErrRotated = errors.New("events rotated away")
+	// ErrStoreFailed is returned by the appends after a write that could not
+	// be undone, which left part of a line in the last segment.
+	ErrStoreFailed = errors.New("event store failed")
+)
+
+type StoreConfig struct {
+	Dir string
+	// SegmentSize is the size in bytes after which a new segment file is
+	// started. An event is never split across segments, so a segment may
+	// grow a little beyond it.
+	SegmentSize int64
+	// MaxSegments is the number of segments kept. When a new one is
+	// started, the oldest ones beyond it are removed. 0 keeps them all.
+	MaxSegments int
+}
+
+var DefaultStore = StoreConfig{Dir: "events", SegmentSize: 4 << 20, MaxSegments: 8}
+
+// StoredEvent is an event with the sequence number the store gave it. The
+// numbers start at 1 and have no gaps.
+type StoredEvent struct {
+	Seq   uint64 `json:"seq"`
+	Event Event  `json:"event"`
+}
+
+// EventStore is a listener that appends every event it gets to segment
+// files, one JSON object per line. The segments are named after the
+// sequence number of their first event.
+//
+// Replay feeds the stored events to a listener. Subscribe does the same for
+// a late subscriber, and then passes it the new events, so that it neither
+// misses nor repeats one.
+type EventStore struct {
+	config StoreConfig
+
+	mu          sync.Mutex // serializes appends, replays and live deliveries
+	segments    []segment
+	file        segmentFile // the last segment, opened for appending
+	next        uint64
+	subscribers []*StoreSubscription
+	closed      bool
+	failed      error
+}
+
+// segmentFile is an *os.File, except in the tests.
+type segmentFile interface {
+	io.WriteCloser
+	Truncate(size int64) error
+}
+
+type segment struct {
+	first uint64
+	path  string
+	size  int64
+}
+
+const segmentSuffix = ".jsonl"
+
+// OpenEventStore opens the store in config.Dir, creating the directory if
+// needed, and continues the sequence numbers where they stopped. A last
+// line cut short by a crash is dropped.
+func OpenEventStore(config StoreConfig) (*EventStore, error) {
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &EventStore{config: config, next: 1}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentSuffix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		first, err := strconv.ParseUint(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		s.segments = append(s.segments, segment{first, filepath.Join(config.Dir, entry.Name()), info.Size()})
+	}
+	slices.SortFunc(s.segments, func(a, b segment) int { return cmp.Compare(a.first, b.first) })
+
+	if len(s.segments) > 0 {
+		last := &s.segments[len(s.segments)-1]
+		if err := s.recover(last); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(last.path, os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		s.file = f
+	}
+	return s, nil
+}
+
+// recover finds the next sequence number after the last segment, and
+// truncates the segment after its last complete line.
+func (s *EventStore) recover(last *segment) error {
+	data, err := os.ReadFile(last.path)
+	if err != nil {
+		return err
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	if complete < len(data) {
+		if err := os.Truncate(last.path, int64(complete)); err != nil {
+			return err
+		}
+		last.size = int64(complete)
+	}
+	s.next = last.first
+	lines := bytes.Split(data[:complete], []byte("\n"))
+	for i := len(lines) - 1; i >= 0; i-- {
+		if len(bytes.TrimSpace(lines[i])) == 0 {
+			continue
+		}
+		var stored StoredEvent
+		if err := json.Unmarshal(lines[i], &stored); err != nil {
+			return fmt.Errorf("%s: %w", last.path, err)
+		}
+		s.next = stored.Seq + 1
+		break
+	}
+	return nil
+}
+
+func (s *EventStore) update(event Event) {
+	if _, err := s.Append(event); err != nil {
+		log.Println("event store:", err)
+	}
+}
+
+// Append stores the event, passes it to the subscribers and returns its
+// sequence number. A failed write is cut off the segment; if that fails too,
+// the next appends fail with ErrStoreFailed.
+func (s *EventStore) Append(event Event) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return 0, ErrStoreClosed
+	}
+	if s.failed != nil {
+		return 0, s.failed
+	}
+	stored := StoredEvent{Seq: s.next, Event: event}
+	line, err := json.Marshal(stored)
+	if err != nil {
+		return 0, err
+	}
+	line = append(line, '\n')
+	if s.file == nil || s.full(len(line)) {
+		if err := s.roll(); err != nil {
+			return 0, err
+		}
+	}
+	last := &s.segments[len(s.segments)-1]
+	if n, err := s.file.Write(line); err != nil {
+		// a partial line would be read as the start of the next event
+		if n > 0 {
+			if tErr := s.file.Truncate(last.size); tErr != nil {
+				s.failed = fmt.Errorf("%w: %s has a partial line: %v", ErrStoreFailed, last.path, tErr)
+			}
+		}
+		return 0, err
+	}
+	last.size += int64(len(line))
+	s.next++
+
+	live := s.subscribers[:0]
+	for _, sub := range s.subscribers {
+		if !sub.cancelled.Load() {
+			sub.listener.update(event)
+			live = append(live, sub)
+		}
+	}
+	clear(s.subscribers[len(live):])
+	s.subscribers = live
+	return stored.Seq, nil
+}
+
+// full tells whether n more bytes would take the last segment beyond
+// SegmentSize. An empty segment always takes the event.
+func (s *EventStore) full(n int) bool {
+	size := s.segments[len(s.segments)-1].size
+	return size > 0 && size+int64(n) > s.config.SegmentSize
+}
+
+// roll starts a new segment with the next event, and removes the oldest
+// segments beyond MaxSegments.
+func (s *EventStore) roll() error {
+	if s.file != nil {
+		if err := s.file.Close(); err != nil {
+			return err
+		}
+		s.file = nil
+	}
+	path := filepath.Join(s.config.Dir, fmt.Sprintf("%020d%s", s.next, segmentSuffix))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file = f
+	s.segments = append(s.segments, segment{first: s.next, path: path})
+
+	if over := len(s.segments) - s.config.MaxSegments; s.config.MaxSegments > 0 && over > 0 {
+		for _, old := range s.segments[:over] {
+			if err := os.Remove(old.path); err != nil {
+				return err
+			}
+		}
+		s.segments = slices.Delete(s.segments, 0, over)
+	}
+	return nil
+}
+
+// First returns the sequence number of the oldest event kept, or the next
+// one if there is none.
+func (s *EventStore) First() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.first()
+}
+
+func (s *EventStore) first() uint64 {
+	if len(s.segments) == 0 {
+		return s.next
+	}
+	return s.segments[0].first
+}
+
+// Next returns the sequence number the next event will get.
+func (s *EventStore) Next() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.next
+}
+
+// Read calls f with the events from sequence number from on, in order,
+// until f returns an error. From 0 is from the oldest event kept; other
+// sequence numbers before First are an ErrRotated.
+func (s *EventStore) Read(from uint64, f func(StoredEvent) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(from, f)
+}
+
+func (s *EventStore) read(from uint64, f func(StoredEvent) error) error {
+	if s.closed {
+		return ErrStoreClosed
+	}
+	if from == 0 {
+		from = s.first()
+	}
+	if from < s.first() {
+		return fmt.Errorf("%w: %d is before %d", ErrRotated, from, s.first())
+	}
+	for i, seg := range s.segments {
+		if i+1 < len(s.segments) && s.segments[i+1].first <= from {
+			continue // entirely before from
+		}
+		if err := readSegment(seg.path, from, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSegment(path string, from uint64, f func(StoredEvent) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var stored StoredEvent
+		if err := json.Unmarshal(scanner.Bytes(), &stored); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if stored.Seq < from {
+			continue
+		}
+		if err := f(stored); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Replay feeds the listener the events from sequence number from on, and
+// returns the sequence number to continue from.
+func (s *EventStore) Replay(listener EventListener, from uint64) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replay(listener, from)
+}
+
+func (s *EventStore) replay(listener EventListener, from uint64) (uint64, error) {
+	err := s.read(from, func(stored StoredEvent) error {
+		listener.update(stored.Event)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return max(from, s.next), nil
+}
+
+// ReplaySince feeds the listener the events from the first one with a
+// timestamp at or after since, and returns the sequence number to continue
+// from. Only the events kept are searched.
+func (s *EventStore) ReplaySince(listener EventListener, since time.Time) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, err := s.seqSince(since)
+	if err != nil {
+		return 0, err
+	}
+	return s.replay(listener, from)
+}
+
+// errStop stops a read early.
+var errStop = errors.New("stop reading")
+
+// seqSince returns the sequence number of the first event at or after
+// since, or the next one if there is none.
+func (s *EventStore) seqSince(since time.Time) (uint64, error) {
+	found := s.next
+	err := s.read(s.first(), func(stored StoredEvent) error {
+		if !stored.Event.Timestamp.Before(since) {
+			found = stored.Seq
+			return errStop
+		}
+		return nil
+	})
+	if errors.Is(err, errStop) {
+		err = nil
+	}
+	return found, err
+}
+
+// StoreSubscription is the handle Subscribe returns.
+type StoreSubscription struct {
+	listener  EventListener
+	cancelled atomic.Bool
+}
+
+// Cancel stops the deliveries to the listener. It may be called from the
+// listener itself.
+func (sub *StoreSubscription) Cancel() {
+	sub.cancelled.Store(true)
+}
+
+// Subscribe replays the events from sequence number from on to the
+// listener, and then passes it every event appended, as Append stores it.
+// The listener runs with the store locked, so it must not call the store
+// other than to Cancel.
+func (s *EventStore) Subscribe(listener EventListener, from uint64) (*StoreSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.subscribe(listener, from)
+}
+
+// SubscribeSince is Subscribe from the first event at or after since.
+func (s *EventStore) SubscribeSince(listener EventListener, since time.Time) (*StoreSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, err := s.seqSince(since)
+	if err != nil {
+		return nil, err
+	}
+	return s.subscribe(listener, from)
+}
+
+func (s *EventStore) subscribe(listener EventListener, from uint64) (*StoreSubscription, error) {
+	if _, err := s.replay(listener, from); err != nil {
+		return nil, err
+	}
+	sub := &StoreSubscription{listener: listener}
+	s.subscribers = append(s.subscribers, sub)
+	return sub, nil
+}
+
+// Close closes the last segment. Appends after Close fail with
+// ErrStoreClosed.
+func (s *EventStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.subscribers = nil
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func openStore(t *testing.T, config StoreConfig) *EventStore {
+	t.Helper()
+	s, err := OpenEventStore(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// appendEvents appends n saves of 0.txt, 1.txt and so on, a minute apart.
+func appendEvents(t *testing.T, s *EventStore, first, n int) {
+	t.Helper()
+	for i := first; i < first+n; i++ {
+		event := Event{Type: SAVE, Filename: fmt.Sprint(i, ".txt"), Timestamp: epoch.Add(time.Duration(i) * time.Minute)}
+		if _, err := s.Append(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func filenames(s *EventStore, from uint64) ([]string, error) {
+	var calls []string
+	_, err := s.Replay(&recordingListener{name: "r", calls: &calls}, from)
+	return calls, err
+}
+
+func expectedFilenames(first, last int) []string {
+	var names []string
+	for i := first; i <= last; i++ {
+		names = append(names, fmt.Sprint("r:", i, ".txt"))
+	}
+	return names
+}
+
+func TestEventStore_Reopen(t *testing.T) {
+	config := StoreConfig{Dir: t.TempDir(), SegmentSize: 1 << 20}
+	s := openStore(t, config)
+	if seq, err := s.Append(Event{Type: OPEN, Filename: "a"}); err != nil || seq != 1 {
+		t.Fatalf("Expected the first event to be 1, got %d, %v", seq, err)
+	}
+	appendEvents(t, s, 1, 4)
+	s.Close()
+	if _, err := s.Append(Event{}); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("Expected ErrStoreClosed, got %v", err)
+	}
+
+	s = openStore(t, config)
+	if seq, _ := s.Append(Event{Type: OPEN, Filename: "b"}); seq != 6 {
+		t.Errorf("Expected the numbers to go on after a restart, got %d", seq)
+	}
+	var stored []StoredEvent
+	s.Read(0, func(e StoredEvent) error {
+		stored = append(stored, e)
+		return nil
+	})
+	if len(stored) != 6 || stored[0].Event.Filename != "a" || stored[5].Event.Filename != "b" || stored[2].Event.Type != SAVE {
+		t.Errorf("Unexpected events %+v", stored)
+	}
+}
+
+func TestEventStore_CrashedWrite(t *testing.T) {
+	config := StoreConfig{Dir: t.TempDir(), SegmentSize: 1 << 20}
+	s := openStore(t, config)
+	appendEvents(t, s, 1, 2)
+	s.Close()
+	path := filepath.Join(config.Dir, "00000000000000000001.jsonl")
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"seq":3,"event":{"ty`)
+	f.Close()
+
+	s = openStore(t, config)
+	if seq, _ := s.Append(Event{Type: SAVE, Filename: "3.txt"}); seq != 3 {
+		t.Errorf("Expected the cut line to be dropped, got seq %d", seq)
+	}
+	if got, err := filenames(s, 1); err != nil || !slices.Equal(got, expectedFilenames(1, 3)) {
+		t.Errorf("Unexpected replay %v, %v", got, err)
+	}
+}
+
+// shortWriter writes half of each line and fails.
+type shortWriter struct {
+	segmentFile
+	truncateErr error
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	n, _ := w.segmentFile.Write(p[:len(p)/2])
+	return n, io.ErrShortWrite
+}
+
+func (w *shortWriter) Truncate(size int64) error {
+	if w.truncateErr != nil {
+		return w.truncateErr
+	}
+	return w.segmentFile.Truncate(size)
+}
+
+func TestEventStore_ShortWrite(t *testing.T) {
+	s := openStore(t, StoreConfig{Dir: t.TempDir(), SegmentSize: 1 << 20})
+	appendEvents(t, s, 1, 2)
+	file := s.file
+	s.file = &shortWriter{segmentFile: file}
+	if _, err := s.Append(Event{Type: SAVE, Filename: "lost.txt"}); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("Expected the write error, got %v", err)
+	}
+	s.file = file
+	if seq, err := s.Append(Event{Type: SAVE, Filename: "3.txt"}); err != nil || seq != 3 {
+		t.Fatalf("Expected event 3 after the failed write, got %d, %v", seq, err)
+	}
+	if got, err := filenames(s, 1); err != nil || !slices.Equal(got, expectedFilenames(1, 3)) {
+		t.Errorf("Expected the partial line to be cut off, got %v, %v", got, err)
+	}
+
+	// the partial line cannot be cut off: no more appends
+	s.file = &shortWriter{segmentFile: file, truncateErr: errors.New("read-only file system")}
+	s.Append(Event{Type: SAVE, Filename: "lost.txt"})
+	s.file = file
+	if _, err := s.Append(Event{Type: SAVE, Filename: "4.txt"}); !errors.Is(err, ErrStoreFailed) {
+		t.Errorf("Expected ErrStoreFailed, got %v", err)
+	}
+}
+
+func TestEventStore_Rotation(t *testing.T) {
+	config := StoreConfig{Dir: t.TempDir(), SegmentSize: 300, MaxSegments: 3}
+	s := openStore(t, config)
+	appendEvents(t, s, 1, 40)
+
+	entries, _ := os.ReadDir(config.Dir)
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 segments, got %d", len(entries))
+	}
+	for _, entry := range entries[:2] {
+		if info, _ := entry.Info(); info.Size() > config.SegmentSize {
+			t.Errorf("Expected %s to roll over at %d bytes, got %d", entry.Name(), config.SegmentSize, info.Size())
+		}
+	}
+
+	first := s.First()
+	if first == 1 || s.Next() != 41 {
+		t.Fatalf("Expected the oldest segments to be removed, got %d to %d", first, s.Next())
+	}
+	if _, err := filenames(s, 1); !errors.Is(err, ErrRotated) {
+		t.Errorf("Expected ErrRotated, got %v", err)
+	}
+	if got, err := filenames(s, 0); err != nil || !slices.Equal(got, expectedFilenames(int(first), 40)) {
+		t.Errorf("Expected the events kept, got %v, %v", got, err)
+	}
+	if got, _ := filenames(s, 38); !slices.Equal(got, expectedFilenames(38, 40)) {
+		t.Errorf("Expected the events from 38, got %v", got)
+	}
+
+	// the segments are found again after a restart
+	s.Close()
+	s = openStore(t, config)
+	if s.First() != first || s.Next() != 41 {
+		t.Errorf("Expected %d to 41 after a restart, got %d to %d", first, s.First(), s.Next())
+	}
+}
+
+func TestEventStore_ReplaySince(t *testing.T) {
+	s := openStore(t, StoreConfig{Dir: t.TempDir(), SegmentSize: 200})
+	appendEvents(t, s, 1, 10)
+
+	var calls []string
+	next, err := s.ReplaySince(&recordingListener{name: "r", calls: &calls}, epoch.Add(7*time.Minute))
+	if err != nil || next != 11 || !slices.Equal(calls, expectedFilenames(7, 10)) {
+		t.Errorf("Expected 7 to 10 and 11 next, got %v, %d, %v", calls, next, err)
+	}
+	calls = nil
+	if next, _ := s.ReplaySince(&recordingListener{name: "r", calls: &calls}, epoch.Add(time.Hour)); len(calls) != 0 || next != 11 {
+		t.Errorf("Expected nothing after the last event, got %v, %d", calls, next)
+	}
+}
+
+func TestEventStore_Subscribe(t *testing.T) {
+	s := openStore(t, StoreConfig{Dir: t.TempDir(), SegmentSize: 1 << 20})
+	e := newEventManager()
+	e.subscribe(SAVE, s)
+	editor := &Editor{events: e}
+	editor.saveFile("1.txt")
+	editor.saveFile("2.txt")
+
+	// a late subscriber catches up, and then gets the new events
+	var calls []string
+	late := &recordingListener{name: "r", calls: &calls}
+	var sub *StoreSubscription
+	late.on = func(event Event) {
+		if event.Filename == "4.txt" {
+			sub.Cancel()
+		}
+	}
+	sub, err := s.Subscribe(late, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 3; i <= 5; i++ {
+		editor.saveFile(fmt.Sprint(i, ".txt"))
+	}
+	if !slices.Equal(calls, expectedFilenames(1, 4)) {
+		t.Errorf("Expected the stored events and then the new ones until cancelled, got %v", calls)
+	}
+
+	calls = nil
+	if _, err := s.SubscribeSince(&recordingListener{name: "r", calls: &calls}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	editor.saveFile("6.txt")
+	if !slices.Equal(calls, expectedFilenames(6, 6)) {
+		t.Errorf("Expected only the new event, got %v", calls)
+	}
+}
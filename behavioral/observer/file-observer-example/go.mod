@@ -0,0 +1,3 @@
+module observer-file
+
+go 1.23.6
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 // Business object
 type Editor struct {
-	events *EventManager
+	events EventPublisher
 }
 
 func (e *Editor) openFile(filename string) {
@@ -33,38 +43,181 @@ type EventType int
 const (
 	OPEN EventType = iota
 	SAVE
+	// published by a Watcher
+	CREATE
+	MODIFY
+	DELETE
+	RENAME
 )
 
+func (t EventType) String() string {
+	switch t {
+	case OPEN:
+		return "open file"
+	case SAVE:
+		return "save file"
+	case CREATE:
+		return "create file"
+	case MODIFY:
+		return "modify file"
+	case DELETE:
+		return "delete file"
+	case RENAME:
+		return "rename file"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// MarshalText encodes the type as a word, e.g. "save" in JSON.
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.word()), nil
+}
+
+func (t *EventType) UnmarshalText(text []byte) error {
+	for candidate := OPEN; candidate <= RENAME; candidate++ {
+		if candidate.word() == string(text) {
+			*t = candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown event type %q", text)
+}
+
+func (t EventType) word() string {
+	return strings.TrimSuffix(t.String(), " file")
+}
+
 type Event struct {
-	Type      EventType
-	Filename  string
-	Timestamp time.Time
+	Type        EventType `json:"type"`
+	Filename    string    `json:"filename"`
+	OldFilename string    `json:"old_filename,omitempty"` // the name before a RENAME
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+func (e Event) describe() string {
+	if e.Type == RENAME {
+		return fmt.Sprint(e.Type, " ", e.OldFilename, " -> ", e.Filename)
+	}
+	return fmt.Sprint(e.Type, " ", e.Filename)
+}
+
+// EventPublisher is implemented by EventManager, which notifies the
+// listeners before notify returns, and by AsyncEventManager, which does not
+// wait for them.
+type EventPublisher interface {
+	subscribe(eventType EventType, listener EventListener)
+	unsubscribe(eventType EventType, listener EventListener)
+	notify(event Event)
 }
 
 // Subject (Publisher)
+//
+// Listeners may subscribe, unsubscribe and notify while they are updated.
+// The changes apply from the next event on, and a notify from a listener is
+// queued until every listener got the current event.
 type EventManager struct {
 	listeners map[EventType][]EventListener
+	topics    topicTree
+	notifying bool
+	queued    []Event
 }
 
 func (e *EventManager) subscribe(eventType EventType, listener EventListener) {
 	e.listeners[eventType] = append(e.listeners[eventType], listener)
 }
+
+// unsubscribe makes a new slice instead of shifting the listeners in place,
+// which would make a notify in progress skip one.
 func (e *EventManager) unsubscribe(eventType EventType, listener EventListener) {
-	if e.listeners[eventType] != nil {
-		for i, item := range e.listeners[eventType] {
-			if item == listener {
-				e.listeners[eventType] = append(e.listeners[eventType][:i], e.listeners[eventType][i+1:]...)
-				break
-			}
-		}
+	listeners := e.listeners[eventType]
+	if i := slices.Index(listeners, listener); i >= 0 {
+		e.listeners[eventType] = slices.Concat(listeners[:i], listeners[i+1:])
 	}
 }
 
 func (e *EventManager) notify(event Event) {
-	if e.listeners[event.Type] != nil {
-		for _, item := range e.listeners[event.Type] {
+	if e.notifying {
+		e.queued = append(e.queued, event)
+		return
+	}
+	e.notifying = true
+	defer func() {
+		e.notifying = false
+		e.queued = nil
+	}()
+	for {
+		// a subscribe appends beyond the end of this slice, and an
+		// unsubscribe replaces it, so it stays as it was
+		listeners := e.listeners[event.Type]
+		matched := e.topics.match(event.Topic())
+		for _, item := range listeners {
 			item.update(event)
 		}
+		for _, s := range matched {
+			if s.accepts(event) {
+				s.listener.update(event)
+			}
+		}
+		if len(e.queued) == 0 {
+			return
+		}
+		event = e.queued[0]
+		e.queued = e.queued[1:]
+	}
+}
+
+// AsyncEventManager notifies the listeners through a Dispatcher, each
+// listener through its own queue.
+type AsyncEventManager struct {
+	dispatcher *Dispatcher[Event]
+
+	mu        sync.Mutex
+	listeners map[EventListener]*asyncListener
+}
+
+type asyncListener struct {
+	types        map[EventType]bool
+	subscription DispatcherSubscription[Event]
+}
+
+func NewAsyncEventManager(dispatcher *Dispatcher[Event]) *AsyncEventManager {
+	return &AsyncEventManager{dispatcher: dispatcher, listeners: make(map[EventListener]*asyncListener)}
+}
+
+func (e *AsyncEventManager) subscribe(eventType EventType, listener EventListener) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	l, ok := e.listeners[listener]
+	if !ok {
+		l = &asyncListener{types: make(map[EventType]bool)}
+		l.subscription = e.dispatcher.Subscribe(fmt.Sprintf("%T", listener), func(event Event) {
+			e.mu.Lock()
+			wanted := l.types[event.Type]
+			e.mu.Unlock()
+			if wanted {
+				listener.update(event)
+			}
+		})
+		e.listeners[listener] = l
+	}
+	l.types[eventType] = true
+}
+
+func (e *AsyncEventManager) unsubscribe(eventType EventType, listener EventListener) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if l, ok := e.listeners[listener]; ok {
+		delete(l.types, eventType)
+		if len(l.types) == 0 {
+			l.subscription.Cancel()
+			delete(e.listeners, listener)
+		}
+	}
+}
+
+func (e *AsyncEventManager) notify(event Event) {
+	if err := e.dispatcher.Publish(event); err != nil {
+		log.Println("notify:", err)
 	}
 }
 
@@ -77,31 +230,82 @@ type EventListener interface {
 type EmailAlertListener struct{}
 
 func (e *EmailAlertListener) update(event Event) {
-	var action string
-	if event.Type == OPEN {
-		action = "open file"
-	}
-	if event.Type == SAVE {
-		action = "save file"
-	}
-	fmt.Println(event.Timestamp, "[EMAIL]", action, event.Filename)
+	fmt.Println(event.Timestamp, "[EMAIL]", event.describe())
 }
 
 type LoggingListener struct {
 }
 
 func (l *LoggingListener) update(event Event) {
-	var action string
-	if event.Type == OPEN {
-		action = "open file"
-	}
-	if event.Type == SAVE {
-		action = "save file"
-	}
-	fmt.Println(event.Timestamp, "[LOGGER]", action, event.Filename)
+	fmt.Println(event.Timestamp, "[LOGGER]", event.describe())
+}
+
+// PrintListener prints the events with a prefix.
+type PrintListener struct {
+	prefix string
+}
+
+func (p *PrintListener) update(event Event) {
+	fmt.Println(p.prefix, event.describe())
+}
+
+// SlowEmailListener takes its time to reach the mail server.
+type SlowEmailListener struct {
+	delay time.Duration
+}
+
+func (e *SlowEmailListener) update(event Event) {
+	time.Sleep(e.delay)
+	fmt.Println("[SLOW EMAIL]", event.Filename)
+}
+
+// BrokenListener panics on every event.
+type BrokenListener struct{}
+
+func (b *BrokenListener) update(event Event) {
+	panic("cannot index " + event.Filename)
 }
 
 func main() {
+	watch := flag.String("watch", "", "print the changes to the files in this directory until interrupted")
+	webhooks := flag.String("webhook", "", "comma-separated URLs to post the changes of -watch to, signed with $WEBHOOK_SECRET")
+	deadLetters := flag.String("dead-letters", DefaultWebhook.DeadLetters, "where -webhook keeps the events it could not deliver")
+	replay := flag.Bool("replay", false, "deliver the dead letters again and exit")
+	flag.Parse()
+
+	if *replay && *webhooks == "" {
+		log.Fatal("-replay needs the -webhook endpoints the dead letters were for")
+	}
+	var webhook *WebhookListener
+	if *webhooks != "" {
+		config := DefaultWebhook
+		config.DeadLetters = *deadLetters
+		var secret []byte
+		if s := os.Getenv("WEBHOOK_SECRET"); s != "" {
+			secret = []byte(s)
+		}
+		for _, url := range strings.Split(*webhooks, ",") {
+			if url != "" {
+				config.Endpoints = append(config.Endpoints, Endpoint{URL: url, Secret: secret})
+			}
+		}
+		webhook = NewWebhookListener(config)
+	}
+	if *replay {
+		delivered, failed, err := webhook.Replay(context.Background())
+		fmt.Println("Delivered", delivered, "dead letters,", failed, "failed again")
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *watch != "" {
+		if err := watchDirectory(*watch, webhook); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	eventManager := &EventManager{listeners: make(map[EventType][]EventListener)}
 	editor := &Editor{events: eventManager}
 
@@ -119,4 +323,184 @@ func main() {
 	editor.events.unsubscribe(SAVE, emailListener)
 	editor.openFile("another.html")
 	editor.saveFile("another.html")
+
+	topics()
+	asynchronous()
+	if err := watching(); err != nil {
+		log.Fatal(err)
+	}
+	storing()
+}
+
+// topics subscribes by topic patterns instead of event types.
+func topics() {
+	eventManager := &EventManager{listeners: make(map[EventType][]EventListener)}
+	editor := &Editor{events: eventManager}
+	logger := &LoggingListener{}
+
+	// everything about HTML files
+	if _, err := eventManager.subscribeTopic("file.#.html", logger); err != nil {
+		log.Fatal(err)
+	}
+	// every save of a file with an extension
+	if _, err := eventManager.subscribeTopic("file.save.*", &EmailAlertListener{}); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := eventManager.subscribeTopic("#", &PrintListener{"[DRAFTS]"}, FilenameMatches("draft-*")); err != nil {
+		log.Fatal(err)
+	}
+
+	editor.openFile("index.html")
+	editor.saveFile("style.css")
+	editor.openFile("draft-2.md")
+}
+
+// asynchronous shows that neither a slow nor a panicking listener holds up
+// the editor when the events go through a dispatcher.
+func asynchronous() {
+	dispatcher := NewDispatcher[Event](DefaultDispatcher)
+	editor := &Editor{events: NewAsyncEventManager(dispatcher)}
+	errorsDone := make(chan struct{})
+	go func() {
+		defer close(errorsDone)
+		for err := range dispatcher.Errors() {
+			var p *PanicError
+			if errors.As(err, &p) {
+				fmt.Println("[ERROR]", p.Subscriber, "panicked:", p.Value)
+			}
+		}
+	}()
+
+	editor.events.subscribe(SAVE, &SlowEmailListener{delay: 200 * time.Millisecond})
+	editor.events.subscribe(SAVE, &BrokenListener{})
+
+	start := time.Now()
+	editor.saveFile("a.html")
+	editor.saveFile("b.html")
+	fmt.Println("The editor saved twice in", time.Since(start).Round(time.Millisecond))
+
+	dispatcher.Flush()
+	fmt.Println("Every listener is done after", time.Since(start).Round(100*time.Millisecond))
+	dispatcher.Close()
+	<-errorsDone
+}
+
+// storing keeps the events in an EventStore, so that a listener
+// subscribing late can catch up.
+func storing() {
+	dir, err := os.MkdirTemp("", "events")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := OpenEventStore(StoreConfig{Dir: dir, SegmentSize: 512, MaxSegments: 2})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	eventManager := &EventManager{listeners: make(map[EventType][]EventListener)}
+	eventManager.subscribe(OPEN, store)
+	eventManager.subscribe(SAVE, store)
+	editor := &Editor{events: eventManager}
+	for i := range 10 {
+		editor.saveFile(fmt.Sprint("page-", i, ".html"))
+	}
+	fmt.Println("The store keeps events", store.First(), "to", store.Next()-1, "of 10")
+
+	// the oldest events were rotated away; the late listener starts from
+	// the ones kept, and then gets the new ones too
+	if _, err := store.Subscribe(&PrintListener{"[LATE]"}, store.First()); err != nil {
+		log.Fatal(err)
+	}
+	editor.openFile("index.html")
+}
+
+// watching makes changes in a temporary directory and lets a Watcher
+// report them.
+func watching() error {
+	dir, err := os.MkdirTemp("", "watched")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	eventManager := &EventManager{listeners: make(map[EventType][]EventListener)}
+	logger := &LoggingListener{}
+	for _, t := range []EventType{CREATE, MODIFY, DELETE, RENAME} {
+		eventManager.subscribe(t, logger)
+	}
+	config := WatcherConfig{Interval: 20 * time.Millisecond, Debounce: 100 * time.Millisecond, Include: []string{"*.html"}}
+	watcher, err := NewWatcher(eventManager, config, dir)
+	if err != nil {
+		return err
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		stop()
+		<-done
+	}()
+
+	settle := func() { time.Sleep(300 * time.Millisecond) }
+	page := filepath.Join(dir, "index.html")
+	for i := range 5 { // a burst of writes is one event
+		if err := os.WriteFile(page, []byte(fmt.Sprint("<p>draft ", i, "</p>")), 0o644); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not included"), 0o644); err != nil {
+		return err
+	}
+	settle()
+	if err := os.WriteFile(page, []byte("<p>final version</p>"), 0o644); err != nil {
+		return err
+	}
+	settle()
+	if err := os.Rename(page, filepath.Join(dir, "home.html")); err != nil {
+		return err
+	}
+	settle()
+	if err := os.Remove(filepath.Join(dir, "home.html")); err != nil {
+		return err
+	}
+	settle()
+	return nil
+}
+
+// watchDirectory prints the changes to the files in dir until interrupted.
+// The webhook, if not nil, gets them too. The events still queued for it
+// are delivered before watchDirectory returns.
+func watchDirectory(dir string, webhook *WebhookListener) error {
+	var events EventPublisher = &EventManager{listeners: make(map[EventType][]EventListener)}
+	if webhook != nil {
+		// the retries must not hold up the watcher
+		dispatcher := NewDispatcher[Event](DefaultDispatcher)
+		defer dispatcher.Close()
+		events = NewAsyncEventManager(dispatcher)
+	}
+	logger := &LoggingListener{}
+	for _, t := range []EventType{CREATE, MODIFY, DELETE, RENAME} {
+		events.subscribe(t, logger)
+		if webhook != nil {
+			events.subscribe(t, webhook)
+		}
+	}
+	config := DefaultWatcher
+	config.Recursive = true
+	watcher, err := NewWatcher(events, config, dir)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Println("Watching", dir)
+	if err := watcher.Run(ctx); !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
 }
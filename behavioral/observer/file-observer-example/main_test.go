@@ -0,0 +1,94 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// recordingListener records its updates, and then runs on.
+type recordingListener struct {
+	name  string
+	calls *[]string
+	on    func(event Event)
+}
+
+func (l *recordingListener) update(event Event) {
+	*l.calls = append(*l.calls, l.name+":"+event.Filename)
+	if l.on != nil {
+		l.on(event)
+	}
+}
+
+func newEventManager() *EventManager {
+	return &EventManager{listeners: make(map[EventType][]EventListener)}
+}
+
+func TestEventManager_UnsubscribeSelf(t *testing.T) {
+	e := newEventManager()
+	var calls []string
+	a := &recordingListener{name: "a", calls: &calls}
+	a.on = func(Event) { e.unsubscribe(SAVE, a) }
+	e.subscribe(SAVE, a)
+	e.subscribe(SAVE, &recordingListener{name: "b", calls: &calls})
+	e.subscribe(SAVE, &recordingListener{name: "c", calls: &calls})
+
+	e.notify(Event{Type: SAVE, Filename: "1"})
+	e.notify(Event{Type: SAVE, Filename: "2"})
+	expected := []string{"a:1", "b:1", "c:1", "b:2", "c:2"}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, calls)
+	}
+}
+
+func TestEventManager_UnsubscribeOther(t *testing.T) {
+	e := newEventManager()
+	var calls []string
+	b := &recordingListener{name: "b", calls: &calls}
+	e.subscribe(SAVE, &recordingListener{name: "a", calls: &calls, on: func(Event) { e.unsubscribe(SAVE, b) }})
+	e.subscribe(SAVE, b)
+	e.subscribe(SAVE, &recordingListener{name: "c", calls: &calls})
+
+	e.notify(Event{Type: SAVE, Filename: "1"})
+	e.notify(Event{Type: SAVE, Filename: "2"})
+	expected := []string{"a:1", "b:1", "c:1", "a:2", "c:2"}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, calls)
+	}
+}
+
+func TestEventManager_SubscribeWhileNotified(t *testing.T) {
+	e := newEventManager()
+	var calls []string
+	added := false
+	e.subscribe(SAVE, &recordingListener{name: "a", calls: &calls, on: func(Event) {
+		if !added {
+			added = true
+			e.subscribe(SAVE, &recordingListener{name: "late", calls: &calls})
+		}
+	}})
+
+	e.notify(Event{Type: SAVE, Filename: "1"})
+	e.notify(Event{Type: SAVE, Filename: "2"})
+	expected := []string{"a:1", "a:2", "late:2"}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, calls)
+	}
+}
+
+func TestEventManager_NestedNotify(t *testing.T) {
+	e := newEventManager()
+	var calls []string
+	e.subscribe(SAVE, &recordingListener{name: "backup", calls: &calls, on: func(event Event) {
+		// saving a backup is an event too, queued until log got this one
+		if event.Filename == "doc" {
+			e.notify(Event{Type: SAVE, Filename: "doc.bak"})
+		}
+	}})
+	e.subscribe(SAVE, &recordingListener{name: "log", calls: &calls})
+
+	e.notify(Event{Type: SAVE, Filename: "doc"})
+	expected := []string{"backup:doc", "log:doc", "backup:doc.bak", "log:doc.bak"}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, calls)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTopic = errors.New("invalid topic pattern")
+
+// Topic places the event in a hierarchy of dot-separated words: "file", the
+// type and the extension of the file, e.g. "file.save.html". A file without
+// an extension has no third word.
+func (e Event) Topic() string {
+	topic := "file." + e.Type.word()
+	if ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(e.Filename), ".")); ext != "" {
+		topic += "." + ext
+	}
+	return topic
+}
+
+// Filter is a predicate on the fields of an event.
+type Filter func(event Event) bool
+
+// FilenameMatches holds for the files whose base name matches the
+// path.Match pattern.
+func FilenameMatches(pattern string) Filter {
+	return func(event Event) bool {
+		ok, _ := path.Match(pattern, filepath.Base(event.Filename))
+		return ok
+	}
+}
+
+// Between holds for the events with a timestamp in [from, to). A zero time
+// leaves that side open.
+func Between(from, to time.Time) Filter {
+	return func(event Event) bool {
+		return (from.IsZero() || !event.Timestamp.Before(from)) && (to.IsZero() || event.Timestamp.Before(to))
+	}
+}
+
+// topicSubscription is a listener with the pattern and the filters it
+// subscribed with.
+type topicSubscription struct {
+	id       int
+	words    []string
+	listener EventListener
+	filters  []Filter
+}
+
+func (s *topicSubscription) accepts(event Event) bool {
+	for _, f := range s.filters {
+		if !f(event) {
+			return false
+		}
+	}
+	return true
+}
+
+// topicTree finds the subscriptions matching a topic without looking at the
+// others. Every node is a word of the patterns: a literal word, "*" for any
+// one word or "#" for any number of words, none included. Matching a topic
+// only walks the branches that can match it, so its cost depends on the
+// depth of the topic and on the wildcards, not on the number of
+// subscriptions.
+type topicTree struct {
+	root   topicNode
+	lastID int
+}
+
+type topicNode struct {
+	children      map[string]*topicNode
+	subscriptions []*topicSubscription
+}
+
+func parsePattern(pattern string) ([]string, error) {
+	words := strings.Split(pattern, ".")
+	for _, w := range words {
+		if w == "" || (strings.ContainsAny(w, "*#") && w != "*" && w != "#") {
+			return nil, fmt.Errorf("%w %q: a word is empty or mixes a wildcard with text", ErrInvalidTopic, pattern)
+		}
+	}
+	return words, nil
+}
+
+func (t *topicTree) add(s *topicSubscription) {
+	t.lastID++
+	s.id = t.lastID
+	n := &t.root
+	for _, w := range s.words {
+		if n.children == nil {
+			n.children = make(map[string]*topicNode)
+		}
+		child, ok := n.children[w]
+		if !ok {
+			child = &topicNode{}
+			n.children[w] = child
+		}
+		n = child
+	}
+	n.subscriptions = append(n.subscriptions, s)
+}
+
+func (t *topicTree) remove(s *topicSubscription) {
+	t.root.remove(s, s.words)
+}
+
+// remove reports whether the node has become empty, so that its parent can
+// drop it.
+func (n *topicNode) remove(s *topicSubscription, words []string) bool {
+	if len(words) == 0 {
+		n.subscriptions = slices.DeleteFunc(n.subscriptions, func(x *topicSubscription) bool { return x == s })
+	} else if child, ok := n.children[words[0]]; ok && child.remove(s, words[1:]) {
+		delete(n.children, words[0])
+	}
+	return len(n.subscriptions) == 0 && len(n.children) == 0
+}
+
+// match returns the subscriptions whose pattern matches the topic, in the
+// order they subscribed.
+func (t *topicTree) match(topic string) []*topicSubscription {
+	found := make(map[*topicSubscription]bool)
+	t.root.match(strings.Split(topic, "."), found)
+	matched := make([]*topicSubscription, 0, len(found))
+	for s := range found {
+		matched = append(matched, s)
+	}
+	slices.SortFunc(matched, func(a, b *topicSubscription) int { return a.id - b.id })
+	return matched
+}
+
+func (n *topicNode) match(words []string, found map[*topicSubscription]bool) {
+	if len(words) == 0 {
+		for _, s := range n.subscriptions {
+			found[s] = true
+		}
+	} else {
+		if child, ok := n.children[words[0]]; ok {
+			child.match(words[1:], found)
+		}
+		if child, ok := n.children["*"]; ok {
+			child.match(words[1:], found)
+		}
+	}
+	if child, ok := n.children["#"]; ok {
+		// # takes none, one or more of the remaining words
+		for i := 0; i <= len(words); i++ {
+			child.match(words[i:], found)
+		}
+	}
+}
+
+// TopicSubscription is the handle subscribeTopic returns.
+type TopicSubscription struct {
+	topics       *topicTree
+	subscription *topicSubscription
+}
+
+// Cancel removes the listener. Cancelling twice does nothing.
+func (s TopicSubscription) Cancel() {
+	if s.topics != nil {
+		s.topics.remove(s.subscription)
+	}
+}
+
+// subscribeTopic subscribes the listener to the events whose topic matches
+// the pattern and that pass every filter. In the pattern, "*" stands for
+// exactly one word and "#" for any number of words: "file.save.*" gets
+// every save but of files without an extension, "file.#.html" everything
+// about HTML files, "#" every event.
+func (e *EventManager) subscribeTopic(pattern string, listener EventListener, filters ...Filter) (TopicSubscription, error) {
+	words, err := parsePattern(pattern)
+	if err != nil {
+		return TopicSubscription{}, err
+	}
+	s := &topicSubscription{words: words, listener: listener, filters: filters}
+	e.topics.add(s)
+	return TopicSubscription{&e.topics, s}, nil
+}
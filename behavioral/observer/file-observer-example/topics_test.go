@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEvent_Topic(t *testing.T) {
+	tests := []struct {
+		event    Event
+		expected string
+	}{
+		{Event{Type: SAVE, Filename: "index.html"}, "file.save.html"},
+		{Event{Type: RENAME, Filename: "dir.v2/Archive.TAR.GZ"}, "file.rename.gz"},
+		{Event{Type: CREATE, Filename: "Makefile"}, "file.create"},
+	}
+	for _, test := range tests {
+		if got := test.event.Topic(); got != test.expected {
+			t.Errorf("Expected topic %q for %v, got %q", test.expected, test.event, got)
+		}
+	}
+}
+
+func TestTopicTree_Match(t *testing.T) {
+	patterns := []string{
+		"file.save.html", "file.save.*", "file.*.html", "file.#", "#", "file.#.html",
+		"file.save", "file.save.#", "*.*", "file.#.save.#", "other.#",
+	}
+	var tree topicTree
+	for _, pattern := range patterns {
+		words, err := parsePattern(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.add(&topicSubscription{words: words})
+	}
+	matches := func(topic string) []string {
+		var got []string
+		for _, s := range tree.match(topic) {
+			got = append(got, patterns[s.id-1])
+		}
+		return got
+	}
+
+	tests := map[string][]string{
+		"file.save.html": {"file.save.html", "file.save.*", "file.*.html", "file.#", "#", "file.#.html", "file.save.#", "file.#.save.#"},
+		"file.save":      {"file.#", "#", "file.save", "file.save.#", "*.*", "file.#.save.#"},
+		"file.open.css":  {"file.#", "#"},
+		"other":          {"#", "other.#"},
+	}
+	for topic, expected := range tests {
+		if got := matches(topic); !slices.Equal(got, expected) {
+			t.Errorf("%s: expected %q, got %q", topic, expected, got)
+		}
+	}
+}
+
+func TestParsePattern(t *testing.T) {
+	for _, pattern := range []string{"", "file..save", "file.*html", "file.#x", "file."} {
+		if _, err := parsePattern(pattern); !errors.Is(err, ErrInvalidTopic) {
+			t.Errorf("%q: expected ErrInvalidTopic, got %v", pattern, err)
+		}
+	}
+}
+
+func TestEventManager_Topics(t *testing.T) {
+	e := newEventManager()
+	var calls []string
+	html := &recordingListener{name: "html", calls: &calls}
+	saves := &recordingListener{name: "saves", calls: &calls}
+	drafts := &recordingListener{name: "drafts", calls: &calls}
+	htmlSub, _ := e.subscribeTopic("file.#.html", html)
+	e.subscribeTopic("file.save.#", saves)
+	e.subscribeTopic("#", drafts, FilenameMatches("draft-*"))
+	if _, err := e.subscribeTopic("file.*html", html); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+
+	e.notify(Event{Type: SAVE, Filename: "site/index.html"})
+	e.notify(Event{Type: OPEN, Filename: "draft-1.txt"})
+	e.notify(Event{Type: SAVE, Filename: "README"})
+	htmlSub.Cancel()
+	htmlSub.Cancel()
+	e.notify(Event{Type: DELETE, Filename: "draft-2.html"})
+
+	expected := []string{"html:site/index.html", "saves:site/index.html", "drafts:draft-1.txt", "saves:README", "drafts:draft-2.html"}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, calls)
+	}
+	if _, ok := e.topics.root.children["file"].children["#"]; ok {
+		t.Error("Expected the nodes of the cancelled subscription to be removed")
+	}
+}
+
+func TestBetween(t *testing.T) {
+	at := func(hour int) Event { return Event{Timestamp: time.Date(2024, 5, 2, hour, 0, 0, 0, time.UTC)} }
+	from, to := at(10).Timestamp, at(12).Timestamp
+	for hour, expected := range map[int]bool{9: false, 10: true, 11: true, 12: false} {
+		if got := Between(from, to)(at(hour)); got != expected {
+			t.Errorf("%d:00: expected %v, got %v", hour, expected, got)
+		}
+	}
+	if !Between(time.Time{}, to)(at(0)) || !Between(from, time.Time{})(at(23)) {
+		t.Error("Expected a zero time to leave that side open")
+	}
+}
+
+// BenchmarkNotify_Topics notifies an event with 10000 subscriptions, of
+// which a handful match.
+func BenchmarkNotify_Topics(b *testing.B) {
+	e := newEventManager()
+	var calls []string
+	listener := &recordingListener{name: "l", calls: &calls}
+	for i := range 10000 {
+		e.subscribeTopic(fmt.Sprintf("file.%s.ext%d", []string{"save", "open", "*"}[i%3], i), listener)
+	}
+	e.subscribeTopic("file.#", listener)
+	event := Event{Type: SAVE, Filename: "page.ext42"}
+	b.ResetTimer()
+	for range b.N {
+		calls = calls[:0]
+		e.notify(event)
+	}
+}
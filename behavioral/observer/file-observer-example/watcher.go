@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+type WatcherConfig struct {
+	// Interval is the time between two polls in Run.
+	Interval time.Duration
+	// Debounce is how long a file must stay unchanged before its changes
+	// are published, so that a burst of writes is one event.
+	Debounce time.Duration
+	// Include and Exclude are path.Match patterns. A file is watched if
+	// it matches an Include pattern, or there are none, and no Exclude
+	// pattern. A pattern matches the base name or the slash-separated path
+	// below the watched directory. Excluded directories are not entered.
+	Include, Exclude []string
+	// Recursive also watches the subdirectories.
+	Recursive bool
+}
+
+var DefaultWatcher = WatcherConfig{Interval: time.Second, Debounce: 500 * time.Millisecond, Exclude: []string{".*"}}
+
+// Watcher polls directories and publishes CREATE, MODIFY, DELETE and RENAME
+// events. It only uses os.Stat information, so it works on every platform
+// and file system: a file changed when its size or modification time
+// changed, or when another file took its place. A file that disappears
+// while os.SameFile finds it under a new name was renamed.
+//
+// The events are published from the goroutine that calls Poll or Run.
+type Watcher struct {
+	dirs   []string
+	config WatcherConfig
+	events EventPublisher
+	now    func() time.Time
+
+	reported map[string]os.FileInfo // the state the listeners know about
+	scanned  map[string]os.FileInfo // the state of the last poll
+	dirty    map[string]time.Time   // files changed since reported, and when they changed last
+}
+
+// NewWatcher takes the files in dirs as they are now as the starting point:
+// only the changes after it are published.
+func NewWatcher(events EventPublisher, config WatcherConfig, dirs ...string) (*Watcher, error) {
+	w := &Watcher{dirs: dirs, config: config, events: events, now: time.Now, dirty: make(map[string]time.Time)}
+	files, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+	w.reported, w.scanned = files, files
+	return w, nil
+}
+
+// Run polls every Interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := w.Poll(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Poll scans the directories once, and publishes the changes of the files
+// that have been quiet for Debounce.
+func (w *Watcher) Poll() error {
+	files, err := w.scan()
+	if err != nil {
+		return err
+	}
+	now := w.now()
+	for path := range union(files, w.scanned) {
+		if changed(w.scanned[path], files[path]) {
+			w.dirty[path] = now
+		}
+	}
+	w.scanned = files
+
+	var due []string
+	for path, last := range w.dirty {
+		if now.Sub(last) >= w.config.Debounce {
+			due = append(due, path)
+			delete(w.dirty, path)
+		}
+	}
+	slices.Sort(due)
+	for _, event := range w.diff(due, now.UTC()) {
+		w.events.notify(event)
+	}
+	return nil
+}
+
+// diff turns the differences between reported and scanned for the due
+// paths into events, and makes them reported.
+func (w *Watcher) diff(due []string, now time.Time) []Event {
+	var created, deleted []string
+	var events []Event
+	gone := make(map[string]os.FileInfo)
+	for _, path := range due {
+		before, after := w.reported[path], w.scanned[path]
+		switch {
+		case before == nil && after == nil:
+			// created and deleted again within the debounce time
+		case before == nil:
+			created = append(created, path)
+		case after == nil:
+			deleted = append(deleted, path)
+			gone[path] = before
+		case changed(before, after):
+			events = append(events, Event{Type: MODIFY, Filename: path, Timestamp: now})
+		}
+		if after == nil {
+			delete(w.reported, path)
+		} else {
+			w.reported[path] = after
+		}
+	}
+
+	// a deleted file that turns up under a new name was renamed
+	for _, path := range created {
+		i := slices.IndexFunc(deleted, func(old string) bool { return os.SameFile(gone[old], w.scanned[path]) })
+		if i < 0 {
+			events = append(events, Event{Type: CREATE, Filename: path, Timestamp: now})
+			continue
+		}
+		events = append(events, Event{Type: RENAME, Filename: path, OldFilename: deleted[i], Timestamp: now})
+		deleted = slices.Delete(deleted, i, i+1)
+	}
+	for _, path := range deleted {
+		events = append(events, Event{Type: DELETE, Filename: path, Timestamp: now})
+	}
+	slices.SortStableFunc(events, func(a, b Event) int { return strings.Compare(a.Filename, b.Filename) })
+	return events
+}
+
+// changed reports whether a file was created, deleted, written or replaced
+// by another one.
+func changed(before, after os.FileInfo) bool {
+	if before == nil || after == nil {
+		return before != after
+	}
+	return before.Size() != after.Size() || !before.ModTime().Equal(after.ModTime()) || !os.SameFile(before, after)
+}
+
+func union[V any](a, b map[string]V) map[string]bool {
+	keys := make(map[string]bool, len(a))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
+
+// scan returns the watched files by path.
+func (w *Watcher) scan() (map[string]os.FileInfo, error) {
+	files := make(map[string]os.FileInfo)
+	for _, dir := range w.dirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if path != dir && errors.Is(err, fs.ErrNotExist) {
+					return nil // deleted while we walked
+				}
+				return err
+			}
+			if path == dir {
+				return nil
+			}
+			rel, _ := filepath.Rel(dir, path)
+			if entry.IsDir() {
+				if !w.config.Recursive || w.excluded(rel) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() || w.excluded(rel) || !w.included(rel) {
+				return nil
+			}
+			info, err := entry.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			files[path] = info
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (w *Watcher) included(rel string) bool {
+	return len(w.config.Include) == 0 || matchAny(w.config.Include, rel)
+}
+
+func (w *Watcher) excluded(rel string) bool {
+	return matchAny(w.config.Exclude, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// watched is a directory under a Watcher with a fake clock.
+type watched struct {
+	t       *testing.T
+	dir     string
+	now     time.Time
+	watcher *Watcher
+	got     []string
+}
+
+func (w *watched) update(event Event) {
+	w.got = append(w.got, strings.ReplaceAll(event.describe(), w.dir+string(filepath.Separator), ""))
+}
+
+func newWatched(t *testing.T, config WatcherConfig, setup func(w *watched)) *watched {
+	t.Helper()
+	w := &watched{t: t, dir: t.TempDir(), now: time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)}
+	if setup != nil {
+		setup(w)
+	}
+	events := &EventManager{listeners: make(map[EventType][]EventListener)}
+	for _, eventType := range []EventType{CREATE, MODIFY, DELETE, RENAME} {
+		events.subscribe(eventType, w)
+	}
+	watcher, err := NewWatcher(events, config, w.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher.now = func() time.Time { return w.now }
+	w.watcher = watcher
+	return w
+}
+
+// write gives the file its content and a modification time of its own, as
+// not every file system has a fine-grained clock.
+func (w *watched) write(name, content string) {
+	w.t.Helper()
+	path := filepath.Join(w.dir, name)
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		w.t.Fatal(err)
+	}
+	w.now = w.now.Add(time.Second)
+	os.Chtimes(path, w.now, w.now)
+}
+
+func (w *watched) poll(after time.Duration) []string {
+	w.t.Helper()
+	w.now = w.now.Add(after)
+	w.got = nil
+	if err := w.watcher.Poll(); err != nil {
+		w.t.Fatal(err)
+	}
+	return w.got
+}
+
+func expectEvents(t *testing.T, got []string, expected ...string) {
+	t.Helper()
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestWatcher_Changes(t *testing.T) {
+	w := newWatched(t, WatcherConfig{}, func(w *watched) {
+		w.write("a.txt", "a")
+		w.write("b.txt", "b")
+		w.write("c.txt", "c")
+	})
+	expectEvents(t, w.poll(0)) // the files that were there are not new
+
+	w.write("new.txt", "new")
+	w.write("a.txt", "changed")
+	os.Remove(filepath.Join(w.dir, "b.txt"))
+	os.Rename(filepath.Join(w.dir, "c.txt"), filepath.Join(w.dir, "d.txt"))
+	expectEvents(t, w.poll(0),
+		"modify file a.txt", "delete file b.txt", "rename file c.txt -> d.txt", "create file new.txt")
+	expectEvents(t, w.poll(time.Second))
+}
+
+func TestWatcher_SameSizeAndTime(t *testing.T) {
+	w := newWatched(t, WatcherConfig{}, func(w *watched) { w.write("a.txt", "aaa") })
+	mtime := w.now
+
+	// replaced by another file of the same size and time, as by an atomic save
+	tmp := filepath.Join(w.dir, "tmp")
+	os.WriteFile(tmp, []byte("bbb"), 0o644)
+	os.Chtimes(tmp, mtime, mtime)
+	os.Rename(tmp, filepath.Join(w.dir, "a.txt"))
+	expectEvents(t, w.poll(0), "modify file a.txt")
+}
+
+func TestWatcher_Debounce(t *testing.T) {
+	w := newWatched(t, WatcherConfig{Debounce: 500 * time.Millisecond}, nil)
+
+	w.write("a.txt", "1")
+	expectEvents(t, w.poll(0))
+	w.write("a.txt", "12")
+	expectEvents(t, w.poll(400*time.Millisecond)) // still changing
+	expectEvents(t, w.poll(400*time.Millisecond)) // quiet for less than 500ms
+	expectEvents(t, w.poll(400*time.Millisecond), "create file a.txt")
+
+	// created and deleted within the debounce time
+	w.write("tmp.txt", "x")
+	w.poll(0)
+	os.Remove(filepath.Join(w.dir, "tmp.txt"))
+	expectEvents(t, w.poll(0))
+	expectEvents(t, w.poll(time.Second))
+}
+
+func TestWatcher_Patterns(t *testing.T) {
+	config := WatcherConfig{Include: []string{"*.go", "docs/*"}, Exclude: []string{"*_test.go", ".*"}, Recursive: true}
+	w := newWatched(t, config, nil)
+
+	for _, name := range []string{"main.go", "main_test.go", "README.md", "docs/guide.md", "pkg/util.go", ".git/config.go", "docs/deep/x.md"} {
+		w.write(name, name)
+	}
+	expectEvents(t, w.poll(0),
+		"create file docs/guide.md", "create file main.go", "create file pkg/util.go")
+}
+
+func TestWatcher_NotRecursive(t *testing.T) {
+	w := newWatched(t, WatcherConfig{}, nil)
+	w.write("top.txt", "top")
+	w.write("sub/nested.txt", "nested")
+	expectEvents(t, w.poll(0), "create file top.txt")
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	mathrand "math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrCircuitOpen = errors.New("circuit open")
+	// ErrPermanent wraps the failures that retrying cannot fix, such as a
+	// 400 Bad Request.
+	ErrPermanent = errors.New("permanent failure")
+	// ErrUnknownEndpoint is returned by Replay for the dead letters of an
+	// endpoint that is not in the configuration.
+	ErrUnknownEndpoint = errors.New("endpoint is not configured")
+)
+
+// The headers of a webhook request
+const (
+	DeliveryHeader  = "X-Delivery-Id"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+	TopicHeader     = "X-Event-Topic"
+)
+
+// Endpoint is a URL the events are posted to. The requests are signed with
+// the secret, if there is one.
+type Endpoint struct {
+	URL    string
+	Secret []byte
+}
+
+type WebhookConfig struct {
+	Endpoints []Endpoint
+	// MaxAttempts is the number of requests made for an event before it
+	// goes to the dead letters.
+	MaxAttempts int
+	// The delay before retry n is a random duration up to
+	// min(MaxDelay, BaseDelay * 2^(n-1)).
+	BaseDelay, MaxDelay time.Duration
+	Timeout             time.Duration // of one request
+	// After BreakerThreshold failed requests in a row, an endpoint gets no
+	// requests for BreakerCooldown. Then a single request tries it again.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// DeadLetters is the file the events that could not be delivered are
+	// appended to, one JSON object per line.
+	DeadLetters string
+}
+
+var DefaultWebhook = WebhookConfig{
+	MaxAttempts:      5,
+	BaseDelay:        500 * time.Millisecond,
+	MaxDelay:         30 * time.Second,
+	Timeout:          10 * time.Second,
+	BreakerThreshold: 5,
+	BreakerCooldown:  time.Minute,
+	DeadLetters:      "dead-letters.jsonl",
+}
+
+// DeadLetter is an event that could not be delivered to an endpoint.
+type DeadLetter struct {
+	Endpoint string    `json:"endpoint"`
+	Delivery string    `json:"delivery"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// WebhookListener posts the events as JSON to every endpoint. It waits for
+// the retries, so it belongs behind an AsyncEventManager, where it only
+// delays itself.
+type WebhookListener struct {
+	config WebhookConfig
+	client *http.Client
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+
+	mu       sync.Mutex
+	breakers map[string]*breaker
+	deadMu   sync.Mutex // serializes the writes to the dead letters
+}
+
+func NewWebhookListener(config WebhookConfig) *WebhookListener {
+	return &WebhookListener{
+		config:   config,
+		client:   &http.Client{Timeout: config.Timeout},
+		now:      time.Now,
+		sleep:    sleep,
+		breakers: make(map[string]*breaker),
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (w *WebhookListener) update(event Event) {
+	if err := w.Deliver(context.Background(), event); err != nil {
+		log.Println("webhook:", err)
+	}
+}
+
+// Deliver posts the event to every endpoint. The events that fail go to the
+// dead letters; the error says which endpoints failed.
+func (w *WebhookListener) Deliver(ctx context.Context, event Event) error {
+	delivery, err := newDeliveryID()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, endpoint := range w.config.Endpoints {
+		if err := w.deliver(ctx, endpoint, delivery, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w *WebhookListener) deliver(ctx context.Context, endpoint Endpoint, delivery string, event Event) error {
+	attempts, err := w.send(ctx, endpoint, delivery, event)
+	if err == nil {
+		return nil
+	}
+	letter := DeadLetter{
+		Endpoint: endpoint.URL,
+		Delivery: delivery,
+		Event:    event,
+		Attempts: attempts,
+		Error:    err.Error(),
+		FailedAt: w.now().UTC(),
+	}
+	if dlErr := w.deadLetter(letter); dlErr != nil {
+		return fmt.Errorf("%s: %w, and it was lost: %v", endpoint.URL, err, dlErr)
+	}
+	return fmt.Errorf("%s: %w", endpoint.URL, err)
+}
+
+// send makes up to MaxAttempts requests, and returns how many it made.
+func (w *WebhookListener) send(ctx context.Context, endpoint Endpoint, delivery string, event Event) (int, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	b := w.breaker(endpoint.URL)
+	attempts := 0
+	for {
+		if !b.allow(w.now()) {
+			return attempts, ErrCircuitOpen
+		}
+		attempts++
+		err = w.post(ctx, endpoint, delivery, event.Topic(), body)
+		// the endpoint answered a permanent failure, so it works
+		b.record(err == nil || errors.Is(err, ErrPermanent), w.now())
+		if err == nil || errors.Is(err, ErrPermanent) || attempts >= max(w.config.MaxAttempts, 1) {
+			return attempts, err
+		}
+		if err := w.sleep(ctx, w.backoff(attempts)); err != nil {
+			return attempts, err
+		}
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts, with
+// full jitter so that the retries of many events do not arrive together.
+func (w *WebhookListener) backoff(failed int) time.Duration {
+	ceiling := w.config.BaseDelay << min(failed-1, 30)
+	if ceiling <= 0 || ceiling > w.config.MaxDelay {
+		ceiling = w.config.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return mathrand.N(ceiling + 1)
+}
+
+func (w *WebhookListener) post(ctx context.Context, endpoint Endpoint, delivery, topic string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPermanent, err)
+	}
+	timestamp := strconv.FormatInt(w.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, delivery)
+	req.Header.Set(TopicHeader, topic)
+	req.Header.Set(TimestampHeader, timestamp)
+	if endpoint.Secret != nil {
+		req.Header.Set(SignatureHeader, Sign(endpoint.Secret, timestamp, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16)) // so that the connection is reused
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return errors.New(resp.Status)
+	default:
+		return fmt.Errorf("%w: %s", ErrPermanent, resp.Status)
+	}
+}
+
+// Sign returns the signature of a request: the hex HMAC-SHA256 of the
+// timestamp, a dot and the body. The timestamp lets the receiver reject
+// old requests replayed by someone else.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks the signature of a request, for the receivers.
+func VerifySignature(secret []byte, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
+
+// newDeliveryID returns a random ID. Receivers drop the deliveries whose ID
+// they have seen, so an error must not give a predictable one.
+func newDeliveryID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("delivery ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func (w *WebhookListener) deadLetter(letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	w.deadMu.Lock()
+	defer w.deadMu.Unlock()
+	f, err := os.OpenFile(w.config.DeadLetters, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Replay tries to deliver the dead letters again, each to the endpoint it
+// failed for, with the same delivery ID so that receivers can tell a
+// replay from a new event. The letters that fail again go back to the dead
+// letters. The file is set aside while it is replayed; if a replay is
+// interrupted, the next one resumes it. A letter for an endpoint that is no
+// longer configured is kept, and Replay returns ErrUnknownEndpoint.
+func (w *WebhookListener) Replay(ctx context.Context) (delivered, failed int, err error) {
+	replaying := w.config.DeadLetters + ".replaying"
+	if _, err := os.Stat(replaying); errors.Is(err, fs.ErrNotExist) {
+		w.deadMu.Lock()
+		err = os.Rename(w.config.DeadLetters, replaying)
+		w.deadMu.Unlock()
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, 0, nil // nothing to replay
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	letters, err := readDeadLetters(replaying)
+	if err != nil {
+		return 0, 0, err
+	}
+	endpoints := make(map[string]Endpoint)
+	for _, e := range w.config.Endpoints {
+		endpoints[e.URL] = e
+	}
+	var unknown []string
+	for _, letter := range letters {
+		if ctx.Err() != nil {
+			// keep the rest for the next replay
+			letter.Error = ctx.Err().Error()
+			if err := w.deadLetter(letter); err != nil {
+				return delivered, failed, err
+			}
+			failed++
+			continue
+		}
+		endpoint, ok := endpoints[letter.Endpoint]
+		if !ok {
+			// without its configuration the letter would go out unsigned
+			letter.Error = fmt.Sprintf("%v: %s", ErrUnknownEndpoint, letter.Endpoint)
+			if err := w.deadLetter(letter); err != nil {
+				return delivered, failed, err
+			}
+			if !slices.Contains(unknown, letter.Endpoint) {
+				unknown = append(unknown, letter.Endpoint)
+			}
+			failed++
+			continue
+		}
+		if err := w.deliver(ctx, endpoint, letter.Delivery, letter.Event); err != nil {
+			failed++
+		} else {
+			delivered++
+		}
+	}
+	if err := os.Remove(replaying); err != nil {
+		return delivered, failed, err
+	}
+	if len(unknown) > 0 {
+		return delivered, failed, fmt.Errorf("%w: %s", ErrUnknownEndpoint, strings.Join(unknown, ", "))
+	}
+	return delivered, failed, nil
+}
+
+func readDeadLetters(path string) ([]DeadLetter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, scanner.Err()
+}
+
+func (w *WebhookListener) breaker(url string) *breaker {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	b, ok := w.breakers[url]
+	if !ok {
+		b = &breaker{threshold: w.config.BreakerThreshold, cooldown: w.config.BreakerCooldown}
+		w.breakers[url] = b
+	}
+	return b
+}
+
+// breaker is the circuit breaker of an endpoint. It is closed while the
+// endpoint works, opens after threshold failures in a row, and half-opens
+// after the cooldown to let one request find out whether the endpoint is
+// back.
+type breaker struct {
+	threshold int // 0 never opens
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool // a request is trying the half-open circuit
+}
+
+func (b *breaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *breaker) record(ok bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if ok {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is an endpoint that answers with the statuses it is given, one
+// per request, and then with 200.
+type receiver struct {
+	*httptest.Server
+	secret []byte
+
+	mu       sync.Mutex
+	statuses []int
+	received []Event
+	requests int
+}
+
+func newReceiver(t *testing.T, secret string, statuses ...int) *receiver {
+	r := &receiver{secret: []byte(secret), statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		if !VerifySignature(r.secret, req.Header.Get(TimestampHeader), body, req.Header.Get(SignatureHeader)) {
+			t.Errorf("Bad signature %q", req.Header.Get(SignatureHeader))
+		}
+		if len(r.statuses) > 0 {
+			status := r.statuses[0]
+			r.statuses = r.statuses[1:]
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+		}
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Error(err)
+		}
+		if req.Header.Get(TopicHeader) != event.Topic() || req.Header.Get(DeliveryHeader) == "" {
+			t.Errorf("Unexpected headers %v", req.Header)
+		}
+		r.received = append(r.received, event)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) counts() (requests, received int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests, len(r.received)
+}
+
+// newTestWebhook does not sleep, but records the delays.
+func newTestWebhook(t *testing.T, config WebhookConfig, endpoints ...*receiver) (*WebhookListener, *[]time.Duration) {
+	for _, r := range endpoints {
+		config.Endpoints = append(config.Endpoints, Endpoint{URL: r.URL, Secret: r.secret})
+	}
+	config.DeadLetters = filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	w := NewWebhookListener(config)
+	var delays []time.Duration
+	w.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+	return w, &delays
+}
+
+func deadLetters(t *testing.T, w *WebhookListener) []DeadLetter {
+	t.Helper()
+	letters, err := readDeadLetters(w.config.DeadLetters)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	return letters
+}
+
+var saved = Event{Type: SAVE, Filename: "index.html", Timestamp: time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)}
+
+func TestWebhook_Deliver(t *testing.T) {
+	a, b := newReceiver(t, "secret a"), newReceiver(t, "secret b")
+	w, _ := newTestWebhook(t, WebhookConfig{MaxAttempts: 3}, a, b)
+
+	if err := w.Deliver(context.Background(), saved); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*receiver{a, b} {
+		if len(r.received) != 1 || r.received[0] != saved {
+			t.Errorf("Expected %v, got %v", saved, r.received)
+		}
+	}
+}
+
+func TestWebhook_Retries(t *testing.T) {
+	r := newReceiver(t, "secret", http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusBadGateway)
+	config := WebhookConfig{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 250 * time.Millisecond}
+	w, delays := newTestWebhook(t, config, r)
+
+	if err := w.Deliver(context.Background(), saved); err != nil {
+		t.Fatal(err)
+	}
+	if requests, received := r.counts(); requests != 4 || received != 1 {
+		t.Errorf("Expected 4 requests and 1 event, got %d and %d", requests, received)
+	}
+	// full jitter up to 100ms, 200ms, then the 250ms cap
+	for i, ceiling := range []time.Duration{100, 200, 250} {
+		if d := (*delays)[i]; d < 0 || d > ceiling*time.Millisecond {
+			t.Errorf("Retry %d: expected a delay up to %dms, got %v", i+1, ceiling, d)
+		}
+	}
+	if len(deadLetters(t, w)) != 0 {
+		t.Error("Expected no dead letters")
+	}
+}
+
+func TestWebhook_DeadLetters(t *testing.T) {
+	failing := newReceiver(t, "secret", 500, 500, 500)
+	rejecting := newReceiver(t, "secret", http.StatusBadRequest)
+	w, _ := newTestWebhook(t, WebhookConfig{MaxAttempts: 3}, failing, rejecting)
+
+	err := w.Deliver(context.Background(), saved)
+	if !errors.Is(err, ErrPermanent) {
+		t.Errorf("Expected the rejection in the error, got %v", err)
+	}
+	if requests, _ := rejecting.counts(); requests != 1 {
+		t.Errorf("Expected a permanent failure not to be retried, got %d requests", requests)
+	}
+
+	letters := deadLetters(t, w)
+	if len(letters) != 2 {
+		t.Fatalf("Expected 2 dead letters, got %v", letters)
+	}
+	if letters[0].Endpoint != failing.URL || letters[0].Attempts != 3 || letters[0].Event != saved || letters[0].Error != "500 Internal Server Error" {
+		t.Errorf("Unexpected dead letter %+v", letters[0])
+	}
+	if letters[1].Endpoint != rejecting.URL || letters[1].Attempts != 1 {
+		t.Errorf("Unexpected dead letter %+v", letters[1])
+	}
+	if letters[0].Delivery == "" || letters[0].Delivery != letters[1].Delivery {
+		t.Error("Expected both endpoints to get the same delivery ID")
+	}
+}
+
+func TestWebhook_CircuitBreaker(t *testing.T) {
+	r := newReceiver(t, "", 500, 500, 500, 500)
+	config := WebhookConfig{MaxAttempts: 1, BreakerThreshold: 3, BreakerCooldown: time.Minute}
+	w, _ := newTestWebhook(t, config, r)
+	now := time.Date(2024, 5, 2, 14, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+
+	for range 5 {
+		w.Deliver(context.Background(), saved)
+	}
+	if requests, _ := r.counts(); requests != 3 {
+		t.Errorf("Expected the circuit to open after 3 failures, got %d requests", requests)
+	}
+	letters := deadLetters(t, w)
+	if len(letters) != 5 || letters[4].Error != ErrCircuitOpen.Error() || letters[4].Attempts != 0 {
+		t.Errorf("Expected every event in the dead letters, got %+v", letters)
+	}
+
+	// half open after the cooldown: one failed trial opens it again
+	now = now.Add(time.Minute)
+	w.Deliver(context.Background(), saved)
+	w.Deliver(context.Background(), saved)
+	if requests, _ := r.counts(); requests != 4 {
+		t.Errorf("Expected a single trial request, got %d requests", requests-3)
+	}
+
+	// the next trial succeeds and closes it
+	now = now.Add(time.Minute)
+	for range 2 {
+		if err := w.Deliver(context.Background(), saved); err != nil {
+			t.Errorf("Expected the circuit to be closed, got %v", err)
+		}
+	}
+}
+
+func TestWebhook_Replay(t *testing.T) {
+	r := newReceiver(t, "secret", 500, 500, 500, 500, http.StatusServiceUnavailable)
+	w, _ := newTestWebhook(t, WebhookConfig{MaxAttempts: 2}, r)
+	deleted := Event{Type: DELETE, Filename: "old.html", Timestamp: saved.Timestamp}
+	w.Deliver(context.Background(), saved)
+	w.Deliver(context.Background(), deleted)
+	if len(deadLetters(t, w)) != 2 {
+		t.Fatal("Expected 2 dead letters")
+	}
+	delivery := deadLetters(t, w)[1].Delivery
+
+	// the receiver is back, but fails once more: saved is delivered, deleted
+	// fails twice and goes back to the dead letters
+	r.mu.Lock()
+	r.statuses = []int{200, 500, 500}
+	r.mu.Unlock()
+	delivered, failed, err := w.Replay(context.Background())
+	if err != nil || delivered != 1 || failed != 1 {
+		t.Fatalf("Expected 1 delivered and 1 failed, got %d, %d, %v", delivered, failed, err)
+	}
+	if len(r.received) != 1 || r.received[0] != saved {
+		t.Errorf("Expected the saved event to be replayed, got %v", r.received)
+	}
+	letters := deadLetters(t, w)
+	if len(letters) != 1 || letters[0].Event != deleted || letters[0].Delivery != delivery {
+		t.Errorf("Expected the deleted event to stay a dead letter, got %+v", letters)
+	}
+	if _, err := os.Stat(w.config.DeadLetters + ".replaying"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("Expected the replayed file to be removed")
+	}
+
+	delivered, failed, err = w.Replay(context.Background())
+	if err != nil || delivered != 1 || failed != 0 {
+		t.Errorf("Expected the last dead letter to be delivered, got %d, %d, %v", delivered, failed, err)
+	}
+	if delivered, failed, err := w.Replay(context.Background()); delivered+failed != 0 || err != nil {
+		t.Errorf("Expected nothing to replay, got %d, %d, %v", delivered, failed, err)
+	}
+}
+
+func TestWebhook_ReplayUnknownEndpoint(t *testing.T) {
+	r := newReceiver(t, "secret", 500)
+	w, _ := newTestWebhook(t, WebhookConfig{MaxAttempts: 1}, r)
+	w.Deliver(context.Background(), saved)
+
+	// the same dead letters, but the endpoint is no longer configured
+	config := w.config
+	config.Endpoints = nil
+	unconfigured := NewWebhookListener(config)
+	delivered, failed, err := unconfigured.Replay(context.Background())
+	if !errors.Is(err, ErrUnknownEndpoint) || delivered != 0 || failed != 1 {
+		t.Fatalf("Expected ErrUnknownEndpoint and 1 failed, got %d, %d, %v", delivered, failed, err)
+	}
+	if requests, _ := r.counts(); requests != 1 {
+		t.Errorf("Expected no unsigned request, got %d requests", requests)
+	}
+	letters := deadLetters(t, w)
+	if len(letters) != 1 || letters[0].Endpoint != r.URL || !strings.Contains(letters[0].Error, ErrUnknownEndpoint.Error()) {
+		t.Errorf("Expected the letter to be kept, got %+v", letters)
+	}
+
+	if delivered, _, err := w.Replay(context.Background()); err != nil || delivered != 1 {
+		t.Errorf("Expected the letter to be delivered with the endpoint configured, got %d, %v", delivered, err)
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"type":"save"}`)
+	signature := Sign([]byte("secret"), "1714658400", body)
+	if !VerifySignature([]byte("secret"), "1714658400", body, signature) {
+		t.Error("Expected the signature to verify")
+	}
+	for _, bad := range []struct{ secret, timestamp, body string }{
+		{"other", "1714658400", string(body)},
+		{"secret", "1714658401", string(body)},
+		{"secret", "1714658400", `{"type":"open"}`},
+	} {
+		if VerifySignature([]byte(bad.secret), bad.timestamp, []byte(bad.body), signature) {
+			t.Errorf("Expected %+v not to verify", bad)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module observer-property
+
+go 1.23.6
@@ -1,88 +1,79 @@
 package main
 
-import (
-	"container/list"
-	"fmt"
-)
+import "fmt"
 
-type Observable struct {
-	subscriptions *list.List
-}
-
-func (o *Observable) Subscribe(x Observer) {
-	o.subscriptions.PushBack(x)
-}
-
-func (o *Observable) Unsubscribe(x Observer) {
-	for z := o.subscriptions.Front(); z != nil; z = z.Next() {
-		// if z.Value == x {
-		if z.Value.(Observer) == x {
-			o.subscriptions.Remove(z)
-		}
-	}
-}
-
-func (o *Observable) Fire(data interface{}) {
-	for z := o.subscriptions.Front(); z != nil; z = z.Next() {
-		z.Value.(Observer).Notify(data)
-	}
-}
-
-type Observer interface {
-	Notify(data interface{})
-}
-
-type PropertyChange struct {
-	Name  string
-	Value interface{}
+// PropertyChange is the event of an observable property of type T.
+type PropertyChange[T any] struct {
+	Name     string
+	Old, New T
 }
 
+// Person declares the facts derived from its age once, as computed
+// properties, instead of leaving every observer to work them out.
 type Person struct {
-	Observable
-	name string
-	age  int
+	scope    Scope
+	Name     *Property[string]
+	Age      *Property[int]
+	CanDrive *Computed[bool]
+	CanVote  *Computed[bool]
+	Status   *Computed[string]
 }
 
-func (p *Person) Age() int {
-	return p.age
+func NewPerson(name string, age int) *Person {
+	p := &Person{}
+	p.Name = NewProperty(&p.scope, "Name", name)
+	p.Age = NewProperty(&p.scope, "Age", age)
+	p.CanDrive = NewComputed(&p.scope, "CanDrive", func() bool { return p.Age.Get() >= 18 })
+	p.CanVote = NewComputed(&p.scope, "CanVote", func() bool { return p.Age.Get() >= 18 })
+	p.Status = NewComputed(&p.scope, "Status", func() string {
+		if p.CanDrive.Get() && p.CanVote.Get() {
+			return p.Name.Get() + " is an adult"
+		}
+		return p.Name.Get() + " is a minor"
+	})
+	return p
 }
 
 func (p *Person) SetAge(age int) {
-	if age == p.age {
-		return
-	}
-	p.age = age
-	p.Fire(PropertyChange{"Age", p.age})
+	p.Age.Set(age)
 }
 
-func NewPerson(name string, age int) *Person {
-	return &Person{
-		Observable: Observable{new(list.List)},
-		name:       name,
-		age:        age,
-	}
+// Update changes several properties with a single round of notifications.
+func (p *Person) Update(f func(p *Person)) {
+	p.scope.Batch(func() { f(p) })
 }
 
 type TrafficManagement struct {
-	o Observable
+	subscription Subscription
 }
 
-func (t *TrafficManagement) Notify(data interface{}) {
-	if pc, ok := data.(PropertyChange); ok {
-		if pc.Value.(int) >= 18 {
-			fmt.Println("Congrats, you can drive now!")
-			t.o.Unsubscribe(t)
-		}
+func (t *TrafficManagement) Notify(pc PropertyChange[bool]) {
+	if pc.New {
+		fmt.Println("Congrats, you can drive now!")
+		t.subscription.Cancel()
 	}
 }
 
 func main() {
 	p := NewPerson("Bruce", 15)
-	t := &TrafficManagement{p.Observable}
-	p.Subscribe(t)
+	t := &TrafficManagement{}
+	t.subscription = p.CanDrive.Changes.Subscribe(t)
+	p.Age.Changes.SubscribeFunc(func(pc PropertyChange[int]) {
+		fmt.Printf("%s changed from %d to %d\n", pc.Name, pc.Old, pc.New)
+	})
+	p.Status.Changes.SubscribeFunc(func(pc PropertyChange[string]) {
+		fmt.Printf("%s: %q -> %q\n", pc.Name, pc.Old, pc.New)
+	})
 
 	for i := 15; i <= 20; i++ {
 		fmt.Println("Setting the age to", i)
 		p.SetAge(i)
 	}
+
+	fmt.Println("Correcting the records in one batch")
+	p.Update(func(p *Person) {
+		p.Name.Set("Bruce Wayne")
+		p.SetAge(17)
+		p.SetAge(19)
+	})
 }
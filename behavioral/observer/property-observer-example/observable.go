@@ -0,0 +1,86 @@
+package main
+
+import "container/list"
+
+// Observer receives the events of an Observable[T]. A wrong payload type is
+// a compile error, not a failed type assertion.
+type Observer[T any] interface {
+	Notify(data T)
+}
+
+// ObserverFunc lets a plain func(T) be an observer.
+type ObserverFunc[T any] func(data T)
+
+func (f ObserverFunc[T]) Notify(data T) { f(data) }
+
+// Subscription is the handle Subscribe returns. Observers are removed by
+// their handle rather than compared, so that any observer can be removed,
+// funcs included.
+type Subscription struct {
+	subscriptions *list.List
+	element       *list.Element
+}
+
+// Cancel removes the observer. Cancelling twice, or a zero Subscription,
+// does nothing.
+func (s Subscription) Cancel() {
+	if s.subscriptions != nil {
+		s.subscriptions.Remove(s.element)
+	}
+}
+
+// Observable is ready to use as its zero value. It is not safe for
+// concurrent use.
+//
+// Observers may subscribe, cancel and fire while they are notified:
+//   - Fire notifies the observers subscribed when it started. Observers
+//     that subscribe or cancel meanwhile are added or removed after the
+//     current event, so every observer of that event gets it exactly once.
+//   - A Fire from an observer is queued and runs after the current event,
+//     so that every observer sees the events in the same order.
+type Observable[T any] struct {
+	subscriptions *list.List
+	firing        bool
+	queued        []T
+}
+
+func (o *Observable[T]) Subscribe(x Observer[T]) Subscription {
+	if o.subscriptions == nil {
+		o.subscriptions = list.New()
+	}
+	return Subscription{o.subscriptions, o.subscriptions.PushBack(x)}
+}
+
+func (o *Observable[T]) SubscribeFunc(f func(data T)) Subscription {
+	return o.Subscribe(ObserverFunc[T](f))
+}
+
+// Fire notifies the observers in the order they subscribed.
+func (o *Observable[T]) Fire(data T) {
+	if o.subscriptions == nil {
+		return
+	}
+	if o.firing {
+		o.queued = append(o.queued, data)
+		return
+	}
+	o.firing = true
+	defer func() {
+		o.firing = false
+		o.queued = nil // lost if an observer panicked
+	}()
+	for {
+		observers := make([]Observer[T], 0, o.subscriptions.Len())
+		for z := o.subscriptions.Front(); z != nil; z = z.Next() {
+			observers = append(observers, z.Value.(Observer[T]))
+		}
+		for _, x := range observers {
+			x.Notify(data)
+		}
+		if len(o.queued) == 0 {
+			return
+		}
+		data = o.queued[0]
+		o.queued = o.queued[1:]
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Scope connects the properties of an object. It records which properties
+// every computed property reads while it computes, and holds back the
+// notifications during a Batch. A scope is not safe for concurrent use.
+type Scope struct {
+	tracking map[*node]bool // the reads of the computation in progress
+	batch    int
+	flushing bool
+	changed  []*node // properties set since the last round of notifications
+	touched  []*node // computed properties whose dependencies changed
+}
+
+// Batch runs f, and sends the notifications of all its changes at the end,
+// in a single round. A property set to several values in f notifies one
+// change from its value before f to its last value, or none if they are
+// equal.
+func (s *Scope) Batch(f func()) {
+	s.batch++
+	defer func() {
+		s.batch--
+		s.flush()
+	}()
+	f()
+}
+
+func (s *Scope) read(n *node) {
+	if s.tracking != nil {
+		s.tracking[n] = true
+	}
+}
+
+// flush sends the notifications: first those of the properties that were
+// set, in that order, then those of the computed properties, dependencies
+// first. Observers see every value already up to date. Their own changes
+// are notified in a further round.
+func (s *Scope) flush() {
+	if s.batch > 0 || s.flushing {
+		return
+	}
+	s.flushing = true
+	defer func() { s.flushing = false }()
+	for len(s.changed) > 0 || len(s.touched) > 0 {
+		changed, touched := s.changed, s.touched
+		s.changed, s.touched = nil, nil
+
+		var notify []func()
+		for _, n := range changed {
+			if f := n.value.commit(); f != nil {
+				notify = append(notify, f)
+			}
+		}
+		slices.SortStableFunc(touched, func(a, b *node) int { return a.level - b.level })
+		for _, n := range touched {
+			n.touched = false
+			if f := n.value.commit(); f != nil {
+				notify = append(notify, f)
+			}
+		}
+		for _, f := range notify {
+			f()
+		}
+	}
+}
+
+// node is the part of Property and Computed that does not depend on the
+// type of the value.
+type node struct {
+	scope      *Scope
+	name       string
+	value      interface{ commit() func() }
+	level      int // 0 for a property, else 1 + the highest level it reads
+	dependents map[*node]bool
+	// computed properties only
+	dependencies map[*node]bool
+	dirty        bool // a dependency changed since it was computed
+	touched      bool // in scope.touched
+	computing    bool
+}
+
+func (n *node) Name() string { return n.name }
+
+// invalidate marks everything computed from n as dirty.
+func (n *node) invalidate() {
+	for d := range n.dependents {
+		if !d.dirty {
+			d.dirty = true
+			if !d.touched {
+				d.touched = true
+				n.scope.touched = append(n.scope.touched, d)
+			}
+			d.invalidate()
+		}
+	}
+}
+
+// Property is a value that notifies its changes.
+type Property[T comparable] struct {
+	node
+	current T
+	before  T    // the value the observers know about
+	pending bool // in scope.changed
+	Changes Observable[PropertyChange[T]]
+}
+
+func NewProperty[T comparable](scope *Scope, name string, value T) *Property[T] {
+	p := &Property[T]{current: value, before: value}
+	p.node = node{scope: scope, name: name, value: p, dependents: make(map[*node]bool)}
+	return p
+}
+
+func (p *Property[T]) Get() T {
+	p.scope.read(&p.node)
+	return p.current
+}
+
+// Set notifies the change at once, or at the end of the batch.
+func (p *Property[T]) Set(value T) {
+	if value == p.current {
+		return
+	}
+	p.current = value
+	if !p.pending {
+		p.pending = true
+		p.scope.changed = append(p.scope.changed, &p.node)
+	}
+	p.invalidate()
+	p.scope.flush()
+}
+
+func (p *Property[T]) commit() func() {
+	p.pending = false
+	change := PropertyChange[T]{p.name, p.before, p.current}
+	p.before = p.current
+	if change.Old == change.New {
+		return nil
+	}
+	return func() { p.Changes.Fire(change) }
+}
+
+// Computed is a property computed from other properties, computed or not.
+// It finds out which ones by recording what compute reads, every time it
+// runs, so that a condition in compute may change its dependencies. Its
+// observers are only notified when its value changes.
+type Computed[T comparable] struct {
+	node
+	compute  func() T
+	current  T
+	notified T
+	Changes  Observable[PropertyChange[T]]
+}
+
+func NewComputed[T comparable](scope *Scope, name string, compute func() T) *Computed[T] {
+	c := &Computed[T]{compute: compute}
+	c.node = node{scope: scope, name: name, value: c, dependents: make(map[*node]bool)}
+	c.recompute()
+	c.notified = c.current
+	return c
+}
+
+// Get recomputes the value if a dependency changed, even in a batch.
+func (c *Computed[T]) Get() T {
+	if c.computing {
+		panic(fmt.Sprintf("computed property %s depends on itself", c.name))
+	}
+	c.scope.read(&c.node)
+	if c.dirty {
+		c.recompute()
+	}
+	return c.current
+}
+
+func (c *Computed[T]) recompute() {
+	for d := range c.dependencies {
+		delete(d.dependents, &c.node)
+	}
+	outer := c.scope.tracking
+	c.scope.tracking = make(map[*node]bool)
+	c.computing = true
+	defer func() {
+		c.computing = false
+		c.dependencies = c.scope.tracking
+		c.scope.tracking = outer
+		c.level = 1
+		for d := range c.dependencies {
+			d.dependents[&c.node] = true
+			c.level = max(c.level, d.level+1)
+		}
+		c.dirty = false
+	}()
+	c.current = c.compute()
+}
+
+func (c *Computed[T]) commit() func() {
+	if c.dirty {
+		c.recompute()
+	}
+	change := PropertyChange[T]{c.name, c.notified, c.current}
+	c.notified = c.current
+	if change.Old == change.New {
+		return nil
+	}
+	return func() { c.Changes.Fire(change) }
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// changes records the notifications of properties, in order.
+type changes []string
+
+func watch[T comparable](c *changes, o *Observable[PropertyChange[T]]) {
+	o.SubscribeFunc(func(pc PropertyChange[T]) {
+		*c = append(*c, fmt.Sprintf("%s:%v->%v", pc.Name, pc.Old, pc.New))
+	})
+}
+
+func expectChanges(t *testing.T, got *changes, expected ...string) {
+	t.Helper()
+	if !slices.Equal(*got, expected) {
+		t.Errorf("Expected %q, got %q", expected, *got)
+	}
+	*got = nil
+}
+
+func TestComputed_OnlyOnChange(t *testing.T) {
+	var scope Scope
+	age := NewProperty(&scope, "Age", 15)
+	computations := 0
+	adult := NewComputed(&scope, "Adult", func() bool {
+		computations++
+		return age.Get() >= 18
+	})
+	var got changes
+	watch(&got, &adult.Changes)
+
+	age.Set(16)
+	age.Set(17)
+	expectChanges(t, &got)
+	age.Set(18)
+	expectChanges(t, &got, "Adult:false->true")
+	age.Set(18) // no change at all
+	age.Set(30)
+	expectChanges(t, &got)
+	if computations != 5 {
+		t.Errorf("Expected one computation per change of age, got %d", computations)
+	}
+}
+
+func TestComputed_Chain(t *testing.T) {
+	var scope Scope
+	first := NewProperty(&scope, "First", "Bruce")
+	last := NewProperty(&scope, "Last", "Wayne")
+	full := NewComputed(&scope, "Full", func() string { return first.Get() + " " + last.Get() })
+	initials := NewComputed(&scope, "Initials", func() string { return full.Get()[:1] + last.Get()[:1] })
+	length := NewComputed(&scope, "Length", func() int { return len(full.Get()) })
+
+	var got changes
+	watch(&got, &length.Changes)
+	watch(&got, &initials.Changes)
+	watch(&got, &full.Changes)
+	watch(&got, &first.Changes)
+
+	// dependencies first, whatever the order of subscription; length does
+	// not change
+	first.Set("Billy")
+	expectChanges(t, &got, "First:Bruce->Billy", "Full:Bruce Wayne->Billy Wayne")
+	last.Set("Batson")
+	expectChanges(t, &got, "Full:Billy Wayne->Billy Batson", "Initials:BW->BB", "Length:11->12")
+}
+
+func TestComputed_ObserversSeeConsistentValues(t *testing.T) {
+	var scope Scope
+	a := NewProperty(&scope, "A", 1)
+	double := NewComputed(&scope, "Double", func() int { return 2 * a.Get() })
+	sum := NewComputed(&scope, "Sum", func() int { return a.Get() + double.Get() })
+	var seen []int
+	a.Changes.SubscribeFunc(func(PropertyChange[int]) {
+		seen = append(seen, a.Get(), double.Get(), sum.Get())
+	})
+	a.Set(2)
+	if !slices.Equal(seen, []int{2, 4, 6}) {
+		t.Errorf("Expected up to date values, got %v", seen)
+	}
+}
+
+func TestComputed_DynamicDependencies(t *testing.T) {
+	var scope Scope
+	useNickname := NewProperty(&scope, "UseNickname", false)
+	name := NewProperty(&scope, "Name", "Bruce")
+	nickname := NewProperty(&scope, "Nickname", "Batman")
+	display := NewComputed(&scope, "Display", func() string {
+		if useNickname.Get() {
+			return nickname.Get()
+		}
+		return name.Get()
+	})
+	var got changes
+	watch(&got, &display.Changes)
+
+	nickname.Set("The Bat") // not read yet
+	expectChanges(t, &got)
+	useNickname.Set(true)
+	expectChanges(t, &got, "Display:Bruce->The Bat")
+	name.Set("Wayne") // no longer read
+	nickname.Set("Batman")
+	expectChanges(t, &got, "Display:The Bat->Batman")
+	if display.dependencies[&name.node] {
+		t.Error("Expected Name to be dropped from the dependencies")
+	}
+}
+
+func TestScope_Batch(t *testing.T) {
+	var scope Scope
+	age := NewProperty(&scope, "Age", 17)
+	name := NewProperty(&scope, "Name", "Bruce")
+	adult := NewComputed(&scope, "Adult", func() bool { return age.Get() >= 18 })
+	var got changes
+	watch(&got, &age.Changes)
+	watch(&got, &name.Changes)
+	watch(&got, &adult.Changes)
+
+	scope.Batch(func() {
+		age.Set(18)
+		if !adult.Get() {
+			t.Error("Expected Get to be up to date in a batch")
+		}
+		expectChanges(t, &got) // nothing before the end
+		name.Set("Bruce Wayne")
+		age.Set(19)
+		scope.Batch(func() { age.Set(20) }) // nested batches end with the outer one
+		expectChanges(t, &got)
+	})
+	expectChanges(t, &got, "Age:17->20", "Name:Bruce->Bruce Wayne", "Adult:false->true")
+
+	// back and forth within a batch is no change
+	scope.Batch(func() {
+		age.Set(10)
+		age.Set(20)
+	})
+	expectChanges(t, &got)
+}
+
+func TestScope_SetFromObserver(t *testing.T) {
+	var scope Scope
+	celsius := NewProperty(&scope, "Celsius", 0)
+	fahrenheit := NewProperty(&scope, "Fahrenheit", 32)
+	celsius.Changes.SubscribeFunc(func(pc PropertyChange[int]) { fahrenheit.Set(pc.New*9/5 + 32) })
+	fahrenheit.Changes.SubscribeFunc(func(pc PropertyChange[int]) { celsius.Set((pc.New - 32) * 5 / 9) })
+	var got changes
+	watch(&got, &celsius.Changes)
+	watch(&got, &fahrenheit.Changes)
+
+	celsius.Set(100)
+	expectChanges(t, &got, "Celsius:0->100", "Fahrenheit:32->212")
+}
+
+func TestComputed_Cycle(t *testing.T) {
+	var scope Scope
+	var c *Computed[int]
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a computed property that reads itself")
+		}
+	}()
+	c = NewComputed(&scope, "Cycle", func() int {
+		if c == nil {
+			return 0
+		}
+		return c.Get() + 1
+	})
+	c.recompute()
+}